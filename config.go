@@ -5,11 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"srs/core"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
 )
 
 type Config struct {
-	BaseDeckPath string
+	BaseDeckPath   string
+	Weights        []float64 // FSRS weights tuned by 'srs optimize', empty for defaults
+	MetadataFormat string    // "comment" or "yaml", empty for the default comment format
+	LearnAhead     string    // learn-ahead window such as "20m", empty for the default
+	Grader         string    // grader suggesting ratings for typed answers, empty for none
+	GraderCommand  string    // program run by the command grader
 }
 
 const ConfigDirName = "srs"
@@ -74,7 +85,40 @@ func loadConfig() (*Config, error) {
 				}
 			}
 			config.BaseDeckPath = value
-			break // Only need this one line
+			continue
+		}
+
+		// Parse weights=w0,w1,... format
+		if strings.HasPrefix(line, "weights=") {
+			weights, err := parseWeights(strings.TrimPrefix(line, "weights="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid weights in config: %v", err)
+			}
+			config.Weights = weights
+			continue
+		}
+
+		// Parse metadata_format=comment|yaml format
+		if strings.HasPrefix(line, "metadata_format=") {
+			config.MetadataFormat = strings.TrimSpace(strings.TrimPrefix(line, "metadata_format="))
+			continue
+		}
+
+		// Parse learn_ahead=duration format
+		if strings.HasPrefix(line, "learn_ahead=") {
+			config.LearnAhead = strings.TrimSpace(strings.TrimPrefix(line, "learn_ahead="))
+			continue
+		}
+
+		// Parse grader=exact|fuzzy|command format
+		if strings.HasPrefix(line, "grader=") {
+			config.Grader = strings.TrimSpace(strings.TrimPrefix(line, "grader="))
+			continue
+		}
+
+		// Parse grader_command=program format
+		if strings.HasPrefix(line, "grader_command=") {
+			config.GraderCommand = strings.TrimSpace(strings.TrimPrefix(line, "grader_command="))
 		}
 	}
 
@@ -109,9 +153,107 @@ func saveConfig(config *Config) error {
 		fmt.Fprintf(file, "base_deck=%s\n", path)
 	}
 
+	// Write tuned FSRS weights
+	if len(config.Weights) > 0 {
+		values := make([]string, len(config.Weights))
+		for i, w := range config.Weights {
+			values[i] = strconv.FormatFloat(w, 'f', 4, 64)
+		}
+		fmt.Fprintln(file, "")
+		fmt.Fprintln(file, "# FSRS weights tuned by 'srs optimize'")
+		fmt.Fprintf(file, "weights=%s\n", strings.Join(values, ","))
+	}
+
+	// Write card metadata format
+	if config.MetadataFormat != "" {
+		fmt.Fprintln(file, "")
+		fmt.Fprintln(file, "# Card metadata format written after reviews: comment or yaml")
+		fmt.Fprintf(file, "metadata_format=%s\n", config.MetadataFormat)
+	}
+
+	// Write learn-ahead window
+	if config.LearnAhead != "" {
+		fmt.Fprintln(file, "")
+		fmt.Fprintln(file, "# How early learning cards are shown once nothing else is due, e.g. 20m or 0")
+		fmt.Fprintf(file, "learn_ahead=%s\n", config.LearnAhead)
+	}
+
+	// Write answer grader
+	if config.Grader != "" || config.GraderCommand != "" {
+		fmt.Fprintln(file, "")
+		fmt.Fprintln(file, "# Grader suggesting ratings for typed answers: exact, fuzzy or command")
+		if config.Grader != "" {
+			fmt.Fprintf(file, "grader=%s\n", config.Grader)
+		}
+		if config.GraderCommand != "" {
+			fmt.Fprintf(file, "grader_command=%s\n", config.GraderCommand)
+		}
+	}
+
 	return nil
 }
 
+func parseWeights(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != len(fsrs.Weights{}) {
+		return nil, fmt.Errorf("expected %d weights, got %d", len(fsrs.Weights{}), len(parts))
+	}
+
+	weights := make([]float64, len(parts))
+	for i, part := range parts {
+		w, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		weights[i] = w
+	}
+
+	return weights, nil
+}
+
+// fsrsWeights returns the tuned weights from the config, if any
+func (c *Config) fsrsWeights() (fsrs.Weights, bool) {
+	var weights fsrs.Weights
+	if len(c.Weights) != len(weights) {
+		return weights, false
+	}
+	copy(weights[:], c.Weights)
+	return weights, true
+}
+
+// learnAhead returns the learn-ahead window: the flag value if given, else
+// the configured one, else the default
+func (c *Config) learnAhead(flagValue string) (time.Duration, error) {
+	switch {
+	case flagValue != "":
+		return core.ParseLearnAhead(flagValue)
+	case c.LearnAhead != "":
+		window, err := core.ParseLearnAhead(c.LearnAhead)
+		if err != nil {
+			return 0, fmt.Errorf("learn_ahead in config: %v", err)
+		}
+		return window, nil
+	}
+	return core.DefaultLearnAhead, nil
+}
+
+// grader returns the grader for typed answers: the flag value if given, else
+// the configured one. It's nil when neither names one, or the name is "off".
+func (c *Config) grader(flagValue string) (core.Grader, error) {
+	name := flagValue
+	if name == "" {
+		name = c.Grader
+	}
+	if name == "" || name == "off" {
+		return nil, nil
+	}
+	grader, err := core.NewGrader(name, c.GraderCommand)
+	if err != nil && flagValue == "" {
+		return nil, fmt.Errorf("grader in config: %v", err)
+	}
+	return grader, err
+}
+
 func resolveDeckPath(deckName string, config *Config) (string, error) {
 	// If no base deck is configured, return error
 	if config.BaseDeckPath == "" {
@@ -195,10 +337,12 @@ func promptForBaseDeck() error {
 			}
 		}
 		
-		// Save the configuration
-		config := &Config{
-			BaseDeckPath: absPath,
+		// Save the configuration, keeping any other settings
+		config, err := loadConfig()
+		if err != nil {
+			config = &Config{}
 		}
+		config.BaseDeckPath = absPath
 		
 		err = saveConfig(config)
 		if err != nil {
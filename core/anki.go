@@ -0,0 +1,627 @@
+package core
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"srs/sqlite"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// AnkiMediaDir is the directory imported Anki media is copied to, inside the
+// deck the package is imported into
+const AnkiMediaDir = "media"
+
+// ankiCloze is the type of Anki's cloze note types
+const ankiCloze = 1
+
+// Anki card types, queues and review log types
+const (
+	ankiLearning       = 1
+	ankiReview         = 2
+	ankiRelearning     = 3
+	ankiSuspended      = -1
+	ankiManualSchedule = 4
+)
+
+// ankiModel is an Anki note type: its fields and card templates
+type ankiModel struct {
+	Name   string `json:"name"`
+	Type   int    `json:"type"`
+	Fields []struct {
+		Name string `json:"name"`
+		Ord  int    `json:"ord"`
+	} `json:"flds"`
+	Templates []ankiTemplate `json:"tmpls"`
+}
+
+// ankiTemplate is a card template of an Anki note type
+type ankiTemplate struct {
+	Name     string `json:"name"`
+	Ord      int    `json:"ord"`
+	Question string `json:"qfmt"`
+	Answer   string `json:"afmt"`
+}
+
+// ankiCollection is the content of an Anki package
+type ankiCollection struct {
+	archive *zip.ReadCloser
+	created time.Time // day numbers of review cards count from here
+	models  map[int64]ankiModel
+	decks   map[int64]string
+	notes   []sqlite.Row
+	cards   map[int64][]sqlite.Row // by note ID, in template order
+	reviews map[int64][]sqlite.Row // by card ID, oldest first
+	media   map[string]*zip.File   // by file name
+}
+
+// AnkiImport summarizes what ImportAnki imported
+type AnkiImport struct {
+	Notes    int // notes written as card files
+	Cards    int // Anki cards in those notes
+	Reviews  int // ratings replayed from Anki's review log
+	Media    int // media files copied
+	Skipped  int // notes whose question is already in the deck
+	Warnings []string
+}
+
+// readAnkiPackage opens an .apkg file and reads its collection
+func readAnkiPackage(path string) (*ankiCollection, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	// Anki 2.1 packages have the real collection in collection.anki21, and
+	// the newest ones compress it into collection.anki21b
+	collection := files["collection.anki21"]
+	if collection == nil && files["collection.anki21b"] != nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s uses the compressed format of recent Anki versions; export it again with \"Support older Anki versions\" checked", path)
+	}
+	if collection == nil {
+		collection = files["collection.anki2"]
+	}
+	if collection == nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s is not an Anki package: no collection inside", path)
+	}
+
+	col, err := readAnkiCollection(collection)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	col.archive = archive
+	col.media = make(map[string]*zip.File)
+	if list := files["media"]; list != nil {
+		names, err := readAnkiMediaList(list)
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+		for key, name := range names {
+			if file := files[key]; file != nil && filepath.Base(name) == name && name != ".." {
+				col.media[name] = file
+			}
+		}
+	}
+	return col, nil
+}
+
+// readAnkiMediaList reads the media file of a package, which maps the
+// numbered files in it to their names
+func readAnkiMediaList(file *zip.File) (map[string]string, error) {
+	data, err := readZipFile(file)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("unreadable media list; export the deck again with \"Support older Anki versions\" checked")
+	}
+	return names, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// readAnkiCollection reads the note types, decks, notes, cards and review log
+// of a collection database
+func readAnkiCollection(file *zip.File) (*ankiCollection, error) {
+	data, err := readZipFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection: %v", err)
+	}
+	db, err := sqlite.Read(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection: %v", err)
+	}
+	if !db.HasTable("col") || !db.HasTable("notes") || !db.HasTable("cards") {
+		return nil, fmt.Errorf("not an Anki collection")
+	}
+
+	info, err := db.Rows("col")
+	if err != nil {
+		return nil, err
+	}
+	if len(info) == 0 {
+		return nil, fmt.Errorf("not an Anki collection: no collection info")
+	}
+	if info[0].Text("models") == "" && db.HasTable("notetypes") {
+		return nil, fmt.Errorf("the collection uses a newer Anki format; export it again with \"Support older Anki versions\" checked")
+	}
+
+	col := &ankiCollection{
+		created: time.Unix(info[0].Int("crt"), 0),
+		models:  make(map[int64]ankiModel),
+		decks:   make(map[int64]string),
+		cards:   make(map[int64][]sqlite.Row),
+		reviews: make(map[int64][]sqlite.Row),
+	}
+
+	var models map[string]ankiModel
+	if err := json.Unmarshal([]byte(info[0].Text("models")), &models); err != nil {
+		return nil, fmt.Errorf("failed to read note types: %v", err)
+	}
+	for id, model := range models {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		col.models[n] = model
+	}
+
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(info[0].Text("decks")), &decks); err != nil {
+		return nil, fmt.Errorf("failed to read decks: %v", err)
+	}
+	for id, deck := range decks {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		col.decks[n] = deck.Name
+	}
+
+	if col.notes, err = db.Rows("notes"); err != nil {
+		return nil, err
+	}
+	cards, err := db.Rows("cards")
+	if err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		col.cards[card.Int("nid")] = append(col.cards[card.Int("nid")], card)
+	}
+	for _, cards := range col.cards {
+		sort.Slice(cards, func(i, j int) bool { return cards[i].Int("ord") < cards[j].Int("ord") })
+	}
+
+	if db.HasTable("revlog") {
+		reviews, err := db.Rows("revlog")
+		if err != nil {
+			return nil, err
+		}
+		// Review IDs are their timestamps in milliseconds, so rowid order is
+		// time order
+		for _, review := range reviews {
+			col.reviews[review.Int("cid")] = append(col.reviews[review.Int("cid")], review)
+		}
+	}
+	return col, nil
+}
+
+func (col *ankiCollection) Close() error {
+	return col.archive.Close()
+}
+
+// deckDir returns the directory for an Anki deck relative to the import
+// deck: subdecks (Languages::Spanish) become subdirectories, and the Default
+// deck the import deck itself
+func (col *ankiCollection) deckDir(id int64) string {
+	name := col.decks[id]
+	if name == "" || name == "Default" {
+		return ""
+	}
+	var parts []string
+	for _, part := range strings.Split(name, "::") {
+		parts = append(parts, Slugify(part))
+	}
+	return filepath.Join(parts...)
+}
+
+// ankiNote is a note converted to markdown, ready to be written as a card
+// file
+type ankiNote struct {
+	blocks  [][2]string // question and answer of each block
+	reverse bool        // the second card reverses the first
+	cloze   bool
+	tags    []string
+}
+
+// ankiFrontSide stands in for {{FrontSide}} in answer templates, so the
+// question can be cut from the answer
+const ankiFrontSide = "\x00front\x00"
+
+var (
+	// ankiAnswerRule is the <hr id=answer> separating question and answer
+	ankiAnswerRule = regexp.MustCompile(`(?i)<hr[^>]*id=["']?answer["']?[^>]*>`)
+	// ankiClozeField finds the field a cloze template fills in
+	ankiClozeField = regexp.MustCompile(`\{\{(?:[^{}:]*:)*cloze:([^{}]+)\}\}`)
+)
+
+// convertNote turns a note into card blocks: one with cloze deletions for
+// cloze notes, one reversible block for forward and reverse cards, or else a
+// block per card. Media names are passed through media for linking.
+func (col *ankiCollection) convertNote(note sqlite.Row, model ankiModel, cards []sqlite.Row, media func(string) string) ankiNote {
+	values := strings.Split(note.Text("flds"), "\x1f")
+	fields := make(map[string]string)
+	for _, field := range model.Fields {
+		if field.Ord < len(values) {
+			fields[field.Name] = values[field.Ord]
+		}
+	}
+
+	result := ankiNote{tags: ankiTags(note.Text("tags"))}
+	suspended := true
+	for _, card := range cards {
+		suspended = suspended && card.Int("queue") == ankiSuspended
+	}
+	if suspended {
+		result.tags = mergeTags(result.tags, SuspendedTag)
+	}
+
+	if model.Type == ankiCloze && len(model.Templates) > 0 {
+		template := model.Templates[0]
+		name := ""
+		if match := ankiClozeField.FindStringSubmatch(template.Question); match != nil {
+			name = strings.TrimSpace(match[1])
+		} else if len(model.Fields) > 0 {
+			name = model.Fields[0].Name
+		}
+
+		// The answer template without the cloze text leaves the extra fields
+		rest := make(map[string]string)
+		for key, value := range fields {
+			if key != name {
+				rest[key] = value
+			}
+		}
+		text := htmlToMarkdown(fields[name], media)
+		extra := htmlToMarkdown(strings.ReplaceAll(renderAnkiTemplate(template.Answer, rest, "", true), ankiFrontSide, ""), media)
+		result.cloze = true
+		result.blocks = [][2]string{{text, extra}}
+		return result
+	}
+
+	templates := make(map[int64]ankiTemplate)
+	for _, template := range model.Templates {
+		templates[int64(template.Ord)] = template
+	}
+	for _, card := range cards {
+		template := templates[card.Int("ord")]
+		front := renderAnkiTemplate(template.Question, fields, "", false)
+		back := renderAnkiTemplate(template.Answer, fields, ankiFrontSide, true)
+		if rule := ankiAnswerRule.FindStringIndex(back); rule != nil {
+			back = back[rule[1]:]
+		}
+		back = strings.ReplaceAll(back, ankiFrontSide, "")
+		result.blocks = append(result.blocks, [2]string{htmlToMarkdown(front, media), htmlToMarkdown(back, media)})
+	}
+
+	if len(cards) == 2 && cards[0].Int("ord") == 0 && cards[1].Int("ord") == 1 &&
+		result.blocks[0][0] == result.blocks[1][1] && result.blocks[0][1] == result.blocks[1][0] {
+		result.blocks = result.blocks[:1]
+		result.reverse = true
+	}
+	return result
+}
+
+// question is the note's first question, for naming its file and finding
+// duplicates
+func (n ankiNote) question() string {
+	text := n.blocks[0][0]
+	if indices := clozeIndices(text); n.cloze && len(indices) > 0 {
+		return clozeQuestion(text, indices[0])
+	}
+	return text
+}
+
+// content lays out the note's card file
+func (n ankiNote) content() (string, error) {
+	var lines []string
+	if n.reverse {
+		lines = append(lines, "---", "reverse: true", "---", "")
+	}
+	tags := ""
+	if len(n.tags) > 0 {
+		var err error
+		if tags, err = tagLine(n.tags); err != nil {
+			return "", err
+		}
+	}
+
+	for i, block := range n.blocks {
+		if i > 0 {
+			lines = append(lines, "", cardSeparator, "")
+		}
+		lines = append(lines, block[0])
+		if !n.cloze || block[1] != "" {
+			lines = append(lines, "---")
+		}
+		if block[1] != "" {
+			lines = append(lines, block[1])
+		}
+		if tags != "" {
+			lines = append(lines, "", tags)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// ankiTags converts a note's tags to srs tags: Anki's hierarchy separator
+// :: becomes /, and other characters tags can't hold become -
+func ankiTags(field string) []string {
+	var tags []string
+	for _, tag := range strings.Fields(field) {
+		tag = strings.ReplaceAll(tag, "::", "/")
+		tag = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '/' || r == '-' {
+				return r
+			}
+			return '-'
+		}, tag)
+		if tag = strings.TrimLeft(tag, "/-"); tag != "" {
+			tags = mergeTags(tags, normalizeTag(tag))
+		}
+	}
+	return tags
+}
+
+// ImportAnki imports the notes of an Anki .apkg package into dir as card
+// files, with Anki's decks as subdecks. Media the notes use is copied into
+// the media directory of dir, and each card's review log is replayed to give
+// it an FSRS state and history. Notes whose question is already in dir are
+// skipped, so importing a package again only adds its new notes.
+func ImportAnki(path, dir string) (*AnkiImport, error) {
+	col, err := readAnkiPackage(path)
+	if err != nil {
+		return nil, err
+	}
+	defer col.Close()
+
+	var existing []*Card
+	if _, err := os.Stat(dir); err == nil {
+		if existing, err = FindCards(dir); err != nil {
+			return nil, fmt.Errorf("failed to load cards: %v", err)
+		}
+	}
+
+	result := &AnkiImport{}
+	mediaDir := filepath.Join(dir, AnkiMediaDir)
+	copied := make(map[string]bool)
+	var journal []ReviewRecord
+	root := ""
+
+	for _, note := range col.notes {
+		cards := col.cards[note.Int("id")]
+		if len(cards) == 0 {
+			continue
+		}
+		model, ok := col.models[note.Int("mid")]
+		if !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("skipped note %d: unknown note type %d", note.Int("id"), note.Int("mid")))
+			continue
+		}
+
+		noteDir := filepath.Join(dir, col.deckDir(cards[0].Int("did")))
+		var used []string
+		converted := col.convertNote(note, model, cards, func(name string) string {
+			if col.media[name] == nil {
+				unescaped, err := url.PathUnescape(name)
+				if err != nil || col.media[unescaped] == nil {
+					return name
+				}
+				name = unescaped
+			}
+			used = append(used, name)
+			rel, err := filepath.Rel(noteDir, filepath.Join(mediaDir, name))
+			if err != nil {
+				return name
+			}
+			return filepath.ToSlash(rel)
+		})
+		if FindDuplicate(existing, converted.question()) != nil {
+			result.Skipped++
+			continue
+		}
+
+		for _, name := range used {
+			if copied[name] {
+				continue
+			}
+			copied[name] = true
+			written, err := col.copyMedia(name, mediaDir)
+			if err != nil {
+				return result, fmt.Errorf("failed to copy %s: %v", name, err)
+			}
+			if written {
+				result.Media++
+			}
+		}
+
+		filePath, err := writeAnkiNote(noteDir, converted)
+		if err != nil {
+			return result, fmt.Errorf("failed to write note %d: %v", note.Int("id"), err)
+		}
+		imported, err := ParseCards(filePath)
+		if err != nil {
+			return result, err
+		}
+		result.Notes++
+
+		for _, ankiCard := range cards {
+			card := matchAnkiCard(imported, converted, ankiCard.Int("ord"))
+			if card == nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: card %d of the note has no counterpart", filePath, ankiCard.Int("ord")+1))
+				continue
+			}
+			result.Cards++
+
+			var logs []fsrs.ReviewLog
+			card.FSRSCard, logs = col.schedule(card, ankiCard)
+			if err := card.UpdateFSRSMetadata(); err != nil {
+				return result, err
+			}
+			for _, log := range logs {
+				journal = append(journal, NewReviewRecord(card.Key(), log))
+			}
+			result.Reviews += len(logs)
+			root = card.Root
+		}
+	}
+
+	if root != "" && len(journal) > 0 {
+		if err := AppendReviews(root, journal); err != nil {
+			return result, fmt.Errorf("failed to journal reviews: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// copyMedia copies a media file into dir unless a file of that name is
+// already there, reporting whether it did
+func (col *ankiCollection) copyMedia(name, dir string) (bool, error) {
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return false, nil
+	}
+	data, err := readZipFile(col.media[name])
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(target, data, 0644)
+}
+
+// writeAnkiNote writes a note to a new file in dir named after its question
+func writeAnkiNote(dir string, note ankiNote) (string, error) {
+	content, err := note.content()
+	if err != nil {
+		return "", err
+	}
+
+	stem := Slugify(stripMarkdownLinks(replaceClozes(note.blocks[0][0], func(_ int, answer, _ string) string { return answer })))
+	return createCardFile(dir, stem, content)
+}
+
+// markdownLink matches markdown links and images, for stripMarkdownLinks
+var markdownLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// stripMarkdownLinks replaces links with their text and drops images, so
+// file names aren't made of media paths
+func stripMarkdownLinks(text string) string {
+	stripped := markdownLink.ReplaceAllStringFunc(text, func(link string) string {
+		if strings.HasPrefix(link, "!") {
+			return ""
+		}
+		return markdownLink.FindStringSubmatch(link)[1]
+	})
+	if strings.TrimSpace(stripped) == "" {
+		return text
+	}
+	return stripped
+}
+
+// matchAnkiCard finds the card an Anki card of the given template or cloze
+// number became
+func matchAnkiCard(cards []*Card, note ankiNote, ord int64) *Card {
+	for _, card := range cards {
+		switch {
+		case note.cloze:
+			if int64(card.Cloze) == ord+1 {
+				return card
+			}
+		case note.reverse:
+			if card.Reverse == (ord == 1) {
+				return card
+			}
+		case int64(card.Block) == ord || len(note.blocks) == 1:
+			return card
+		}
+	}
+	return nil
+}
+
+// schedule works out a card's FSRS state by replaying its Anki reviews. Cards
+// whose review log is missing get a state matching their Anki interval.
+func (col *ankiCollection) schedule(card *Card, ankiCard sqlite.Row) (fsrs.Card, []fsrs.ReviewLog) {
+	scheduler := card.Scheduler()
+	state := fsrs.NewCard()
+	var logs []fsrs.ReviewLog
+	for _, review := range col.reviews[ankiCard.Int("id")] {
+		ease := review.Int("ease")
+		if review.Int("type") == ankiManualSchedule {
+			// Rescheduling by hand isn't a rating, but "forget" starts over
+			if review.Int("ivl") == 0 {
+				state = fsrs.NewCard()
+			}
+			continue
+		}
+		if ease < 1 || ease > 4 {
+			continue
+		}
+		info := scheduler.Repeat(state, time.UnixMilli(review.Int("id")))[fsrs.Rating(ease)]
+		state = info.Card
+		logs = append(logs, info.ReviewLog)
+	}
+	if len(logs) > 0 {
+		return state, logs
+	}
+
+	due, interval := ankiCard.Int("due"), ankiCard.Int("ivl")
+	if ankiCard.Int("odid") != 0 {
+		due = ankiCard.Int("odue") // the card is in a filtered deck
+	}
+	switch ankiCard.Int("type") {
+	case ankiReview, ankiRelearning:
+		state.State = fsrs.Review
+		state.Due = col.created.AddDate(0, 0, int(due))
+		state.ScheduledDays = uint64(max(interval, 1))
+		state.Stability = float64(max(interval, 1))
+		state.Difficulty = 5
+		state.LastReview = state.Due.AddDate(0, 0, -int(state.ScheduledDays))
+	case ankiLearning:
+		state.State = fsrs.Learning
+		state.Due = time.Unix(due, 0)
+		state.Stability = 1
+		state.Difficulty = 5
+	default:
+		return state, nil
+	}
+	state.Reps = uint64(ankiCard.Int("reps"))
+	state.Lapses = uint64(ankiCard.Int("lapses"))
+	return state, nil
+}
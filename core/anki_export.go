@@ -0,0 +1,513 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"srs/sqlite"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Note types of exported packages. Their IDs are fixed so notes exported
+// again update the note types imported before.
+const (
+	ankiBasicModel    int64 = 1700000000001
+	ankiReversedModel int64 = 1700000000002
+	ankiClozeModel    int64 = 1700000000003
+)
+
+// ankiDayStart is the hour Anki starts a new day at
+const ankiDayStart = 4
+
+// ankiCSS styles exported cards; markdown lists and code read better left
+// aligned than Anki's default centered text
+const ankiCSS = `.card { font-family: arial; font-size: 20px; text-align: left; color: black; background-color: white; }
+.cloze { font-weight: bold; color: blue; }
+pre { background: #f4f4f4; padding: 0.5em; }`
+
+// ankiSchema is the legacy collection schema every Anki version imports
+var ankiSchema = []sqlite.Table{
+	{Name: "col", SQL: `CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null, ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null, models text not null, decks text not null, dconf text not null, tags text not null)`},
+	{Name: "notes", SQL: `CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null, usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null, flags integer not null, data text not null)`},
+	{Name: "cards", SQL: `CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null, mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null, ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null, odue integer not null, odid integer not null, flags integer not null, data text not null)`},
+	{Name: "revlog", SQL: `CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null, ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null)`},
+	{Name: "graves", SQL: `CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)`},
+}
+
+// AnkiExport summarizes what ExportAnki exported
+type AnkiExport struct {
+	Notes    int // Anki notes: one per card block
+	Cards    int
+	Reviews  int // review log entries, when exporting scheduling
+	Media    int // images and sounds bundled
+	Warnings []string
+}
+
+// ankiExporter gathers the collection and media of a package being exported
+type ankiExporter struct {
+	dir        string
+	scheduling bool
+	now        time.Time
+	created    time.Time // start of the collection's first day
+	ids        map[int64]bool
+	decks      map[string]int64
+	media      map[string]string // bundled name by source path
+	mediaNames map[string]bool
+	notes      [][]interface{}
+	cards      [][]interface{}
+	revlog     [][]interface{}
+	result     *AnkiExport
+}
+
+// ExportAnki writes cards from the deck in dir as an Anki package at path.
+// Each card block becomes a note, in an Anki deck named after dir with its
+// subdirectories as subdecks. Markdown is converted to HTML and local images
+// and sounds are bundled. With scheduling, cards keep their due dates and
+// FSRS memory state and take their review history along; otherwise they
+// start as new cards.
+func ExportAnki(cards []*Card, dir, path string, scheduling bool) (*AnkiExport, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &ankiExporter{
+		dir:        absDir,
+		scheduling: scheduling,
+		now:        time.Now(),
+		ids:        make(map[int64]bool),
+		decks:      make(map[string]int64),
+		media:      make(map[string]string),
+		mediaNames: make(map[string]bool),
+		result:     &AnkiExport{},
+	}
+
+	// Day numbers count from the collection's creation, so start it early
+	// enough for every overdue card
+	first := e.now
+	if scheduling {
+		for _, card := range cards {
+			if card.FSRSCard.State == fsrs.Review && card.FSRSCard.Due.Before(first) {
+				first = card.FSRSCard.Due
+			}
+		}
+	}
+	e.created = ankiDay(first)
+
+	// Cards sharing a block make up one note
+	var order []string
+	blocks := make(map[string][]*Card)
+	for _, card := range cards {
+		key := fmt.Sprintf("%s\x00%d", card.FilePath, card.Block)
+		if blocks[key] == nil {
+			order = append(order, key)
+		}
+		blocks[key] = append(blocks[key], card)
+	}
+	for i, key := range order {
+		e.addNote(blocks[key], i+1)
+	}
+
+	collection, err := e.collection()
+	if err != nil {
+		return e.result, err
+	}
+	return e.result, e.writePackage(path, collection)
+}
+
+// ankiDay returns the start of the Anki day t falls on
+func ankiDay(t time.Time) time.Time {
+	day := t.Add(-ankiDayStart * time.Hour)
+	return time.Date(day.Year(), day.Month(), day.Day(), ankiDayStart, 0, 0, 0, t.Location())
+}
+
+// id returns an unused Anki ID for a note or card: like Anki's own, the time
+// it was made in milliseconds, taken from its srs ID. Exporting the deck
+// again gives the same IDs, so Anki updates the notes it imported before.
+func (e *ankiExporter) id(key string) int64 {
+	id := e.now.UnixMilli()
+	if created, ok := idTime(key); ok {
+		id = created.UnixMilli()
+	}
+	for e.ids[id] {
+		id++
+	}
+	e.ids[id] = true
+	return id
+}
+
+// deck returns the ID of the Anki deck for a card directory, adding it
+func (e *ankiExporter) deck(cardDir string) int64 {
+	name := filepath.Base(e.dir)
+	if abs, err := filepath.Abs(cardDir); err == nil {
+		cardDir = abs
+	}
+	if rel, err := filepath.Rel(e.dir, cardDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		name += "::" + strings.Join(strings.Split(filepath.ToSlash(rel), "/"), "::")
+	}
+	if id, ok := e.decks[name]; ok {
+		return id
+	}
+	// Decks are found by name, so any ID in the range of Anki's will do
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	id := 1<<40 + int64(hash.Sum64()%(1<<40))
+	e.decks[name] = id
+	return id
+}
+
+// addNote adds the note for the cards of a block, new cards due in position
+func (e *ankiExporter) addNote(cards []*Card, position int) {
+	first := cards[0]
+	model := ankiBasicModel
+	var fields []string
+	switch {
+	case first.Cloze > 0:
+		model = ankiClozeModel
+		extra := strings.TrimSpace(strings.TrimPrefix(first.Answer, clozeAnswer(first.Text, "", first.Cloze)))
+		fields = []string{e.html(first, first.Text), e.html(first, extra)}
+	default:
+		for _, card := range cards {
+			if card.Reverse {
+				model = ankiReversedModel
+			} else {
+				first = card
+			}
+		}
+		fields = []string{e.html(first, first.Question), e.html(first, first.Answer)}
+	}
+
+	var tags []string
+	for _, tag := range first.Tags {
+		tags = append(tags, strings.ReplaceAll(tag, "/", "::"))
+	}
+	tagField := ""
+	if len(tags) > 0 {
+		tagField = " " + strings.Join(tags, " ") + " "
+	}
+
+	sortField := stripHTML(fields[0])
+	checksum := sha1.Sum([]byte(sortField))
+	csum, _ := strconv.ParseInt(fmt.Sprintf("%x", checksum[:4]), 16, 64)
+
+	noteID := e.id(first.Key())
+	e.notes = append(e.notes, []interface{}{
+		noteID, first.Key(), model, e.now.Unix(), -1, tagField,
+		strings.Join(fields, "\x1f"), sortField, csum, 0, "",
+	})
+	e.result.Notes++
+
+	deck := e.deck(filepath.Dir(first.FilePath))
+	for _, card := range cards {
+		ord := 0
+		if card.Cloze > 0 {
+			ord = card.Cloze - 1
+		} else if card.Reverse {
+			ord = 1
+		}
+		cardID := e.id(card.Key())
+		cardType, queue, due, interval, factor, data := e.schedule(card, position)
+		if card.Suspended() {
+			queue = ankiSuspended
+		}
+		left := 0
+		if cardType == ankiLearning || cardType == ankiRelearning {
+			left = 1001 // one step left, due today
+		}
+		e.cards = append(e.cards, []interface{}{
+			cardID, noteID, deck, ord, e.now.Unix(), -1, cardType, queue, due, interval, factor,
+			int64(card.FSRSCard.Reps), int64(card.FSRSCard.Lapses), left, 0, 0, 0, data,
+		})
+		e.result.Cards++
+		if e.scheduling && cardType != 0 {
+			e.addReviews(card, cardID)
+		}
+	}
+}
+
+// schedule maps a card's FSRS state to Anki's card type, queue, due date,
+// interval, ease factor and memory state. Without scheduling every card is
+// new.
+func (e *ankiExporter) schedule(card *Card, position int) (cardType, queue, due, interval, factor int64, data string) {
+	state := card.FSRSCard
+	if !e.scheduling || state.State == fsrs.New {
+		return 0, 0, int64(position), 0, 0, ""
+	}
+
+	memory, _ := json.Marshal(map[string]float64{
+		"s": math.Round(state.Stability*100) / 100,
+		"d": math.Round(state.Difficulty*100) / 100,
+	})
+	data = string(memory)
+	switch state.State {
+	case fsrs.Learning:
+		return ankiLearning, ankiLearning, state.Due.Unix(), 0, 2500, data
+	case fsrs.Relearning:
+		return ankiRelearning, ankiLearning, state.Due.Unix(), int64(max(state.ScheduledDays, 1)), 2500, data
+	}
+	days := int64(ankiDay(state.Due).Sub(e.created).Hours()+12) / 24
+	return ankiReview, ankiReview, days, int64(max(state.ScheduledDays, 1)), 2500, data
+}
+
+// addReviews adds a card's review history to the review log
+func (e *ankiExporter) addReviews(card *Card, cardID int64) {
+	for i, log := range card.ReviewLog {
+		if log.Rating < fsrs.Again || log.Rating > fsrs.Easy {
+			continue
+		}
+		// Logs hold the interval before the review; the next one, or the
+		// card's, is the interval it gave
+		interval := card.FSRSCard.ScheduledDays
+		if i+1 < len(card.ReviewLog) {
+			interval = card.ReviewLog[i+1].ScheduledDays
+		}
+		reviewType := 0 // learning
+		switch log.State {
+		case fsrs.Review:
+			reviewType = 1
+		case fsrs.Relearning:
+			reviewType = 2
+		}
+		id := log.Review.UnixMilli()
+		for e.ids[id] {
+			id++
+		}
+		e.ids[id] = true
+		e.revlog = append(e.revlog, []interface{}{
+			id, cardID, -1, int64(log.Rating), int64(interval), int64(log.ScheduledDays), 2500, 0, reviewType,
+		})
+		e.result.Reviews++
+	}
+}
+
+// markdownReference matches markdown images and links with their
+// destination, plain or in <>
+var markdownReference = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*(<[^>]*>|[^)\s]+)([^)]*)\)`)
+
+// ankiAudio lists the sound files Anki plays with [sound:...]
+var ankiAudio = map[string]bool{".mp3": true, ".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".m4a": true, ".flac": true}
+
+// markdown renders card text for Anki: raw HTML in cards is kept, as Anki
+// fields are HTML anyway
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(gmhtml.WithUnsafe()),
+)
+
+// html converts a card's markdown to HTML for an Anki field, bundling the
+// local images and sounds it references
+func (e *ankiExporter) html(card *Card, text string) string {
+	text = markdownReference.ReplaceAllStringFunc(text, func(match string) string {
+		parts := markdownReference.FindStringSubmatch(match)
+		image, label, dest, title := parts[1] == "!", parts[2], strings.Trim(parts[3], "<>"), parts[4]
+		if strings.Contains(dest, "://") || strings.HasPrefix(dest, "data:") || strings.HasPrefix(dest, "mailto:") {
+			return match
+		}
+		if !image && !ankiAudio[strings.ToLower(filepath.Ext(dest))] {
+			return match
+		}
+
+		name, err := e.bundle(card, dest)
+		if err != nil {
+			e.result.Warnings = append(e.result.Warnings, fmt.Sprintf("%s: %v", card.FilePath, err))
+			return match
+		}
+		if !image {
+			return "[sound:" + name + "]"
+		}
+		return fmt.Sprintf("![%s](%s%s)", label, linkDestination(name), title)
+	})
+
+	var out bytes.Buffer
+	if err := markdown.Convert([]byte(text), &out); err != nil {
+		return text
+	}
+	html := strings.TrimSpace(out.String())
+
+	// A lone paragraph doesn't need its tags
+	if strings.HasPrefix(html, "<p>") && strings.HasSuffix(html, "</p>") && strings.Count(html, "<p>") == 1 {
+		html = html[len("<p>") : len(html)-len("</p>")]
+	}
+	return html
+}
+
+// bundle adds a media file referenced from a card to the package, returning
+// its name there. Anki keeps media in one directory, so files of the same
+// name from different places get numbered.
+func (e *ankiExporter) bundle(card *Card, dest string) (string, error) {
+	if unescaped, err := url.PathUnescape(dest); err == nil {
+		dest = unescaped
+	}
+	source := filepath.FromSlash(dest)
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(card.FilePath), source)
+	}
+	if name, ok := e.media[source]; ok {
+		return name, nil
+	}
+	if info, err := os.Stat(source); err != nil || info.IsDir() {
+		return "", fmt.Errorf("media file %s not found", dest)
+	}
+
+	base := filepath.Base(source)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 2; e.mediaNames[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+	e.media[source] = name
+	e.mediaNames[name] = true
+	return name, nil
+}
+
+// collection builds the collection database
+func (e *ankiExporter) collection() ([]byte, error) {
+	field := func(name string, ord int) map[string]interface{} {
+		return map[string]interface{}{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+	}
+	template := func(name string, ord int, question, answer string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "ord": ord, "qfmt": question, "afmt": answer, "bqfmt": "", "bafmt": "", "did": nil, "bfont": "", "bsize": 0}
+	}
+	model := func(id int64, name string, kind int, fields []string, templates []map[string]interface{}, req []interface{}) map[string]interface{} {
+		var flds []map[string]interface{}
+		for i, f := range fields {
+			flds = append(flds, field(f, i))
+		}
+		return map[string]interface{}{
+			"id": id, "name": name, "type": kind, "mod": e.now.Unix(), "usn": -1, "sortf": 0, "did": 1,
+			"flds": flds, "tmpls": templates, "css": ankiCSS, "req": req, "tags": []string{}, "vers": []string{},
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}", "latexsvg": false,
+		}
+	}
+	const answerRule = "{{FrontSide}}\n\n<hr id=answer>\n\n"
+	models := map[string]interface{}{
+		strconv.FormatInt(ankiBasicModel, 10): model(ankiBasicModel, "srs Basic", 0, []string{"Front", "Back"},
+			[]map[string]interface{}{template("Card 1", 0, "{{Front}}", answerRule+"{{Back}}")},
+			[]interface{}{[]interface{}{0, "any", []int{0}}}),
+		strconv.FormatInt(ankiReversedModel, 10): model(ankiReversedModel, "srs Basic (and reversed card)", 0, []string{"Front", "Back"},
+			[]map[string]interface{}{template("Card 1", 0, "{{Front}}", answerRule+"{{Back}}"), template("Card 2", 1, "{{Back}}", answerRule+"{{Front}}")},
+			[]interface{}{[]interface{}{0, "any", []int{0}}, []interface{}{1, "any", []int{1}}}),
+		strconv.FormatInt(ankiClozeModel, 10): model(ankiClozeModel, "srs Cloze", ankiCloze, []string{"Text", "Back Extra"},
+			[]map[string]interface{}{template("Cloze", 0, "{{cloze:Text}}", "{{cloze:Text}}<br>\n{{Back Extra}}")},
+			[]interface{}{}),
+	}
+
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": e.now.Unix(), "usn": -1, "desc": "", "dyn": 0, "conf": 1,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 0, "extendRev": 0,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	decks := map[string]interface{}{"1": deck(1, "Default")}
+	for name, id := range e.decks {
+		decks[strconv.FormatInt(id, 10)] = deck(id, name)
+	}
+
+	options := map[string]interface{}{"1": map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new":   map[string]interface{}{"bury": false, "delays": []int{1, 10}, "initialFactor": 2500, "ints": []int{1, 4, 0}, "order": 1, "perDay": 20},
+		"lapse": map[string]interface{}{"delays": []int{10}, "leechAction": 1, "leechFails": 8, "minInt": 1, "mult": 0},
+		"rev":   map[string]interface{}{"bury": false, "ease4": 1.3, "ivlFct": 1, "maxIvl": 36500, "perDay": 200, "hardFactor": 1.2},
+	}}
+	conf := map[string]interface{}{
+		"activeDecks": []int{1}, "curDeck": 1, "newSpread": 0, "collapseTime": 1200, "timeLim": 0,
+		"estTimes": true, "dueCounts": true, "curModel": nil, "nextPos": len(e.notes) + 1, "sortType": "noteFld",
+		"sortBackwards": false, "addToCur": true,
+	}
+
+	var encoded []string
+	for _, v := range []interface{}{conf, models, decks, options} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, string(data))
+	}
+
+	tables := make([]sqlite.Table, len(ankiSchema))
+	copy(tables, ankiSchema)
+	tables[0].Rows = [][]interface{}{{
+		1, e.created.Unix(), e.now.UnixMilli(), e.now.UnixMilli(), 11, 0, 0, 0,
+		encoded[0], encoded[1], encoded[2], encoded[3], "{}",
+	}}
+	tables[1].Rows = e.notes
+	tables[2].Rows = e.cards
+	tables[3].Rows = e.revlog
+	return sqlite.Write(tables)
+}
+
+// writePackage zips the collection and media into an .apkg file. Media is
+// stored as numbered files, named by the media list.
+func (e *ankiExporter) writePackage(path string, collection []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+
+	write := func(name string, data []byte) error {
+		entry, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(data)
+		return err
+	}
+
+	sources := make([]string, 0, len(e.media))
+	for source := range e.media {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	err = write("collection.anki2", collection)
+	names := make(map[string]string)
+	for _, source := range sources {
+		if err != nil {
+			break
+		}
+		var data []byte
+		if data, err = os.ReadFile(source); err == nil {
+			key := strconv.Itoa(len(names))
+			names[key] = e.media[source]
+			err = write(key, data)
+		}
+	}
+	if err == nil {
+		var list []byte
+		if list, err = json.Marshal(names); err == nil {
+			err = write("media", list)
+		}
+	}
+	e.result.Media = len(names)
+
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
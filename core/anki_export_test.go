@@ -0,0 +1,125 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestExportAnki(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "go")
+	InitDeckRoot(root)
+	writeFile(filepath.Join(root, "chan.md"), "What is a *channel*?\n---\nA typed pipe ![diagram](img/chan.png)\n\n#go/concurrency")
+	os.MkdirAll(filepath.Join(root, "img"), 0755)
+	writeFile(filepath.Join(root, "img", "chan.png"), "PNG")
+	os.MkdirAll(filepath.Join(root, "basics"), 0755)
+	writeFile(filepath.Join(root, "basics", "nil.md"), "---\nreverse: true\n---\n\nnil\n---\nzero value of pointers")
+	writeFile(filepath.Join(root, "basics", "defer.md"), "{{c1::defer}} runs at {{c2::return}}\n---\nLIFO order\n\n#suspended")
+
+	// Review the channel card so it has a state and history to export
+	card, _ := ParseCard(filepath.Join(root, "chan.md"))
+	reviewed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	info := card.Scheduler().Repeat(card.FSRSCard, reviewed)[fsrs.Good]
+	card.FSRSCard = info.Card
+	card.UpdateFSRSMetadata()
+	AppendReview(root, NewReviewRecord(card.Key(), info.ReviewLog))
+
+	cards, err := FindCards(root)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "go.apkg")
+	result, err := ExportAnki(cards, root, path, true)
+	if err != nil {
+		t.Fatalf("ExportAnki failed: %v", err)
+	}
+	if result.Notes != 3 || result.Cards != 5 || result.Reviews != 1 || result.Media != 1 || len(result.Warnings) != 0 {
+		t.Errorf("Unexpected export summary: %+v", result)
+	}
+
+	col, err := readAnkiPackage(path)
+	if err != nil {
+		t.Fatalf("Failed to read the package back: %v", err)
+	}
+	defer col.Close()
+
+	decks := make(map[string]bool)
+	for _, name := range col.decks {
+		decks[name] = true
+	}
+	if !decks["go"] || !decks["go::basics"] {
+		t.Errorf("Expected the subdirectory as a subdeck, got %v", col.decks)
+	}
+	if col.media["chan.png"] == nil {
+		t.Error("Expected the image to be bundled")
+	}
+
+	for _, note := range col.notes {
+		fields := strings.Split(note.Text("flds"), "\x1f")
+		cards := col.cards[note.Int("id")]
+		switch {
+		case strings.Contains(fields[0], "channel"):
+			if fields[0] != "What is a <em>channel</em>?" || fields[1] != `A typed pipe <img src="chan.png" alt="diagram">` {
+				t.Errorf("Unexpected fields: %q", fields)
+			}
+			if note.Text("tags") != " go::concurrency " {
+				t.Errorf("Expected the tag hierarchy in Anki's form, got %q", note.Text("tags"))
+			}
+			if cards[0].Int("type") != ankiLearning || cards[0].Text("data") == "" || len(col.reviews[cards[0].Int("id")]) != 1 {
+				t.Errorf("Expected a learning card with its memory state and review, got %v", cards[0])
+			}
+		case strings.Contains(fields[0], "defer"):
+			if note.Int("mid") != ankiClozeModel || fields[0] != "{{c1::defer}} runs at {{c2::return}}" || fields[1] != "LIFO order" {
+				t.Errorf("Unexpected cloze note: %q", fields)
+			}
+			if len(cards) != 2 || cards[1].Int("ord") != 1 || cards[0].Int("queue") != ankiSuspended {
+				t.Errorf("Expected two suspended cloze cards, got %v", cards)
+			}
+		default:
+			if note.Int("mid") != ankiReversedModel || len(cards) != 2 || fields[0] != "nil" {
+				t.Errorf("Unexpected reversible note: %q with %d cards", fields, len(cards))
+			}
+		}
+	}
+
+	// The package imports back into the same cards
+	copied := t.TempDir()
+	InitDeckRoot(copied)
+	imported, err := ImportAnki(path, copied)
+	if err != nil {
+		t.Fatalf("ImportAnki failed: %v", err)
+	}
+	if imported.Notes != 3 || imported.Cards != 5 || imported.Reviews != 1 {
+		t.Errorf("Unexpected import of the export: %+v", imported)
+	}
+	again, _ := FindCards(copied)
+	for _, card := range cards {
+		if FindDuplicate(again, card.Question) == nil {
+			t.Errorf("Expected %q to survive the round trip", card.Question)
+		}
+	}
+
+	// Without scheduling every card starts over
+	if _, err := ExportAnki(cards, root, path, false); err != nil {
+		t.Fatalf("ExportAnki failed: %v", err)
+	}
+	fresh, err := readAnkiPackage(path)
+	if err != nil {
+		t.Fatalf("Failed to read the package back: %v", err)
+	}
+	defer fresh.Close()
+	for _, cards := range fresh.cards {
+		for _, card := range cards {
+			if card.Int("type") != 0 {
+				t.Errorf("Expected only new cards, got %v", card)
+			}
+		}
+	}
+	if len(fresh.reviews) != 0 {
+		t.Error("Expected no review log without scheduling")
+	}
+}
@@ -0,0 +1,294 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// ankiSound matches Anki's [sound:file.mp3] media references
+var ankiSound = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+
+// whitespace matches the runs of whitespace HTML renders as one space
+var whitespace = regexp.MustCompile(`\s+`)
+
+// markdownEscaper escapes the characters in Anki text that markdown would
+// otherwise read as emphasis or code
+var markdownEscaper = strings.NewReplacer(`*`, `\*`, `_`, `\_`, "`", "\\`")
+
+// htmlToMarkdown converts the HTML of an Anki field to markdown. Media file
+// names in images and sounds are passed through media, which returns the
+// path to link to.
+func htmlToMarkdown(source string, media func(name string) string) string {
+	source = ankiSound.ReplaceAllStringFunc(source, func(match string) string {
+		name := ankiSound.FindStringSubmatch(match)[1]
+		return fmt.Sprintf(`<a href="%s">🔊 %s</a>`, html.EscapeString(name), html.EscapeString(name))
+	})
+
+	var out strings.Builder
+	var lists []int // open lists: -1 for bullets, else the last item number
+	var links []string
+	pre, skip := 0, 0
+
+	block := func() {
+		out.WriteString("\n\n")
+	}
+
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(source))
+	for {
+		kind := tokenizer.Next()
+		if kind == nethtml.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		if skip > 0 && !(kind == nethtml.EndTagToken && (token.Data == "script" || token.Data == "style")) {
+			continue
+		}
+
+		switch kind {
+		case nethtml.TextToken:
+			text := strings.ReplaceAll(token.Data, "\u00a0", " ")
+			if pre > 0 {
+				out.WriteString(text)
+				continue
+			}
+			text = whitespace.ReplaceAllString(text, " ")
+			if current := out.String(); current == "" || strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n") {
+				text = strings.TrimLeft(text, " ")
+			}
+			out.WriteString(markdownEscaper.Replace(text))
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			switch token.Data {
+			case "script", "style":
+				if kind == nethtml.StartTagToken {
+					skip++
+				}
+			case "br":
+				out.WriteString("\n")
+			case "p", "div", "table", "blockquote":
+				block()
+			case "tr":
+				out.WriteString("\n")
+			case "td", "th":
+				out.WriteString(" | ")
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				block()
+				out.WriteString(strings.Repeat("#", int(token.Data[1]-'0')) + " ")
+			case "hr":
+				block()
+				out.WriteString("***")
+				block()
+			case "b", "strong":
+				out.WriteString("**")
+			case "i", "em":
+				out.WriteString("*")
+			case "s", "del", "strike":
+				out.WriteString("~~")
+			case "code":
+				if pre == 0 {
+					out.WriteString("`")
+				}
+			case "pre":
+				block()
+				out.WriteString("```\n")
+				pre++
+			case "ul":
+				lists = append(lists, -1)
+				out.WriteString("\n")
+			case "ol":
+				lists = append(lists, 0)
+				out.WriteString("\n")
+			case "li":
+				out.WriteString("\n")
+				if len(lists) == 0 {
+					out.WriteString("- ")
+					continue
+				}
+				out.WriteString(strings.Repeat("  ", len(lists)-1))
+				if top := len(lists) - 1; lists[top] >= 0 {
+					lists[top]++
+					fmt.Fprintf(&out, "%d. ", lists[top])
+				} else {
+					out.WriteString("- ")
+				}
+			case "a":
+				href := attribute(token, "href")
+				if href != "" && !strings.Contains(href, "://") && !strings.HasPrefix(href, "mailto:") {
+					href = media(href)
+				}
+				links = append(links, href)
+				if href != "" {
+					out.WriteString("[")
+				}
+			case "img":
+				if src := attribute(token, "src"); src != "" {
+					fmt.Fprintf(&out, "![%s](%s)", attribute(token, "alt"), linkDestination(media(src)))
+				}
+			}
+
+		case nethtml.EndTagToken:
+			switch token.Data {
+			case "script", "style":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "table", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6":
+				block()
+			case "b", "strong":
+				out.WriteString("**")
+			case "i", "em":
+				out.WriteString("*")
+			case "s", "del", "strike":
+				out.WriteString("~~")
+			case "code":
+				if pre == 0 {
+					out.WriteString("`")
+				}
+			case "pre":
+				if pre > 0 {
+					pre--
+					if !strings.HasSuffix(out.String(), "\n") {
+						out.WriteString("\n")
+					}
+					out.WriteString("```")
+					block()
+				}
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				if len(lists) == 0 {
+					block()
+				}
+			case "a":
+				if len(links) > 0 {
+					href := links[len(links)-1]
+					links = links[:len(links)-1]
+					if href != "" {
+						fmt.Fprintf(&out, "](%s)", linkDestination(href))
+					}
+				}
+			}
+		}
+	}
+
+	return tidyMarkdown(out.String())
+}
+
+// attribute returns the value of an HTML tag's attribute
+func attribute(token nethtml.Token, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// linkDestination wraps link targets with spaces or parentheses in <>
+func linkDestination(target string) string {
+	if strings.ContainsAny(target, " ()") {
+		return "<" + target + ">"
+	}
+	return target
+}
+
+// tidyMarkdown trims trailing spaces and runs of blank lines, and escapes
+// lines srs would read as card structure: --- and === separators and lines
+// of #tags
+func tidyMarkdown(text string) string {
+	var lines []string
+	blank := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "---" || trimmed == cardSeparator {
+			line = `\` + trimmed
+		} else if _, ok := parseTagLine(line); ok {
+			line = `\` + strings.TrimLeft(line, " ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// ankiField matches a field reference in an Anki card template, such as
+// {{Front}}, {{cloze:Text}} or a {{#Back}} ... {{/Back}} section
+var ankiField = regexp.MustCompile(`\{\{([#^/]?)([^{}]*)\}\}`)
+
+// renderAnkiTemplate fills in an Anki card template with a note's fields.
+// Sections are kept only when their field is non-empty ({{#Field}}) or empty
+// ({{^Field}}). Filters are dropped, keeping the field, except tts ones and
+// type: ones on the question side, which have no equivalent. On the answer
+// side a type: field shows the expected answer.
+func renderAnkiTemplate(template string, fields map[string]string, frontSide string, answer bool) string {
+	// Resolve sections innermost first
+	for {
+		matches := ankiField.FindAllStringSubmatchIndex(template, -1)
+		resolved := false
+		for i := len(matches) - 1; i >= 0 && !resolved; i-- {
+			m := matches[i]
+			kind, name := template[m[2]:m[3]], strings.TrimSpace(template[m[4]:m[5]])
+			if kind != "#" && kind != "^" {
+				continue
+			}
+			closing := "{{/" + name + "}}"
+			end := strings.Index(template[m[1]:], closing)
+			if end < 0 {
+				continue
+			}
+			end += m[1]
+
+			present := strings.TrimSpace(stripHTML(fields[name])) != ""
+			body := ""
+			if present == (kind == "#") {
+				body = template[m[1]:end]
+			}
+			template = template[:m[0]] + body + template[end+len(closing):]
+			resolved = true
+		}
+		if !resolved {
+			break
+		}
+	}
+
+	return ankiField.ReplaceAllStringFunc(template, func(match string) string {
+		parts := ankiField.FindStringSubmatch(match)
+		if parts[1] != "" {
+			return "" // stray section tags
+		}
+		name := strings.TrimSpace(parts[2])
+		if name == "FrontSide" {
+			return frontSide
+		}
+		filters := strings.Split(name, ":")
+		name = filters[len(filters)-1]
+		for _, filter := range filters[:len(filters)-1] {
+			if (filter == "type" && !answer) || strings.HasPrefix(filter, "tts") {
+				return ""
+			}
+		}
+		return fields[name]
+	})
+}
+
+// ankiTag matches HTML tags, for stripHTML
+var ankiTag = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML reduces HTML to its text
+func stripHTML(s string) string {
+	return html.UnescapeString(ankiTag.ReplaceAllString(s, ""))
+}
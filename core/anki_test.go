@@ -0,0 +1,173 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestImportAnki(t *testing.T) {
+	root := t.TempDir()
+	InitDeckRoot(root)
+
+	result, err := ImportAnki("testdata/anki/sample.apkg", root)
+	if err != nil {
+		t.Fatalf("ImportAnki failed: %v", err)
+	}
+	if result.Notes != 3 || result.Cards != 5 || result.Reviews != 2 || result.Media != 1 || len(result.Warnings) != 0 {
+		t.Errorf("Unexpected import summary: %+v", result)
+	}
+
+	cards, err := FindCards(root)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	if len(cards) != 5 {
+		t.Fatalf("Expected 5 cards, got %d", len(cards))
+	}
+
+	basic := FindDuplicate(cards, "What does the **heart** pump?")
+	if basic == nil {
+		t.Fatal("Expected the basic note with its bold converted")
+	}
+	if basic.Answer != "Blood\n![](media/heart.png)" {
+		t.Errorf("Unexpected answer: %q", basic.Answer)
+	}
+	if !reflect.DeepEqual(basic.Tags, []string{"biology", "anki/imported"}) {
+		t.Errorf("Unexpected tags: %v", basic.Tags)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, AnkiMediaDir, "heart.png")); err != nil || string(data) != "PNG" {
+		t.Errorf("Expected the image to be copied: %v", err)
+	}
+	if basic.FSRSCard.Reps != 2 || basic.FSRSCard.State != fsrs.Review || !basic.FSRSCard.LastReview.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the review log to be replayed, got %+v", basic.FSRSCard)
+	}
+
+	history, err := LoadHistory(root)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(history[basic.Key()]) != 2 {
+		t.Errorf("Expected 2 journaled reviews, got %d", len(history[basic.Key()]))
+	}
+
+	var forward, reverse *Card
+	for _, card := range cards {
+		if filepath.Dir(card.FilePath) == filepath.Join(root, "languages", "spanish") {
+			if card.Reverse {
+				reverse = card
+			} else {
+				forward = card
+			}
+		}
+	}
+	if forward == nil || reverse == nil {
+		t.Fatal("Expected a reversible card in the Languages::Spanish subdeck")
+	}
+	if forward.Question != "perro" || forward.Answer != "dog" {
+		t.Errorf("Unexpected reversible card: %q / %q", forward.Question, forward.Answer)
+	}
+	if forward.FSRSCard.State != fsrs.Review || forward.FSRSCard.ScheduledDays != 10 || forward.FSRSCard.Lapses != 1 ||
+		!forward.FSRSCard.Due.Equal(time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the Anki interval without a review log, got %+v", forward.FSRSCard)
+	}
+	if reverse.FSRSCard.State != fsrs.New {
+		t.Errorf("Expected the new reverse card to stay new, got %v", reverse.FSRSCard.State)
+	}
+
+	clozes := 0
+	for _, card := range cards {
+		if card.Cloze > 0 {
+			clozes++
+			if !strings.HasSuffix(card.Answer, "\n\nCells have many.") || !reflect.DeepEqual(card.Tags, []string{"suspended"}) {
+				t.Errorf("Unexpected cloze card: %q %v", card.Answer, card.Tags)
+			}
+		}
+	}
+	if clozes != 2 {
+		t.Errorf("Expected 2 cloze cards, got %d", clozes)
+	}
+
+	// Suspended cards stay out of reviews even though they're new
+	for _, card := range GetDueCards(cards) {
+		if card.Cloze > 0 {
+			t.Errorf("Expected the suspended cloze card %s not to be due", card.Name())
+		}
+	}
+	if due := GetDueCards(cards); len(due) != 3 {
+		t.Errorf("Expected the other 3 cards to be due, got %d", len(due))
+	}
+
+	again, err := ImportAnki("testdata/anki/sample.apkg", root)
+	if err != nil {
+		t.Fatalf("ImportAnki failed again: %v", err)
+	}
+	if again.Notes != 0 || again.Skipped != 3 {
+		t.Errorf("Expected importing again to skip every note, got %+v", again)
+	}
+
+	if _, err := ImportAnki("testdata/anki/sample.sql", root); err == nil {
+		t.Error("Expected a file that isn't a package to be refused")
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	media := func(name string) string { return "media/" + name }
+	tests := []struct {
+		html, want string
+	}{
+		{"Hello&nbsp;<b>bold</b> and <i>it</i>", "Hello **bold** and *it*"},
+		{"line one<br>line two<div>block</div>", "line one\nline two\n\nblock"},
+		{"<ul><li>a</li><li>b</li></ul>", "- a\n- b"},
+		{"<ol><li>a</li><li>b</li></ol>", "1. a\n2. b"},
+		{`<img src="a b.png" alt="x">`, "![x](<media/a b.png>)"},
+		{`<a href="https://example.com">site</a>`, "[site](https://example.com)"},
+		{"[sound:hola.mp3]", "[🔊 hola.mp3](media/hola.mp3)"},
+		{"2 * 3 = 6_x", `2 \* 3 = 6\_x`},
+		{"---", `\---`},
+		{"#tag", `\#tag`},
+		{"<style>.x {}</style>text", "text"},
+		{"<pre>a  *b*</pre>", "```\na  *b*\n```"},
+	}
+
+	for _, tt := range tests {
+		if got := htmlToMarkdown(tt.html, media); got != tt.want {
+			t.Errorf("htmlToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestRenderAnkiTemplate(t *testing.T) {
+	fields := map[string]string{"Front": "perro", "Back": "dog", "Hint": ""}
+	tests := []struct {
+		template string
+		answer   bool
+		want     string
+	}{
+		{"{{Front}}", false, "perro"},
+		{"{{#Hint}}hint: {{Hint}}{{/Hint}}{{^Hint}}no hint{{/Hint}}", false, "no hint"},
+		{"{{#Back}}<i>{{text:Back}}</i>{{/Back}}", false, "<i>dog</i>"},
+		{"{{Front}} {{type:Back}}", false, "perro "},
+		{"{{FrontSide}}<hr id=answer>{{type:Back}}", true, "F<hr id=answer>dog"},
+		{"{{tts en_US:Front}}{{Missing}}", false, ""},
+	}
+
+	for _, tt := range tests {
+		if got := renderAnkiTemplate(tt.template, fields, "F", tt.answer); got != tt.want {
+			t.Errorf("renderAnkiTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestAnkiTags(t *testing.T) {
+	got := ankiTags(" Spanish::Verbs leech  c++ ::odd spanish::verbs ")
+	want := []string{"spanish/verbs", "leech", "c--", "odd"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ankiTags = %v, want %v", got, want)
+	}
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,33 +12,194 @@ import (
 	"github.com/open-spaced-repetition/go-fsrs/v3"
 )
 
-// ParseCard reads and parses a markdown card file
+// ParseCard reads and parses a markdown card file, including its review
+// history and deck settings. For files holding several cards, the first card
+// is returned; use ParseCards to get all of them.
 func ParseCard(filePath string) (*Card, error) {
-	file, err := os.Open(filePath)
+	cards, err := ParseCards(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no cards in %s", filePath)
+	}
+	return cards[0], nil
+}
+
+// ParseCards reads every card in a markdown card file, including their review
+// history and deck settings
+func ParseCards(filePath string) ([]*Card, error) {
+	cards, err := parseCardFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := FindDeckRoot(filepath.Dir(filePath))
+	settings, err := EffectiveSettings(root, filepath.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var history map[string][]ReviewRecord
+	if root != "" {
+		history, err = LoadHistory(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, card := range cards {
+		card.Root = root
+		card.Settings = settings
+		if history != nil {
+			card.attachHistory(history)
+		}
+	}
+
+	return cards, nil
+}
+
+// ReloadCard re-reads a card from disk, e.g. after it has been edited,
+// matching it by ID within its file
+func ReloadCard(card *Card) (*Card, error) {
+	cards, err := ParseCards(card.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.ID != "" {
+		for _, reloaded := range cards {
+			if reloaded.ID == card.ID {
+				return reloaded, nil
+			}
+		}
+	}
+	for _, reloaded := range cards {
+		if reloaded.samePosition(card) {
+			return reloaded, nil
+		}
+	}
+	for _, reloaded := range cards {
+		if reloaded.Block == card.Block {
+			return reloaded, nil
+		}
+	}
+
+	return nil, fmt.Errorf("card %s no longer exists in %s", card.Key(), card.FilePath)
+}
+
+// samePosition reports whether two cards of a file are in the same block with
+// the same cloze index and direction
+func (c *Card) samePosition(other *Card) bool {
+	return c.Block == other.Block && c.Cloze == other.Cloze && c.Reverse == other.Reverse
+}
+
+// cardSeparator on a line of its own, after a blank line, splits a file into
+// several cards. Requiring the blank line keeps setext headings working.
+const cardSeparator = "==="
+
+// blockRanges returns the [start, end) line range of each card block in a file
+func blockRanges(lines []string) [][2]int {
+	var ranges [][2]int
+	start := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == cardSeparator && (i == 0 || strings.TrimSpace(lines[i-1]) == "") {
+			ranges = append(ranges, [2]int{start, i})
+			start = i + 1
+		}
+	}
+	return append(ranges, [2]int{start, len(lines)})
+}
+
+// readLines reads a file as lines, tolerating Windows line endings
+func readLines(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), nil
+}
+
+// isMetadataLine reports whether a line is a <!-- FSRS: ... --> comment
+func isMetadataLine(line string) bool {
+	return strings.HasPrefix(line, "<!-- FSRS:") && strings.HasSuffix(strings.TrimSpace(line), "-->")
+}
+
+// parseCardFile parses the cards in a file, without consulting the journal
+func parseCardFile(filePath string) ([]*Card, error) {
+	lines, err := readLines(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var modTime time.Time
+	if fileInfo, err := os.Stat(filePath); err == nil {
+		modTime = fileInfo.ModTime()
+	}
+
+	front := parseFrontMatter(lines)
+
+	lines = blankFrontMatter(lines)
+	ranges := blockRanges(lines)
+	var cards []*Card
+	for i, r := range ranges {
+		for _, card := range parseCardBlock(lines[r[0]:r[1]], i, front) {
+			card.FilePath = filePath
+			card.MultiCard = len(ranges) > 1
+			card.LastModified = modTime
+			cards = append(cards, card)
+		}
+	}
+
+	// Empty files are still a (blank) card, as they always have been
+	if len(cards) == 0 && len(ranges) == 1 {
+		cards = append(cards, &Card{
+			FilePath:     filePath,
+			FSRSCard:     fsrs.NewCard(),
+			LastModified: modTime,
+		})
+	}
+
+	return cards, nil
+}
 
+// parseCardBlock parses one block's lines into its cards, returning nil for a
+// block with no content in a multi-card file. A block holds one card, plus its
+// reverse if requested, or one card per cloze index if it contains cloze
+// deletions.
+func parseCardBlock(lines []string, block int, front frontMatter) []*Card {
 	var question, answer strings.Builder
-	var fsrsMetadata string
-	scanner := bufio.NewScanner(file)
-	
+	var metadata []map[string]string
+	tags := mergeTags(nil, front.List("tags")...)
 	inAnswer := false
-	
-	for scanner.Scan() {
-		line := scanner.Text()
-		
-		if strings.HasPrefix(line, "<!-- FSRS:") && strings.HasSuffix(line, "-->") {
-			fsrsMetadata = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(line, "-->"), "<!-- FSRS:"))
+	inCode := false
+	hasContent := false
+
+	for _, line := range lines {
+		if isMetadataLine(line) {
+			metadata = append(metadata, metadataFields(line[len("<!-- FSRS:"):]))
 			continue
 		}
-		
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+		if !inCode {
+			if lineTags, ok := parseTagLine(line); ok {
+				tags = mergeTags(tags, lineTags...)
+				continue
+			}
+		}
+
+		if strings.TrimSpace(line) != "" {
+			hasContent = true
+		}
+
 		if line == "---" && !inAnswer {
 			inAnswer = true
 			continue
 		}
-		
+
 		if inAnswer {
 			answer.WriteString(line + "\n")
 		} else {
@@ -47,61 +207,172 @@ func ParseCard(filePath string) (*Card, error) {
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if !hasContent && len(metadata) == 0 {
+		return nil
 	}
 
-	card := &Card{
-		Question: strings.TrimSpace(question.String()),
-		Answer:   strings.TrimSpace(answer.String()),
-		FilePath: filePath,
-	}
+	text := strings.TrimSpace(question.String())
+	extra := strings.TrimSpace(answer.String())
 
-	if fsrsMetadata != "" {
-		card.FSRSCard = parseFSRSMetadata(fsrsMetadata)
+	var cards []*Card
+	if indices := clozeIndices(text); len(indices) > 0 {
+		for _, index := range indices {
+			cards = append(cards, &Card{
+				Question: clozeQuestion(text, index),
+				Answer:   clozeAnswer(text, extra, index),
+				Cloze:    index,
+				Text:     text,
+			})
+		}
 	} else {
+		cards = append(cards, &Card{Question: text, Answer: extra})
+		if front.Bool("reverse") {
+			cards = append(cards, &Card{Question: extra, Answer: text, Reverse: true})
+		}
+	}
+
+	// Front matter states take precedence over legacy comments
+	for _, card := range cards {
+		card.Block = block
+		card.Tags = tags
 		card.FSRSCard = fsrs.NewCard()
+		for _, fields := range metadata {
+			if card.ownsMetadata(fields) {
+				card.applyMetadata(fields)
+			}
+		}
+		for _, fields := range front.states() {
+			if card.ownsState(fields) {
+				card.applyMetadata(fields)
+			}
+		}
 	}
 
-	fileInfo, err := os.Stat(filePath)
-	if err == nil {
-		card.LastModified = fileInfo.ModTime()
+	return cards
+}
+
+// ownsMetadata reports whether a metadata comment in the card's block belongs
+// to the card: by ID if it has one, otherwise by cloze index and direction
+func (c *Card) ownsMetadata(fields map[string]string) bool {
+	if id := fields["id"]; id != "" && c.ID != "" {
+		return id == c.ID
 	}
 
-	return card, nil
+	cloze, _ := strconv.Atoi(fields["cloze"])
+	return cloze == c.Cloze && frontMatterBool(fields["reverse"]) == c.Reverse
+}
+
+// ownsState reports whether a front-matter state belongs to the card. These
+// are shared by the whole file, so without an ID the block must match too.
+func (c *Card) ownsState(fields map[string]string) bool {
+	if id := fields["id"]; id != "" && c.ID != "" {
+		return id == c.ID
+	}
+
+	block, _ := strconv.Atoi(fields["block"])
+	return block == c.Block && c.ownsMetadata(fields)
+}
+
+// applyMetadata loads the card's ID and FSRS state from metadata fields
+func (c *Card) applyMetadata(fields map[string]string) {
+	c.ID = fields["id"]
+	c.FSRSCard = fsrsCardFromFields(fields)
+}
+
+// Name returns the card's display name: its file name, numbered within
+// multi-card files and labelled with its cloze index or direction
+func (c *Card) Name() string {
+	name := strings.TrimSuffix(filepath.Base(c.FilePath), filepath.Ext(c.FilePath))
+	if c.MultiCard {
+		name = fmt.Sprintf("%s #%d", name, c.Block+1)
+	}
+	if c.Cloze > 0 {
+		name = fmt.Sprintf("%s (cloze %d)", name, c.Cloze)
+	}
+	if c.Reverse {
+		name += " (reverse)"
+	}
+	return name
 }
 
 // FindCards recursively finds all markdown cards in a directory
 func FindCards(deckPath string) ([]*Card, error) {
 	var cards []*Card
+	root := FindDeckRoot(deckPath)
+	dirSettings := make(map[string]DeckSettings)
 	
 	err := filepath.Walk(deckPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
-			card, err := ParseCard(path)
+		if info.IsDir() {
+			// Skip srs's own data directory
+			if info.Name() == DataDirName {
+				return filepath.SkipDir
+			}
+			
+			// Settings are inherited from the parent directory, or from every
+			// ancestor up to the deck root for the starting directory
+			var settings DeckSettings
+			if path == deckPath {
+				settings, err = EffectiveSettings(root, path)
+			} else {
+				var own DeckSettings
+				own, err = LoadDeckSettings(path)
+				settings = dirSettings[filepath.Dir(path)].Merge(own)
+			}
+			if err != nil {
+				return err
+			}
+			dirSettings[path] = settings
+			return nil
+		}
+		
+		if strings.HasSuffix(strings.ToLower(path), ".md") {
+			fileCards, err := parseCardFile(path)
 			if err != nil {
 				fmt.Printf("Warning: failed to parse card %s: %v\n", path, err)
 				return nil
 			}
-			cards = append(cards, card)
+			for _, card := range fileCards {
+				card.Settings = dirSettings[filepath.Dir(path)]
+				cards = append(cards, card)
+			}
 		}
 		
 		return nil
 	})
+	if err != nil {
+		return cards, err
+	}
 	
-	return cards, err
+	// Load the journal once for the whole deck rather than once per card
+	if root == "" {
+		return cards, nil
+	}
+	
+	history, err := LoadHistory(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review history: %v", err)
+	}
+	
+	for _, card := range cards {
+		card.Root = root
+		card.attachHistory(history)
+	}
+	
+	return cards, nil
 }
 
-// GetDueCards filters cards that are due for review
+// GetDueCards filters cards that are due for review, leaving out suspended
+// cards
 func GetDueCards(cards []*Card) []*Card {
 	now := time.Now()
 	var dueCards []*Card
 	
 	for _, card := range cards {
-		if card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now) {
+		if (card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now)) && !card.Suspended() {
 			dueCards = append(dueCards, card)
 		}
 	}
@@ -109,54 +380,153 @@ func GetDueCards(cards []*Card) []*Card {
 	return dueCards
 }
 
-// UpdateFSRSMetadata writes the FSRS metadata back to the card file
+// UpdateFSRSMetadata writes the FSRS metadata back to the card file, in the
+// format chosen with SetMetadataFormat. In multi-card files only the card's
+// own block is rewritten.
 func (c *Card) UpdateFSRSMetadata() error {
+	return c.WriteMetadata(metadataFormat)
+}
+
+// WriteMetadata writes the FSRS metadata back to the card file in the given
+// format, removing any copy stored in the other format. A card without an ID
+// gets one here.
+func (c *Card) WriteMetadata(format MetadataFormat) error {
 	content, err := os.ReadFile(c.FilePath)
 	if err != nil {
 		return err
 	}
+	c.assignID()
 
 	lines := strings.Split(string(content), "\n")
-	
-	// Remove existing FSRS metadata
-	var filteredLines []string
-	for _, line := range lines {
-		if !strings.HasPrefix(line, "<!-- FSRS:") {
-			filteredLines = append(filteredLines, line)
+	if format == FormatYAML {
+		lines = c.writeFrontMatterState(c.removeComment(lines), true)
+	} else {
+		lines = c.writeComment(c.writeFrontMatterState(lines, false))
+	}
+
+	return os.WriteFile(c.FilePath, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// removeComment removes the card's metadata comment from its block
+func (c *Card) removeComment(lines []string) []string {
+	start, end := c.findBlock(lines)
+
+	result := append([]string(nil), lines[:start]...)
+	for _, line := range lines[start:end] {
+		trimmed := strings.TrimSuffix(line, "\r")
+		if isMetadataLine(trimmed) && c.ownsMetadata(metadataFields(trimmed[len("<!-- FSRS:"):])) {
+			continue
 		}
+		result = append(result, line)
 	}
+	return append(result, lines[end:]...)
+}
 
-	// Add new FSRS metadata at the top
-	fsrsLine := fmt.Sprintf("<!-- FSRS: due:%s, stability:%.2f, difficulty:%.2f, elapsed_days:%d, scheduled_days:%d, reps:%d, lapses:%d, state:%s -->",
+// writeComment replaces the card's metadata comment at the top of its block
+func (c *Card) writeComment(lines []string) []string {
+	lines = c.removeComment(lines)
+	start, end := c.findBlock(lines)
+
+	// The comment goes at the top of the block, but below any front matter or
+	// the blank line after a separator
+	insertAt := start
+	if start == 0 {
+		_, insertAt = frontMatterRange(trimLines(lines))
+	}
+	for insertAt > 0 && insertAt < end && strings.TrimSpace(lines[insertAt]) == "" {
+		insertAt++
+	}
+
+	// Add new FSRS metadata at the top of the block
+	var idField string
+	if c.ID != "" {
+		idField = fmt.Sprintf("id:%s, ", c.ID)
+	}
+	if c.Cloze > 0 {
+		idField += fmt.Sprintf("cloze:%d, ", c.Cloze)
+	}
+	if c.Reverse {
+		idField += "reverse:true, "
+	}
+	// Full precision and the last review, as in front matter, so switching
+	// formats loses nothing
+	var lastReview string
+	if !c.FSRSCard.LastReview.IsZero() {
+		lastReview = fmt.Sprintf("last_review:%s, ", c.FSRSCard.LastReview.Format(time.RFC3339))
+	}
+	fsrsLine := fmt.Sprintf("<!-- FSRS: %sdue:%s, %sstability:%s, difficulty:%s, elapsed_days:%d, scheduled_days:%d, reps:%d, lapses:%d, state:%s -->",
+		idField,
 		c.FSRSCard.Due.Format(time.RFC3339),
-		c.FSRSCard.Stability,
-		c.FSRSCard.Difficulty,
+		lastReview,
+		strconv.FormatFloat(c.FSRSCard.Stability, 'f', -1, 64),
+		strconv.FormatFloat(c.FSRSCard.Difficulty, 'f', -1, 64),
 		c.FSRSCard.ElapsedDays,
 		c.FSRSCard.ScheduledDays,
 		c.FSRSCard.Reps,
 		c.FSRSCard.Lapses,
 		StateToString(c.FSRSCard.State))
 
-	newContent := fsrsLine + "\n" + strings.Join(filteredLines, "\n")
-	
-	return os.WriteFile(c.FilePath, []byte(newContent), 0644)
+	var newLines []string
+	newLines = append(newLines, lines[:insertAt]...)
+	newLines = append(newLines, fsrsLine)
+	newLines = append(newLines, lines[insertAt:]...)
+	return newLines
 }
 
-func parseFSRSMetadata(metadata string) fsrs.Card {
+// findBlock locates the card's block in the file's lines, by ID if the block
+// has one and by position otherwise
+func (c *Card) findBlock(lines []string) (start, end int) {
+	trimmed := trimLines(lines)
+	ranges := blockRanges(trimmed)
+
+	if c.ID != "" {
+		for _, r := range ranges {
+			for _, line := range trimmed[r[0]:r[1]] {
+				if isMetadataLine(line) && parseCardID(line[len("<!-- FSRS:"):]) == c.ID {
+					return r[0], r[1]
+				}
+			}
+		}
+	}
+
+	if c.Block < len(ranges) {
+		return ranges[c.Block][0], ranges[c.Block][1]
+	}
+	return 0, len(lines)
+}
+
+// trimLines strips the carriage returns of Windows line endings
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSuffix(line, "\r")
+	}
+	return trimmed
+}
+
+// metadataPattern matches the key:value pairs of a metadata comment
+var metadataPattern = regexp.MustCompile(`(\w+):([^,]+)`)
+
+// ParseFSRSMetadata parses the body of a <!-- FSRS: ... --> comment
+func ParseFSRSMetadata(metadata string) fsrs.Card {
+	return fsrsCardFromFields(metadataFields(metadata))
+}
+
+// fsrsCardFromFields builds an FSRS card from metadata fields, from either a
+// comment or front matter
+func fsrsCardFromFields(fields map[string]string) fsrs.Card {
 	card := fsrs.NewCard()
 	
-	re := regexp.MustCompile(`(\w+):([^,]+)`)
-	matches := re.FindAllStringSubmatch(metadata, -1)
-	
-	for _, match := range matches {
-		key := strings.TrimSpace(match[1])
-		value := strings.TrimSpace(match[2])
-		
+	for key, value := range fields {
 		switch key {
 		case "due":
 			if t, err := time.Parse(time.RFC3339, value); err == nil {
 				card.Due = t
 			}
+		case "last_review":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				card.LastReview = t
+			}
 		case "stability":
 			if f, err := strconv.ParseFloat(value, 64); err == nil {
 				card.Stability = f
@@ -189,6 +559,20 @@ func parseFSRSMetadata(metadata string) fsrs.Card {
 	return card
 }
 
+// metadataFields returns the key:value pairs of a metadata comment
+func metadataFields(metadata string) map[string]string {
+	fields := make(map[string]string)
+	for _, match := range metadataPattern.FindAllStringSubmatch(strings.TrimSuffix(strings.TrimSpace(metadata), "-->"), -1) {
+		fields[strings.TrimSpace(match[1])] = strings.TrimSpace(match[2])
+	}
+	return fields
+}
+
+// parseCardID extracts the stable card ID from a metadata comment
+func parseCardID(metadata string) string {
+	return metadataFields(metadata)["id"]
+}
+
 // StateToString converts FSRS state to string
 func StateToString(state fsrs.State) string {
 	switch state {
@@ -255,4 +255,204 @@ func TestStateConversion(t *testing.T) {
 			t.Errorf("StringToState(%s) = %v, expected %v", result, backToState, test.state)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestParseMultiCardFile(t *testing.T) {
+	content := `What is hola?
+---
+Hello
+
+===
+
+<!-- FSRS: id:01JH3Q8ZK4W6V2N5XG7T0RB9CM, due:2025-01-01T00:00:00Z, stability:2.50, difficulty:5.00, elapsed_days:0, scheduled_days:1, reps:1, lapses:0, state:Review -->
+What is adiós?
+---
+Goodbye
+
+===
+`
+
+	tmpDir := t.TempDir()
+	cardPath := filepath.Join(tmpDir, "vocab.md")
+	writeFile(cardPath, content)
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, got %d", len(cards))
+	}
+
+	if cards[0].Question != "What is hola?" || cards[0].Answer != "Hello" {
+		t.Errorf("Unexpected first card %q / %q", cards[0].Question, cards[0].Answer)
+	}
+	if cards[1].Question != "What is adiós?" || cards[1].Answer != "Goodbye" {
+		t.Errorf("Unexpected second card %q / %q", cards[1].Question, cards[1].Answer)
+	}
+	if cards[1].ID != "01JH3Q8ZK4W6V2N5XG7T0RB9CM" || cards[1].FSRSCard.State != fsrs.Review {
+		t.Errorf("Expected second card to keep its own metadata, got ID %q state %v", cards[1].ID, cards[1].FSRSCard.State)
+	}
+	if cards[0].ID != "" || cards[0].Key() != cardPath {
+		t.Errorf("Expected first card to be keyed by its path until it's written, got %q", cards[0].Key())
+	}
+	if cards[1].Name() != "vocab #2" {
+		t.Errorf("Expected name 'vocab #2', got %q", cards[1].Name())
+	}
+
+	// Rating one card only rewrites its own block
+	cards[1].FSRSCard.Stability = 9.99
+	if err := cards[1].UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(reparsed) != 2 {
+		t.Fatalf("Expected 2 cards after update, got %d", len(reparsed))
+	}
+	if reparsed[1].FSRSCard.Stability != 9.99 {
+		t.Errorf("Expected updated stability 9.99, got %.2f", reparsed[1].FSRSCard.Stability)
+	}
+	if reparsed[0].FSRSCard.State != fsrs.New || reparsed[0].ID != cards[0].ID {
+		t.Errorf("Expected first card to be untouched, got state %v ID %q", reparsed[0].FSRSCard.State, reparsed[0].ID)
+	}
+
+	reloaded, err := ReloadCard(cards[1])
+	if err != nil {
+		t.Fatalf("ReloadCard failed: %v", err)
+	}
+	if reloaded.Question != "What is adiós?" {
+		t.Errorf("Expected ReloadCard to find the second card, got %q", reloaded.Question)
+	}
+}
+
+func TestSetextHeadingIsNotASeparator(t *testing.T) {
+	content := `Title
+===
+What is a setext heading?
+---
+An underlined heading`
+
+	cardPath := filepath.Join(t.TempDir(), "heading.md")
+	writeFile(cardPath, content)
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("Expected a single card, got %d", len(cards))
+	}
+	if !strings.Contains(cards[0].Question, "Title\n===") {
+		t.Errorf("Expected heading to stay in the question, got %q", cards[0].Question)
+	}
+}
+
+func TestParseClozeCard(t *testing.T) {
+	content := `The {{c1::mitochondria}} is the {{c2::powerhouse::role}} of the {{c1::cell}}.
+---
+Biology 101`
+
+	cardPath := filepath.Join(t.TempDir(), "cell.md")
+	writeFile(cardPath, content)
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected one card per cloze index, got %d", len(cards))
+	}
+
+	if cards[0].Question != `The **\[...\]** is the powerhouse of the **\[...\]**.` {
+		t.Errorf("Unexpected cloze 1 question %q", cards[0].Question)
+	}
+	if cards[0].Answer != "The **mitochondria** is the powerhouse of the **cell**.\n\nBiology 101" {
+		t.Errorf("Unexpected cloze 1 answer %q", cards[0].Answer)
+	}
+	if cards[1].Question != `The mitochondria is the **\[role\]** of the cell.` {
+		t.Errorf("Unexpected cloze 2 question %q", cards[1].Question)
+	}
+	if cards[1].Name() != "cell (cloze 2)" {
+		t.Errorf("Expected name 'cell (cloze 2)', got %q", cards[1].Name())
+	}
+	if cards[0].Key() == cards[1].Key() {
+		t.Errorf("Expected distinct keys, got %q and %q", cards[0].Key(), cards[1].Key())
+	}
+
+	// Each cloze keeps its own schedule
+	cards[1].FSRSCard.State = fsrs.Review
+	cards[1].FSRSCard.Stability = 7.5
+	if err := cards[1].UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(reparsed) != 2 {
+		t.Fatalf("Expected 2 cards after update, got %d", len(reparsed))
+	}
+	if reparsed[0].ID != cards[0].ID || reparsed[0].FSRSCard.State != fsrs.New {
+		t.Errorf("Expected cloze 1 to be untouched, got ID %q state %v", reparsed[0].ID, reparsed[0].FSRSCard.State)
+	}
+	if reparsed[1].ID != cards[1].ID || reparsed[1].FSRSCard.Stability != 7.5 {
+		t.Errorf("Expected cloze 2 to keep its update, got ID %q stability %.2f", reparsed[1].ID, reparsed[1].FSRSCard.Stability)
+	}
+}
+
+func TestParseReversibleCard(t *testing.T) {
+	content := `---
+reverse: true
+---
+perro
+---
+dog`
+
+	cardPath := filepath.Join(t.TempDir(), "perro.md")
+	writeFile(cardPath, content)
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected a card and its reverse, got %d", len(cards))
+	}
+
+	if cards[0].Question != "perro" || cards[0].Answer != "dog" || cards[0].Name() != "perro" {
+		t.Errorf("Unexpected forward card %q / %q named %q", cards[0].Question, cards[0].Answer, cards[0].Name())
+	}
+	if cards[1].Question != "dog" || cards[1].Answer != "perro" || cards[1].Name() != "perro (reverse)" {
+		t.Errorf("Unexpected reverse card %q / %q named %q", cards[1].Question, cards[1].Answer, cards[1].Name())
+	}
+
+	cards[1].FSRSCard.State = fsrs.Review
+	if err := cards[1].UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+
+	// Metadata must stay below the front matter so the flag keeps working
+	lines, _ := readLines(cardPath)
+	if lines[0] != "---" {
+		t.Errorf("Expected front matter to stay at the top, got %q", lines[0])
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(reparsed) != 2 {
+		t.Fatalf("Expected 2 cards after update, got %d", len(reparsed))
+	}
+	if reparsed[0].FSRSCard.State != fsrs.New || reparsed[1].FSRSCard.State != fsrs.Review {
+		t.Errorf("Expected independent schedules, got %v and %v", reparsed[0].FSRSCard.State, reparsed[1].FSRSCard.State)
+	}
+	if reparsed[1].ID != cards[1].ID || reparsed[0].ID == reparsed[1].ID {
+		t.Errorf("Expected distinct stable IDs, got %q and %q", reparsed[0].ID, reparsed[1].ID)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// clozePattern matches {{c1::text}} and {{c1::text::hint}} deletions
+var clozePattern = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+// clozeIndices returns the distinct cloze indices in text, in ascending order
+func clozeIndices(text string) []int {
+	seen := make(map[int]bool)
+	var indices []int
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		index, err := strconv.Atoi(match[1])
+		if err != nil || index < 1 || seen[index] {
+			continue
+		}
+		seen[index] = true
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// clozeQuestion blanks the deletions for index, showing their hint if they
+// have one, and reveals all other deletions
+func clozeQuestion(text string, index int) string {
+	return replaceClozes(text, func(i int, answer, hint string) string {
+		if i != index {
+			return answer
+		}
+		// Escaped so markdown doesn't read the blank as a link reference
+		if hint != "" {
+			return `**\[` + hint + `\]**`
+		}
+		return `**\[...\]**`
+	})
+}
+
+// clozeAnswer reveals every deletion, highlighting those for index, followed
+// by any extra notes from the card's answer section
+func clozeAnswer(text, extra string, index int) string {
+	answer := replaceClozes(text, func(i int, answer, hint string) string {
+		if i != index {
+			return answer
+		}
+		return "**" + answer + "**"
+	})
+	if extra != "" {
+		answer += "\n\n" + extra
+	}
+	return answer
+}
+
+// Expected returns the answer a typed response is graded against: for a
+// cloze card only its own deletions, joined with ", " if there are several
+func (c *Card) Expected() string {
+	if c.Cloze == 0 {
+		return c.Answer
+	}
+
+	var deletions []string
+	replaceClozes(c.Text, func(index int, answer, hint string) string {
+		if index == c.Cloze {
+			deletions = append(deletions, answer)
+		}
+		return answer
+	})
+	return strings.Join(deletions, ", ")
+}
+
+func replaceClozes(text string, replace func(index int, answer, hint string) string) string {
+	return clozePattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := clozePattern.FindStringSubmatch(match)
+		index, _ := strconv.Atoi(parts[1])
+		return replace(index, parts[2], parts[3])
+	})
+}
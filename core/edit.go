@@ -0,0 +1,290 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// TrashDirName is the directory inside DataDirName that deleted cards are
+// moved to
+const TrashDirName = "trash"
+
+// maxSlugLength caps the length of file names generated from questions
+const maxSlugLength = 60
+
+// Slugify turns text into a file name stem: lowercase words of letters and
+// digits joined by hyphens, e.g. "What is a goroutine?" → "what-is-a-goroutine"
+func Slugify(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	slug := ""
+	for _, word := range words {
+		if slug != "" && len(slug)+1+len(word) > maxSlugLength {
+			break
+		}
+		if slug != "" {
+			slug += "-"
+		}
+		slug += word
+	}
+	if runes := []rune(slug); len(runes) > maxSlugLength {
+		slug = string(runes[:maxSlugLength])
+	}
+	if slug == "" {
+		slug = "card"
+	}
+	return slug
+}
+
+// validateContent checks that a question and answer make up exactly one card
+// when written to a file
+func validateContent(question, answer string) error {
+	if strings.TrimSpace(question) == "" {
+		return fmt.Errorf("question is required")
+	}
+	if strings.TrimSpace(answer) == "" {
+		return fmt.Errorf("answer is required")
+	}
+	for _, line := range strings.Split(question, "\n") {
+		if strings.TrimSpace(line) == "---" {
+			return fmt.Errorf("question can't contain a line with only ---, which separates it from the answer")
+		}
+	}
+	for _, line := range strings.Split(question+"\n"+answer, "\n") {
+		if strings.TrimSpace(line) == cardSeparator || isMetadataLine(strings.TrimSpace(line)) {
+			return fmt.Errorf("card text can't contain a line with only %s or an FSRS comment", cardSeparator)
+		}
+	}
+	return nil
+}
+
+// tagLine formats tags as a line of hashtags
+func tagLine(tags []string) (string, error) {
+	var fields []string
+	for _, tag := range tags {
+		field := "#" + normalizeTag(tag)
+		if !inlineTag.MatchString(field) {
+			return "", fmt.Errorf("invalid tag %q: tags are letters, digits, _, / and -", tag)
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// cardLines lays out a card block's text: question, separator, answer and an
+// optional line of tags
+func cardLines(question, answer string, tags []string) ([]string, error) {
+	if err := validateContent(question, answer); err != nil {
+		return nil, err
+	}
+
+	lines := []string{strings.TrimSpace(question), "---", strings.TrimSpace(answer)}
+	if len(tags) > 0 {
+		line, err := tagLine(tags)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "", line)
+	}
+	return lines, nil
+}
+
+// CreateCard writes a new card file in dir, named after its question, and
+// returns the parsed card with its new ID. If another card has the name, a
+// number is added to it.
+func CreateCard(dir, question, answer string, tags []string) (*Card, error) {
+	lines, err := cardLines(question, answer, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := createCardFile(dir, Slugify(question), strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := ParseCard(path)
+	if err != nil {
+		return nil, err
+	}
+	return card, assignIDs(card)
+}
+
+// createCardFile writes content to a new card file in dir named stem.md, or
+// stem-2.md, stem-3.md and so on if the name is taken, and returns its path
+func createCardFile(dir, stem, content string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	for i := 1; ; i++ {
+		name := stem + ".md"
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d.md", stem, i)
+		}
+		path := filepath.Join(dir, name)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = file.WriteString(content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return path, err
+	}
+}
+
+// FindDuplicate returns the card among cards asking the same question,
+// ignoring case and whitespace, or nil if there is none
+func FindDuplicate(cards []*Card, question string) *Card {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+
+	want := normalize(question)
+	for _, card := range cards {
+		if normalize(card.Question) == want {
+			return card
+		}
+	}
+	return nil
+}
+
+// bodyStart returns the first line of a block that belongs to the card text:
+// after the front matter for the first block of a file
+func bodyStart(lines []string, start int) int {
+	if start == 0 {
+		if _, end := frontMatterRange(lines); end > 0 {
+			return end
+		}
+	}
+	return start
+}
+
+// UpdateContent replaces the text of the card's block with a new question and
+// answer, keeping its ID and FSRS state. Tags set the block's tag line; nil
+// keeps the existing tag lines. Tags from front matter are left alone. Cloze
+// cards share their text with their siblings and can't be updated this way.
+func (c *Card) UpdateContent(question, answer string, tags []string) (*Card, error) {
+	if c.Cloze > 0 {
+		return nil, fmt.Errorf("%s is a cloze card; edit its file to change the cloze text", c.Name())
+	}
+	if c.Reverse {
+		question, answer = answer, question
+	}
+
+	lines, err := readLines(c.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	start, end := c.findBlock(lines)
+	body := bodyStart(lines, start)
+
+	var metadata, keptTags []string
+	inCode := false
+	for _, line := range lines[body:end] {
+		if isMetadataLine(line) {
+			metadata = append(metadata, line)
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+		if _, ok := parseTagLine(line); ok && !inCode && tags == nil {
+			keptTags = append(keptTags, line)
+		}
+	}
+
+	text, err := cardLines(question, answer, tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(keptTags) > 0 {
+		text = append(append(text, ""), keptTags...)
+	}
+
+	// Blocks after a separator start with a blank line, and a separator
+	// needs one before it
+	var block []string
+	if start > 0 {
+		block = append(block, "")
+	}
+	block = append(append(block, metadata...), text...)
+	if end < len(lines) {
+		block = append(block, "")
+	}
+
+	updated := append(append(append([]string(nil), lines[:body]...), block...), lines[end:]...)
+	if end == len(lines) {
+		updated = append(updated, "")
+	}
+	if err := os.WriteFile(c.FilePath, []byte(strings.Join(updated, "\n")), 0644); err != nil {
+		return nil, err
+	}
+
+	return ReloadCard(c)
+}
+
+// TrashCard moves a card to the trash directory of the base deck root and
+// returns where it went. A card alone in its file takes the file with it; in
+// multi-card files, its block is cut out. Either way, sibling cards sharing
+// the card's text (cloze deletions, reverse cards) go too. The card keeps its
+// ID, so its history applies again if it's moved back.
+func TrashCard(card *Card, root string) (string, error) {
+	dir := filepath.Join(root, DataDirName, TrashDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if err := assignIDs(card); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(card.FilePath), filepath.Ext(card.FilePath))
+	trashPath := filepath.Join(dir, fmt.Sprintf("%s-%s.md", name, card.ID))
+	if _, err := os.Stat(trashPath); err == nil {
+		return "", fmt.Errorf("%s already exists", trashPath)
+	}
+
+	if !card.MultiCard {
+		return trashPath, os.Rename(card.FilePath, trashPath)
+	}
+
+	lines, err := readLines(card.FilePath)
+	if err != nil {
+		return "", err
+	}
+	start, end := card.findBlock(lines)
+	body := bodyStart(lines, start)
+
+	if err := os.WriteFile(trashPath, []byte(strings.TrimSpace(strings.Join(lines[body:end], "\n"))+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	// Remove the block with the separator after it, or before it for the
+	// last block
+	cutFrom, cutTo := body, end+1
+	if end == len(lines) {
+		cutFrom, cutTo = start-1, end
+		for cutFrom > 0 && strings.TrimSpace(lines[cutFrom-1]) == "" {
+			cutFrom--
+		}
+	}
+	cutTo = min(cutTo, len(lines))
+	remaining := append(append([]string(nil), lines[:cutFrom]...), lines[cutTo:]...)
+
+	content := strings.TrimRight(strings.Join(remaining, "\n"), "\n") + "\n"
+	if cutFrom == 0 {
+		content = strings.TrimLeft(content, "\n")
+	}
+	return trashPath, os.WriteFile(card.FilePath, []byte(content), 0644)
+}
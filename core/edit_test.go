@@ -0,0 +1,163 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"What is a goroutine?", "what-is-a-goroutine"},
+		{"  Ser vs. Estar  ", "ser-vs-estar"},
+		{"¿Qué hora es?", "qué-hora-es"},
+		{"???", "card"},
+		{strings.Repeat("word ", 30), strings.TrimSuffix(strings.Repeat("word-", 12), "-")},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.text); got != tt.expected {
+			t.Errorf("Slugify(%q) = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestCreateCard(t *testing.T) {
+	dir := t.TempDir()
+
+	card, err := CreateCard(filepath.Join(dir, "go"), "What is a goroutine?", "A lightweight thread managed by the Go runtime", []string{"go", "Concurrency"})
+	if err != nil {
+		t.Fatalf("CreateCard failed: %v", err)
+	}
+	if card.FilePath != filepath.Join(dir, "go", "what-is-a-goroutine.md") {
+		t.Errorf("Unexpected file path %s", card.FilePath)
+	}
+	if card.ID == "" || card.Question != "What is a goroutine?" || card.FSRSCard.State != fsrs.New {
+		t.Errorf("Unexpected card %+v", card)
+	}
+	if !card.HasTag("go") || !card.HasTag("concurrency") {
+		t.Errorf("Expected tags go and concurrency, got %v", card.Tags)
+	}
+
+	// A different question with the same name gets a numbered file
+	other, err := CreateCard(filepath.Join(dir, "go"), "What is a goroutine", "Again", nil)
+	if err != nil {
+		t.Fatalf("CreateCard failed on a name collision: %v", err)
+	}
+	if other.FilePath != filepath.Join(dir, "go", "what-is-a-goroutine-2.md") || other.Answer != "Again" {
+		t.Errorf("Expected a numbered file, got %s", other.FilePath)
+	}
+	if data, _ := os.ReadFile(card.FilePath); !strings.Contains(string(data), "lightweight thread") {
+		t.Errorf("Expected the first card untouched, got %q", data)
+	}
+	if _, err := CreateCard(dir, "Question\n---\nmore", "Answer", nil); err == nil {
+		t.Error("Expected an error for a question containing the answer separator")
+	}
+	if _, err := CreateCard(dir, "Question", "Answer", []string{"two words"}); err == nil {
+		t.Error("Expected an error for an invalid tag")
+	}
+
+	if FindDuplicate([]*Card{card}, "  what is a   GOROUTINE? ") != card {
+		t.Error("Expected FindDuplicate to ignore case and whitespace")
+	}
+	if FindDuplicate([]*Card{card}, "What is a channel?") != nil {
+		t.Error("Expected no duplicate for a different question")
+	}
+}
+
+func TestUpdateContent(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "cards.md")
+	writeFile(cardPath, "First?\n---\nOne\n#old\n\n===\n\nSecond?\n---\nTwo\n")
+
+	cards, err := ParseCards(cardPath)
+	if err != nil || len(cards) != 2 {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	first := cards[0]
+	first.FSRSCard.Reps = 3
+	first.FSRSCard.State = fsrs.Review
+	if err := first.UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+
+	updated, err := first.UpdateContent("First, edited?", "Uno", nil)
+	if err != nil {
+		t.Fatalf("UpdateContent failed: %v", err)
+	}
+	if updated.ID != first.ID || updated.FSRSCard.Reps != 3 || updated.FSRSCard.State != fsrs.Review {
+		t.Errorf("Expected ID and FSRS state to be kept, got %+v", updated)
+	}
+	if updated.Question != "First, edited?" || updated.Answer != "Uno" || !updated.HasTag("old") {
+		t.Errorf("Unexpected updated card %q / %q %v", updated.Question, updated.Answer, updated.Tags)
+	}
+
+	second, err := cards[1].UpdateContent("Second?", "Dos", []string{})
+	if err != nil {
+		t.Fatalf("UpdateContent failed: %v", err)
+	}
+	if second.Answer != "Dos" {
+		t.Errorf("Expected answer Dos, got %q", second.Answer)
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil || len(reparsed) != 2 {
+		t.Fatalf("Expected the file to still hold 2 cards, got %d: %v", len(reparsed), err)
+	}
+	if reparsed[0].ID != first.ID || reparsed[1].ID != cards[1].ID {
+		t.Error("Expected both cards to keep their IDs")
+	}
+
+	clozePath := filepath.Join(t.TempDir(), "cloze.md")
+	writeFile(clozePath, "The {{c1::mitochondria}} is the powerhouse of the cell.")
+	cloze, _ := ParseCard(clozePath)
+	if _, err := cloze.UpdateContent("Q", "A", nil); err == nil {
+		t.Error("Expected an error updating a cloze card")
+	}
+}
+
+func TestTrashCard(t *testing.T) {
+	root := t.TempDir()
+	InitDeckRoot(root)
+
+	single := filepath.Join(root, "single.md")
+	writeFile(single, "Question\n---\nAnswer")
+	card, _ := ParseCard(single)
+
+	trashPath, err := TrashCard(card, root)
+	if err != nil {
+		t.Fatalf("TrashCard failed: %v", err)
+	}
+	if _, err := os.Stat(single); !os.IsNotExist(err) {
+		t.Error("Expected the card file to be gone")
+	}
+	if filepath.Dir(trashPath) != filepath.Join(root, DataDirName, TrashDirName) {
+		t.Errorf("Expected the card in the trash, got %s", trashPath)
+	}
+	if trashed, _ := ParseCard(trashPath); trashed == nil || trashed.ID != card.ID {
+		t.Error("Expected the trashed card to keep its ID")
+	}
+
+	multi := filepath.Join(root, "multi.md")
+	writeFile(multi, "One?\n---\n1\n\n===\n\nTwo?\n---\n2\n\n===\n\nThree?\n---\n3\n")
+	for _, question := range []string{"Three?", "One?"} {
+		cards, _ := ParseCards(multi)
+		target := FindDuplicate(cards, question)
+		if _, err := TrashCard(target, root); err != nil {
+			t.Fatalf("TrashCard failed: %v", err)
+		}
+	}
+
+	remaining, err := ParseCards(multi)
+	if err != nil || len(remaining) != 1 {
+		t.Fatalf("Expected one card left, got %d: %v", len(remaining), err)
+	}
+	if remaining[0].Question != "Two?" || remaining[0].MultiCard {
+		t.Errorf("Expected only Two? to be left, got %+v", remaining[0])
+	}
+}
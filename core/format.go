@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MetadataFormat selects where a card's FSRS state is stored in its file
+type MetadataFormat string
+
+const (
+	// FormatComment stores each card's state in a <!-- FSRS: ... --> comment
+	// at the top of its block
+	FormatComment MetadataFormat = "comment"
+	// FormatYAML stores the states of every card in a file under the srs key
+	// of its YAML front matter
+	FormatYAML MetadataFormat = "yaml"
+)
+
+// metadataFormat is the format UpdateFSRSMetadata writes
+var metadataFormat = FormatComment
+
+// SetMetadataFormat changes the format UpdateFSRSMetadata writes. Both
+// formats are always read.
+func SetMetadataFormat(format MetadataFormat) {
+	metadataFormat = format
+}
+
+// ParseMetadataFormat validates a metadata format name
+func ParseMetadataFormat(name string) (MetadataFormat, error) {
+	switch format := MetadataFormat(name); format {
+	case FormatComment, FormatYAML:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown metadata format %q (want %s or %s)", name, FormatComment, FormatYAML)
+}
+
+// stateFields returns the card's ID and FSRS state as front-matter fields
+func (c *Card) stateFields() map[string]string {
+	fields := map[string]string{
+		"due":            c.FSRSCard.Due.Format(time.RFC3339),
+		"stability":      strconv.FormatFloat(c.FSRSCard.Stability, 'f', -1, 64),
+		"difficulty":     strconv.FormatFloat(c.FSRSCard.Difficulty, 'f', -1, 64),
+		"elapsed_days":   strconv.FormatUint(c.FSRSCard.ElapsedDays, 10),
+		"scheduled_days": strconv.FormatUint(c.FSRSCard.ScheduledDays, 10),
+		"reps":           strconv.FormatUint(c.FSRSCard.Reps, 10),
+		"lapses":         strconv.FormatUint(c.FSRSCard.Lapses, 10),
+		"state":          StateToString(c.FSRSCard.State),
+	}
+	if c.ID != "" {
+		fields["id"] = c.ID
+	}
+	if c.Block > 0 {
+		fields["block"] = strconv.Itoa(c.Block)
+	}
+	if c.Cloze > 0 {
+		fields["cloze"] = strconv.Itoa(c.Cloze)
+	}
+	if c.Reverse {
+		fields["reverse"] = "true"
+	}
+	if !c.FSRSCard.LastReview.IsZero() {
+		fields["last_review"] = c.FSRSCard.LastReview.Format(time.RFC3339)
+	}
+	return fields
+}
+
+// writeFrontMatterState replaces the card's state in the file's front matter,
+// or removes it if keep is false, leaving the states of other cards in place
+func (c *Card) writeFrontMatterState(lines []string, keep bool) []string {
+	states := parseFrontMatter(trimLines(lines)).states()
+
+	var updated []map[string]string
+	found := false
+	for _, state := range states {
+		if !c.ownsState(state) {
+			updated = append(updated, state)
+			continue
+		}
+		if keep && !found {
+			updated = append(updated, c.stateFields())
+		}
+		found = true
+	}
+	if keep && !found {
+		updated = append(updated, c.stateFields())
+	}
+
+	if !found && !keep {
+		return lines
+	}
+	return setFrontMatterStates(lines, updated)
+}
+
+// MigrateFormat rewrites the metadata of every card in format, returning the
+// number of cards converted. Cards without IDs get them first, with their
+// journaled reviews, so front-matter states never rely on block order.
+func MigrateFormat(cards []*Card, format MetadataFormat) (int, error) {
+	for i, card := range cards {
+		if card.ID == "" {
+			if err := assignIDs(card); err != nil {
+				return i, fmt.Errorf("failed to migrate %s: %v", card.FilePath, err)
+			}
+		}
+		if err := card.WriteMetadata(format); err != nil {
+			return i, fmt.Errorf("failed to migrate %s: %v", card.FilePath, err)
+		}
+	}
+	return len(cards), nil
+}
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	lines := strings.Split(`---
+reverse: true
+tags: [spanish, "verbs"]
+notes:
+  source: "Textbook: chapter 3, verbs"
+  pages: |
+    12-14
+srs:
+  - id: A
+    state: Review
+  - id: B
+    cloze: 2
+---
+Question`, "\n")
+
+	front := parseFrontMatter(lines)
+	if !front.Bool("reverse") {
+		t.Error("Expected reverse to be true")
+	}
+	if tags, _ := front["tags"].([]interface{}); len(tags) != 2 || tags[1] != "verbs" {
+		t.Errorf("Unexpected tags %v", front["tags"])
+	}
+	if notes, _ := front["notes"].(map[string]interface{}); notes["source"] != "Textbook: chapter 3, verbs" || notes["pages"] != "12-14\n" {
+		t.Errorf("Unexpected notes %v", front["notes"])
+	}
+
+	states := front.states()
+	if len(states) != 2 || states[0]["state"] != "Review" || states[1]["cloze"] != "2" {
+		t.Errorf("Unexpected states %v", states)
+	}
+}
+
+func TestYAMLMetadataFormat(t *testing.T) {
+	SetMetadataFormat(FormatYAML)
+	defer SetMetadataFormat(FormatComment)
+
+	content := `---
+reverse: true
+---
+perro
+---
+dog`
+
+	cardPath := filepath.Join(t.TempDir(), "perro.md")
+	writeFile(cardPath, content)
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, got %d", len(cards))
+	}
+
+	cards[1].FSRSCard.State = fsrs.Review
+	cards[1].FSRSCard.Stability = 12.3456
+	if err := cards[1].UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(cardPath)
+	if strings.Contains(string(data), "<!-- FSRS:") {
+		t.Errorf("Expected no metadata comments in YAML format, got:\n%s", data)
+	}
+	if !strings.HasPrefix(string(data), "---\nreverse: true\nsrs:\n  - id: ") {
+		t.Errorf("Expected states in the front matter, got:\n%s", data)
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(reparsed) != 2 || reparsed[0].Question != "perro" {
+		t.Fatalf("Expected the card body to be untouched, got %d cards", len(reparsed))
+	}
+	if reparsed[0].ID != cards[0].ID || reparsed[0].FSRSCard.State != fsrs.New {
+		t.Errorf("Expected forward card untouched, got ID %q state %v", reparsed[0].ID, reparsed[0].FSRSCard.State)
+	}
+	if reparsed[1].ID != cards[1].ID || reparsed[1].FSRSCard.Stability != 12.3456 {
+		t.Errorf("Expected reverse card at full precision, got ID %q stability %v", reparsed[1].ID, reparsed[1].FSRSCard.Stability)
+	}
+}
+
+func TestMigrateFormatRoundTrip(t *testing.T) {
+	deck := t.TempDir()
+	original := `<!-- FSRS: id:01JH3Q8ZK4W6V2N5XG7T0RB9CM, due:2025-01-01T00:00:00Z, last_review:2024-12-28T09:15:00Z, stability:2.4789123, difficulty:5.0312, elapsed_days:3, scheduled_days:4, reps:2, lapses:1, state:Review -->
+What is hola?
+---
+Hello
+
+===
+
+<!-- FSRS: id:01JH3Q8ZK4W6V2N5XG7T0RB9CN, cloze:1, due:2025-02-01T00:00:00Z, stability:1, difficulty:6, elapsed_days:0, scheduled_days:1, reps:1, lapses:0, state:Learning -->
+{{c1::Adiós}} means goodbye`
+	writeFile(filepath.Join(deck, "vocab.md"), original)
+
+	before, err := FindCards(deck)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+
+	for _, format := range []MetadataFormat{FormatYAML, FormatComment} {
+		if _, err := MigrateFormat(before, format); err != nil {
+			t.Fatalf("MigrateFormat(%s) failed: %v", format, err)
+		}
+
+		after, err := FindCards(deck)
+		if err != nil {
+			t.Fatalf("FindCards failed: %v", err)
+		}
+		if len(after) != len(before) {
+			t.Fatalf("%s: expected %d cards, got %d", format, len(before), len(after))
+		}
+		for i := range before {
+			if after[i].ID != before[i].ID || after[i].FSRSCard != before[i].FSRSCard || after[i].Question != before[i].Question {
+				t.Errorf("%s: card %d changed from %+v to %+v", format, i, before[i].FSRSCard, after[i].FSRSCard)
+			}
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(deck, "vocab.md"))
+	if string(data) != original {
+		t.Errorf("Expected migrating back to restore the file, got:\n%s", data)
+	}
+}
+
+func TestMigrateFormatFromYAMLRoundTrip(t *testing.T) {
+	deck := t.TempDir()
+	original := `---
+tags: [spanish]
+srs:
+  - id: 01JH3Q8ZK4W6V2N5XG7T0RB9CM
+    due: 2025-01-01T00:00:00Z
+    last_review: 2024-12-28T09:15:00Z
+    stability: 2.4789123
+    difficulty: 5.0312
+    elapsed_days: 3
+    scheduled_days: 4
+    reps: 2
+    lapses: 1
+    state: Review
+  - id: 01JH3Q8ZK4W6V2N5XG7T0RB9CN
+    block: 1
+    cloze: 1
+    due: 2025-02-01T00:00:00Z
+    last_review: 2025-01-31T18:00:00Z
+    stability: 0.4012
+    difficulty: 6.81
+    elapsed_days: 0
+    scheduled_days: 1
+    reps: 1
+    lapses: 0
+    state: Learning
+---
+What is hola?
+---
+Hello
+
+===
+
+{{c1::Adiós}} means goodbye`
+	writeFile(filepath.Join(deck, "vocab.md"), original)
+
+	before, err := FindCards(deck)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	if len(before) != 2 || before[0].FSRSCard.LastReview.IsZero() || before[1].FSRSCard.Stability != 0.4012 {
+		t.Fatalf("Expected both states read from the front matter, got %d cards", len(before))
+	}
+
+	for _, format := range []MetadataFormat{FormatComment, FormatYAML} {
+		if _, err := MigrateFormat(before, format); err != nil {
+			t.Fatalf("MigrateFormat(%s) failed: %v", format, err)
+		}
+
+		after, err := FindCards(deck)
+		if err != nil {
+			t.Fatalf("FindCards failed: %v", err)
+		}
+		for i := range before {
+			if after[i].ID != before[i].ID || after[i].FSRSCard != before[i].FSRSCard || !after[i].HasTag("spanish") {
+				t.Errorf("%s: card %d changed from %+v to %+v", format, i, before[i].FSRSCard, after[i].FSRSCard)
+			}
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(deck, "vocab.md"))
+	if string(data) != original {
+		t.Errorf("Expected migrating back to restore the file, got:\n%s", data)
+	}
+}
+
+func TestMigrateFormatAssignsIDs(t *testing.T) {
+	root := t.TempDir()
+	InitDeckRoot(root)
+	cardPath := filepath.Join(root, "vocab.md")
+	writeFile(cardPath, `What is hola?
+---
+Hello
+
+===
+
+<!-- FSRS: due:2025-02-01T00:00:00Z, stability:3.5, difficulty:5, elapsed_days:0, scheduled_days:3, reps:1, lapses:0, state:Review -->
+What is adiós?
+---
+Goodbye`)
+	AppendReview(root, ReviewRecord{CardID: "vocab.md:2", Time: time.Date(2025, 1, 29, 9, 0, 0, 0, time.UTC), Rating: 3, State: "New"})
+
+	cards, err := FindCards(root)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	if _, err := MigrateFormat(cards, FormatYAML); err != nil {
+		t.Fatalf("MigrateFormat failed: %v", err)
+	}
+	if cards[0].ID == "" || cards[1].ID == "" {
+		t.Fatalf("Expected both cards to get IDs, got %q and %q", cards[0].ID, cards[1].ID)
+	}
+
+	// The review journaled under the path key follows the card's new ID
+	history, _ := LoadHistory(root)
+	if len(history[cards[1].ID]) != 1 || len(history["vocab.md:2"]) != 0 {
+		t.Errorf("Expected the review re-keyed to %s, got %v", cards[1].ID, history)
+	}
+
+	// Every state written to the front matter carries its card's ID
+	data, _ := os.ReadFile(cardPath)
+	states := parseFrontMatter(strings.Split(string(data), "\n")).states()
+	if len(states) != 2 || states[0]["id"] == "" || states[1]["id"] == "" {
+		t.Errorf("Expected both states stored with IDs, got %v", states)
+	}
+
+	reparsed, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if reparsed[1].ID != cards[1].ID || reparsed[1].FSRSCard.State != fsrs.Review || len(reparsed[1].ReviewLog) != 1 {
+		t.Errorf("Expected the reviewed card to keep its state and history, got %q %v", reparsed[1].ID, reparsed[1].FSRSCard.State)
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter opens and closes a front-matter block at the top of a
+// card file
+const frontMatterDelimiter = "---"
+
+// stateKey is the front-matter key holding the FSRS state of each card in
+// the file
+const stateKey = "srs"
+
+// frontMatterField matches a "key: value" line inside front matter
+var frontMatterField = regexp.MustCompile(`^([A-Za-z_][\w-]*):(?:\s+(.*))?$`)
+
+// frontMatter is the parsed front matter of a card file. Values are strings,
+// []interface{} lists, or map[string]interface{} mappings.
+type frontMatter map[string]interface{}
+
+// frontMatterRange returns the [start, end) line range of a file's front
+// matter, delimiters included, or 0, 0 if it has none. Only metadata comments
+// may come before it. Every line inside must be a field, a list item, an
+// indented continuation, a comment or blank, so a card that happens to start
+// with the answer separator isn't mistaken for front matter.
+func frontMatterRange(lines []string) (start, end int) {
+	for start < len(lines) && isMetadataLine(lines[start]) {
+		start++
+	}
+	if start >= len(lines) || strings.TrimSpace(lines[start]) != frontMatterDelimiter {
+		return 0, 0
+	}
+
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == frontMatterDelimiter:
+			return start, i + 1
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(line, " "):
+			continue
+		case trimmed == "-" || strings.HasPrefix(trimmed, "- ") || frontMatterField.MatchString(trimmed):
+			continue
+		default:
+			return 0, 0
+		}
+	}
+	return 0, 0
+}
+
+// parseFrontMatter parses a file's front matter, which may be empty
+func parseFrontMatter(lines []string) frontMatter {
+	start, end := frontMatterRange(lines)
+	if end == 0 {
+		return frontMatter{}
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[start+1:end-1], "\n")), &document); err != nil {
+		return frontMatter{}
+	}
+
+	value := yamlValue(&document)
+	if fields, ok := value.(map[string]interface{}); ok {
+		return frontMatter(fields)
+	}
+	return frontMatter{}
+}
+
+// String returns a scalar field, or "" if it is missing or not a scalar
+func (f frontMatter) String(key string) string {
+	value, _ := f[key].(string)
+	return value
+}
+
+// Bool interprets a scalar field as a boolean
+func (f frontMatter) Bool(key string) bool {
+	return frontMatterBool(f.String(key))
+}
+
+// states returns the per-card FSRS states stored under stateKey
+func (f frontMatter) states() []map[string]string {
+	items, _ := f[stateKey].([]interface{})
+
+	var states []map[string]string
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		state := make(map[string]string)
+		for key, value := range fields {
+			if s, ok := value.(string); ok {
+				state[key] = s
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// blankFrontMatter returns a copy of lines with the front matter replaced by
+// blank lines, so card parsing sees only the body without shifting line numbers
+func blankFrontMatter(lines []string) []string {
+	start, end := frontMatterRange(lines)
+	if end == 0 {
+		return lines
+	}
+
+	body := append([]string(nil), lines...)
+	for i := start; i < end; i++ {
+		body[i] = ""
+	}
+	return body
+}
+
+// frontMatterBool interprets a front-matter value as a boolean
+func frontMatterBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// setFrontMatterStates replaces the stateKey section of a file's front
+// matter with states, creating the front matter if needed and removing it if
+// nothing else is left in it. Other fields are kept exactly as written.
+func setFrontMatterStates(lines []string, states []map[string]string) []string {
+	start, end := frontMatterRange(trimLines(lines))
+	if end == 0 {
+		if len(states) == 0 {
+			return lines
+		}
+		section := append([]string{frontMatterDelimiter}, renderStates(states)...)
+		section = append(section, frontMatterDelimiter)
+		return append(section, lines...)
+	}
+
+	// Find the section: the key line plus everything indented or listed under it
+	var body []string
+	sectionAt := -1
+	for i := start + 1; i < end-1; i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		if strings.TrimSpace(line) == stateKey+":" && !strings.HasPrefix(line, " ") {
+			sectionAt = len(body)
+			for i+1 < end-1 {
+				next := strings.TrimSuffix(lines[i+1], "\r")
+				if strings.TrimSpace(next) != "" && !strings.HasPrefix(next, " ") && !strings.HasPrefix(next, "-") {
+					break
+				}
+				i++
+			}
+			continue
+		}
+		body = append(body, lines[i])
+	}
+
+	if len(states) > 0 {
+		if sectionAt < 0 {
+			sectionAt = len(body)
+		}
+		rendered := renderStates(states)
+		body = append(body[:sectionAt], append(rendered, body[sectionAt:]...)...)
+	}
+
+	var result []string
+	result = append(result, lines[:start]...)
+	if strings.TrimSpace(strings.Join(body, "")) != "" {
+		result = append(result, frontMatterDelimiter)
+		result = append(result, body...)
+		result = append(result, frontMatterDelimiter)
+	}
+	return append(result, lines[end:]...)
+}
+
+// stateFieldOrder is the order fields of a card state are written in
+var stateFieldOrder = []string{"id", "block", "cloze", "reverse", "due", "last_review", "stability", "difficulty", "elapsed_days", "scheduled_days", "reps", "lapses", "state"}
+
+// renderStates renders the stateKey section of front matter
+func renderStates(states []map[string]string) []string {
+	lines := []string{stateKey + ":"}
+	for _, state := range states {
+		prefix := "  - "
+		for _, key := range stateFieldOrder {
+			if value, ok := state[key]; ok {
+				lines = append(lines, prefix+key+": "+value)
+				prefix = "    "
+			}
+		}
+	}
+	return lines
+}
+
+// yamlValue converts a decoded YAML node to front-matter values. Scalars are
+// kept as written, so IDs, dates and numbers read back exactly.
+func yamlValue(node *yaml.Node) interface{} {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return yamlValue(node.Content[0])
+		}
+	case yaml.MappingNode:
+		fields := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fields[node.Content[i].Value] = yamlValue(node.Content[i+1])
+		}
+		return fields
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, item := range node.Content {
+			list = append(list, yamlValue(item))
+		}
+		return list
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!null" {
+			return node.Value
+		}
+	}
+	return ""
+}
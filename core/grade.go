@@ -0,0 +1,232 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Grader names accepted by NewGrader
+const (
+	GraderExact   = "exact"   // the typed answer matches once normalized
+	GraderFuzzy   = "fuzzy"   // edit distance and word overlap
+	GraderCommand = "command" // an external program, such as a local LLM
+)
+
+// GradeTimeout bounds how long a command grader may take
+var GradeTimeout = 30 * time.Second
+
+// Grade is a suggested rating for a typed answer
+type Grade struct {
+	Rating      fsrs.Rating
+	Explanation string
+}
+
+// Grader suggests a rating for an answer typed during review. The suggestion
+// is only shown; the user still picks the rating.
+type Grader interface {
+	Grade(question, expected, answer string) (Grade, error)
+}
+
+// NewGrader returns the grader with the given name. The command grader runs
+// command through the shell.
+func NewGrader(name, command string) (Grader, error) {
+	switch name {
+	case GraderExact:
+		return ExactGrader{}, nil
+	case GraderFuzzy:
+		return FuzzyGrader{}, nil
+	case GraderCommand:
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("the command grader needs a grader_command")
+		}
+		return CommandGrader{Command: command}, nil
+	}
+	return nil, fmt.Errorf("unknown grader %q (want %s, %s or %s)", name, GraderExact, GraderFuzzy, GraderCommand)
+}
+
+// normalizeAnswer lowercases text and reduces it to words of letters and
+// digits, so punctuation, markdown and spacing don't count against an answer
+func normalizeAnswer(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ExactGrader suggests Good when the typed answer matches the expected one,
+// ignoring case, punctuation and spacing, and Again otherwise
+type ExactGrader struct{}
+
+func (ExactGrader) Grade(question, expected, answer string) (Grade, error) {
+	if strings.Join(normalizeAnswer(expected), " ") == strings.Join(normalizeAnswer(answer), " ") {
+		return Grade{Rating: fsrs.Good, Explanation: "Matches the answer"}, nil
+	}
+	return Grade{Rating: fsrs.Again, Explanation: "Doesn't match the answer"}, nil
+}
+
+// Similarity thresholds of the fuzzy grader
+const (
+	fuzzyGood = 0.9
+	fuzzyHard = 0.6
+)
+
+// FuzzyGrader rates a typed answer by its similarity to the expected one:
+// the better of their edit distance and the share of the expected words it
+// contains. Close answers get Good, partial ones Hard.
+type FuzzyGrader struct{}
+
+func (FuzzyGrader) Grade(question, expected, answer string) (Grade, error) {
+	want, got := normalizeAnswer(expected), normalizeAnswer(answer)
+	similarity := max(editSimilarity(strings.Join(want, " "), strings.Join(got, " ")), wordOverlap(want, got))
+
+	explanation := fmt.Sprintf("%.0f%% similar to the answer", similarity*100)
+	switch {
+	case similarity >= fuzzyGood:
+		return Grade{Rating: fsrs.Good, Explanation: explanation}, nil
+	case similarity >= fuzzyHard:
+		return Grade{Rating: fsrs.Hard, Explanation: explanation}, nil
+	}
+	return Grade{Rating: fsrs.Again, Explanation: explanation}, nil
+}
+
+// editSimilarity is 1 minus the Levenshtein distance between two strings
+// over the length of the longer one
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein counts the insertions, deletions and substitutions turning a
+// into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// wordOverlap is the share of the expected words found in the answer
+func wordOverlap(want, got []string) float64 {
+	if len(want) == 0 {
+		return 0
+	}
+	have := make(map[string]bool)
+	for _, word := range got {
+		have[word] = true
+	}
+	found := 0
+	for _, word := range want {
+		if have[word] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(want))
+}
+
+// CommandGrader hands grading to an external program run through the shell.
+// It gets {"question", "expected", "answer"} as JSON on stdin, and the same
+// in the SRS_QUESTION, SRS_EXPECTED and SRS_ANSWER environment variables. It
+// prints either {"rating": 3, "explanation": "..."} or a rating followed by
+// an explanation, such as "3 Close enough". Ratings are 1-4 or their names.
+type CommandGrader struct {
+	Command string
+}
+
+func (g CommandGrader) Grade(question, expected, answer string) (Grade, error) {
+	input, err := json.Marshal(map[string]string{"question": question, "expected": expected, "answer": answer})
+	if err != nil {
+		return Grade{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), GradeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", g.Command)
+	// Killing the shell leaves any background processes it started holding
+	// its output open, so stop waiting for them shortly after the timeout
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "SRS_QUESTION="+question, "SRS_EXPECTED="+expected, "SRS_ANSWER="+answer)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return Grade{}, fmt.Errorf("grader timed out after %s", GradeTimeout)
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return Grade{}, fmt.Errorf("grader failed: %v: %s", err, message)
+		}
+		return Grade{}, fmt.Errorf("grader failed: %v", err)
+	}
+	return parseGradeOutput(output)
+}
+
+// parseGradeOutput reads a command grader's JSON or plain text verdict
+func parseGradeOutput(output []byte) (Grade, error) {
+	output = bytes.TrimSpace(output)
+	if bytes.HasPrefix(output, []byte("{")) {
+		var verdict struct {
+			Rating      json.RawMessage `json:"rating"`
+			Explanation string          `json:"explanation"`
+		}
+		if err := json.Unmarshal(output, &verdict); err != nil {
+			return Grade{}, fmt.Errorf("invalid grader output: %v", err)
+		}
+		rating, err := parseRating(strings.Trim(string(verdict.Rating), `"`))
+		if err != nil {
+			return Grade{}, err
+		}
+		return Grade{Rating: rating, Explanation: strings.TrimSpace(verdict.Explanation)}, nil
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return Grade{}, fmt.Errorf("grader printed nothing")
+	}
+	rating, err := parseRating(strings.TrimRight(fields[0], ".:,"))
+	if err != nil {
+		return Grade{}, err
+	}
+	explanation := strings.TrimSpace(strings.TrimPrefix(string(output), fields[0]))
+	return Grade{Rating: rating, Explanation: explanation}, nil
+}
+
+// parseRating reads a rating given as 1-4 or by name
+func parseRating(value string) (fsrs.Rating, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return RatingFromInt(n)
+	}
+	for _, rating := range []fsrs.Rating{fsrs.Again, fsrs.Hard, fsrs.Good, fsrs.Easy} {
+		if strings.EqualFold(value, RatingToString(rating)) {
+			return rating, nil
+		}
+	}
+	return fsrs.Again, fmt.Errorf("grader gave no rating: want 1-4 or Again, Hard, Good or Easy, got %q", value)
+}
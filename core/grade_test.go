@@ -0,0 +1,149 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestExactGrader(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   fsrs.Rating
+	}{
+		{"Paris", fsrs.Good},
+		{"  paris. ", fsrs.Good},
+		{"**Paris**", fsrs.Good},
+		{"Lyon", fsrs.Again},
+		{"Paris, France", fsrs.Again},
+	}
+
+	for _, tt := range tests {
+		grade, err := ExactGrader{}.Grade("Capital of France?", "Paris", tt.answer)
+		if err != nil {
+			t.Fatalf("Grade(%q) failed: %v", tt.answer, err)
+		}
+		if grade.Rating != tt.want {
+			t.Errorf("Grade(%q) = %s, want %s", tt.answer, RatingToString(grade.Rating), RatingToString(tt.want))
+		}
+	}
+}
+
+func TestFuzzyGrader(t *testing.T) {
+	expected := "A lightweight thread managed by the Go runtime"
+	tests := []struct {
+		answer string
+		want   fsrs.Rating
+	}{
+		{"a lightweight thread managed by the go runtime", fsrs.Good},
+		{"A lightwieght thread managed by the Go runtime", fsrs.Good},
+		{"lightweight thread managed by the runtime", fsrs.Hard},
+		{"a process", fsrs.Again},
+		{"", fsrs.Again},
+	}
+
+	for _, tt := range tests {
+		grade, err := FuzzyGrader{}.Grade("What is a goroutine?", expected, tt.answer)
+		if err != nil {
+			t.Fatalf("Grade(%q) failed: %v", tt.answer, err)
+		}
+		if grade.Rating != tt.want {
+			t.Errorf("Grade(%q) = %s (%s), want %s", tt.answer, RatingToString(grade.Rating), grade.Explanation, RatingToString(tt.want))
+		}
+	}
+}
+
+func TestGradeClozeCard(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "cell.md")
+	writeFile(cardPath, "The {{c1::mitochondria}} is the {{c2::powerhouse}} of the {{c1::cell}}.\n---\nBiology 101")
+
+	cards, err := ParseCards(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cloze cards, got %d", len(cards))
+	}
+	if cards[0].Expected() != "mitochondria, cell" || cards[1].Expected() != "powerhouse" {
+		t.Errorf("Expected only each card's deletions, got %q and %q", cards[0].Expected(), cards[1].Expected())
+	}
+
+	grade, err := ExactGrader{}.Grade(cards[1].Question, cards[1].Expected(), "Powerhouse")
+	if err != nil {
+		t.Fatalf("Grade failed: %v", err)
+	}
+	if grade.Rating != fsrs.Good {
+		t.Errorf("Expected the deletion alone to be graded Good, got %s", RatingToString(grade.Rating))
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"héllo", "hello", 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCommandGrader(t *testing.T) {
+	grader := CommandGrader{Command: `if [ "$SRS_ANSWER" = "$SRS_EXPECTED" ]; then echo "4 Spot on"; else cat >/dev/null; echo '{"rating": "Hard", "explanation": "Close"}'; fi`}
+
+	grade, err := grader.Grade("Capital of France?", "Paris", "Paris")
+	if err != nil {
+		t.Fatalf("Grade failed: %v", err)
+	}
+	if grade.Rating != fsrs.Easy || grade.Explanation != "Spot on" {
+		t.Errorf("Expected Easy with an explanation, got %+v", grade)
+	}
+
+	grade, err = grader.Grade("Capital of France?", "Paris", "Pari")
+	if err != nil {
+		t.Fatalf("Grade failed: %v", err)
+	}
+	if grade.Rating != fsrs.Hard || grade.Explanation != "Close" {
+		t.Errorf("Expected Hard from the JSON verdict, got %+v", grade)
+	}
+
+	for _, command := range []string{"exit 1", "echo maybe", "true"} {
+		if _, err := (CommandGrader{Command: command}).Grade("Q", "A", "A"); err == nil {
+			t.Errorf("Expected %q to fail to grade", command)
+		}
+	}
+
+	// A background process keeping the output open can't outlast the timeout
+	defer func(timeout time.Duration) { GradeTimeout = timeout }(GradeTimeout)
+	GradeTimeout = 100 * time.Millisecond
+	start := time.Now()
+	if _, err := (CommandGrader{Command: "sleep 60 & wait"}).Grade("Q", "A", "A"); err == nil {
+		t.Error("Expected a hung grader to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the grader to be stopped promptly, took %s", elapsed)
+	}
+}
+
+func TestNewGrader(t *testing.T) {
+	for _, name := range []string{GraderExact, GraderFuzzy} {
+		if _, err := NewGrader(name, ""); err != nil {
+			t.Errorf("NewGrader(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := NewGrader(GraderCommand, ""); err == nil {
+		t.Error("Expected the command grader to need a command")
+	}
+	if _, err := NewGrader("llm", ""); err == nil {
+		t.Error("Expected an unknown grader to be refused")
+	}
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// DataDirName is the hidden directory under a base deck that holds srs data
+const DataDirName = ".srs"
+
+// HistoryFileName is the append-only review journal inside DataDirName
+const HistoryFileName = "reviews.jsonl"
+
+// ReviewRecord is a single entry in the review journal
+type ReviewRecord struct {
+	CardID        string    `json:"card"`
+	Time          time.Time `json:"time"`
+	Rating        int       `json:"rating"`
+	State         string    `json:"state"`
+	ElapsedDays   uint64    `json:"elapsed_days"`
+	ScheduledDays uint64    `json:"scheduled_days"`
+}
+
+// NewReviewRecord builds a journal entry for the given card from an FSRS review log
+func NewReviewRecord(cardID string, log fsrs.ReviewLog) ReviewRecord {
+	return ReviewRecord{
+		CardID:        cardID,
+		Time:          log.Review,
+		Rating:        int(log.Rating),
+		State:         StateToString(log.State),
+		ElapsedDays:   log.ElapsedDays,
+		ScheduledDays: log.ScheduledDays,
+	}
+}
+
+// ReviewLog converts the journal entry back into an FSRS review log
+func (r ReviewRecord) ReviewLog() fsrs.ReviewLog {
+	return fsrs.ReviewLog{
+		Rating:        fsrs.Rating(r.Rating),
+		ScheduledDays: r.ScheduledDays,
+		ElapsedDays:   r.ElapsedDays,
+		Review:        r.Time,
+		State:         StringToState(r.State),
+	}
+}
+
+// InitDeckRoot creates the data directory under a base deck
+func InitDeckRoot(root string) error {
+	return os.MkdirAll(filepath.Join(root, DataDirName), 0755)
+}
+
+// FindDeckRoot walks up from path to the nearest directory containing a data
+// directory, returning "" if there is none
+func FindDeckRoot(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, DataDirName)); err == nil && info.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// HistoryPath returns the location of the review journal for a base deck
+func HistoryPath(root string) string {
+	return filepath.Join(root, DataDirName, HistoryFileName)
+}
+
+// AppendReview appends a record to the review journal of a base deck
+func AppendReview(root string, record ReviewRecord) error {
+	return AppendReviews(root, []ReviewRecord{record})
+}
+
+// AppendReviews appends records to the review journal of a base deck in one
+// write
+func AppendReviews(root string, records []ReviewRecord) error {
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	file, err := os.OpenFile(HistoryPath(root), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
+
+// LoadHistory reads the review journal of a base deck, grouped by card ID
+func LoadHistory(root string) (map[string][]ReviewRecord, error) {
+	records, err := loadHistoryRecords(root)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string][]ReviewRecord)
+	for _, record := range records {
+		history[record.CardID] = append(history[record.CardID], record)
+	}
+
+	return history, nil
+}
+
+// loadHistoryRecords reads the review journal of a base deck in file order
+func loadHistoryRecords(root string) ([]ReviewRecord, error) {
+	var records []ReviewRecord
+
+	file, err := os.Open(HistoryPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record ReviewRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", HistoryPath(root), lineNum, err)
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// rewriteHistory replaces the journal with the result of applying fn to each
+// record, dropping records for which fn returns false
+func rewriteHistory(root string, fn func(ReviewRecord) (ReviewRecord, bool)) error {
+	history, err := loadHistoryRecords(root)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, record := range history {
+		record, keep := fn(record)
+		if !keep {
+			continue
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	// Write to a temporary file first so a failure can't truncate the journal
+	tmpPath := HistoryPath(root) + ".tmp"
+	if err := os.WriteFile(tmpPath, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, HistoryPath(root))
+}
+
+// Key returns the identifier the card's reviews are journaled under
+func (c *Card) Key() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.pathKey()
+}
+
+// pathKey keys a card that hasn't been written with an ID yet: its path
+// relative to its base deck, which keyed the journal before cards had IDs,
+// followed by its block, cloze index and direction when it shares its file
+func (c *Card) pathKey() string {
+	key := c.relativePath()
+	if c.Block > 0 {
+		key += fmt.Sprintf(":%d", c.Block+1)
+	}
+	if c.Cloze > 0 {
+		key += fmt.Sprintf(":c%d", c.Cloze)
+	}
+	if c.Reverse {
+		key += ":reverse"
+	}
+	return key
+}
+
+// History returns the card's records from a loaded journal, including any
+// still keyed by its path from before it had an ID, oldest first
+func (c *Card) History(history map[string][]ReviewRecord) []ReviewRecord {
+	records := history[c.Key()]
+	if legacy := c.pathKey(); legacy != c.Key() && len(history[legacy]) > 0 {
+		records = append(append([]ReviewRecord(nil), history[legacy]...), records...)
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Time.Before(records[j].Time)
+		})
+	}
+	return records
+}
+
+// relativePath is the card file's path relative to its base deck
+func (c *Card) relativePath() string {
+	if c.Root == "" {
+		return c.FilePath
+	}
+
+	rel, err := filepath.Rel(c.Root, c.FilePath)
+	if err != nil {
+		return c.FilePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ApplyReview stores a scheduling outcome on the card, writing the new state
+// to the card file and the review to the deck's journal, and remembering the
+// change so UndoLastReview can revert it
+func (c *Card) ApplyReview(info fsrs.SchedulingInfo) error {
+	// Give the card its ID first, so undoing the rating doesn't take it away
+	if c.ID == "" {
+		if err := c.UpdateFSRSMetadata(); err != nil {
+			return err
+		}
+	}
+
+	before, err := os.ReadFile(c.FilePath)
+	if err != nil {
+		return err
+	}
+	previous := c.FSRSCard
+
+	c.FSRSCard = info.Card
+	c.ReviewLog = append(c.ReviewLog, info.ReviewLog)
+
+	if err := c.UpdateFSRSMetadata(); err != nil {
+		return err
+	}
+
+	if c.Root == "" {
+		return nil
+	}
+
+	record := NewReviewRecord(c.Key(), info.ReviewLog)
+	if err := AppendReview(c.Root, record); err != nil {
+		return err
+	}
+
+	after, err := os.ReadFile(c.FilePath)
+	if err != nil {
+		return err
+	}
+	return pushUndo(c.Root, UndoRecord{
+		CardID:   c.Key(),
+		FilePath: c.relativePath(),
+		Before:   string(before),
+		After:    string(after),
+		Previous: previous,
+		Review:   record,
+	})
+}
+
+// attachHistory rehydrates the card's review log from a loaded journal
+func (c *Card) attachHistory(history map[string][]ReviewRecord) {
+	records := c.History(history)
+	if len(records) == 0 {
+		return
+	}
+
+	c.ReviewLog = make([]fsrs.ReviewLog, 0, len(records))
+	for _, record := range records {
+		c.ReviewLog = append(c.ReviewLog, record.ReviewLog())
+	}
+
+	// Metadata comments from before last_review was stored lack the last
+	// review time, so recover it from the journal to keep elapsed-day
+	// calculations correct
+	if c.FSRSCard.State != fsrs.New && c.FSRSCard.LastReview.IsZero() {
+		c.FSRSCard.LastReview = records[len(records)-1].Time
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAppendAndLoadHistory(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+
+	reviewed := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []ReviewRecord{
+		{CardID: "a.md", Time: reviewed, Rating: 3, State: "New"},
+		{CardID: "b.md", Time: reviewed, Rating: 1, State: "Review", ElapsedDays: 4, ScheduledDays: 5},
+		{CardID: "a.md", Time: reviewed.Add(time.Hour), Rating: 4, State: "Learning"},
+	}
+	for _, record := range records {
+		if err := AppendReview(root, record); err != nil {
+			t.Fatalf("AppendReview failed: %v", err)
+		}
+	}
+
+	history, err := LoadHistory(root)
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+
+	if len(history["a.md"]) != 2 {
+		t.Errorf("Expected 2 records for a.md, got %d", len(history["a.md"]))
+	}
+	if len(history["b.md"]) != 1 {
+		t.Fatalf("Expected 1 record for b.md, got %d", len(history["b.md"]))
+	}
+
+	log := history["b.md"][0].ReviewLog()
+	if log.Rating != fsrs.Again || log.State != fsrs.Review || log.ElapsedDays != 4 || log.ScheduledDays != 5 {
+		t.Errorf("Unexpected review log %+v", log)
+	}
+	if !log.Review.Equal(reviewed) {
+		t.Errorf("Expected review time %v, got %v", reviewed, log.Review)
+	}
+}
+
+func TestLoadHistoryMissingJournal(t *testing.T) {
+	history, err := LoadHistory(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Expected empty history, got %d entries", len(history))
+	}
+}
+
+func TestFindDeckRoot(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "spanish", "verbs")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create dirs: %v", err)
+	}
+
+	if got := FindDeckRoot(nested); got != "" {
+		t.Errorf("Expected no deck root before init, got %q", got)
+	}
+
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+
+	if got := FindDeckRoot(nested); got != root {
+		t.Errorf("Expected deck root %q, got %q", root, got)
+	}
+}
+
+func TestRateCardPersistsHistory(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+
+	cardPath := filepath.Join(root, "spanish", "hola.md")
+	os.MkdirAll(filepath.Dir(cardPath), 0755)
+	if err := writeFile(cardPath, "Hola?\n---\nHello"); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	card, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	if card.Key() != "spanish/hola.md" {
+		t.Errorf("Expected an unwritten card to be keyed by its path, got %q", card.Key())
+	}
+
+	session := NewReviewSession([]*Card{card})
+	if err := session.RateCard(fsrs.Good); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+	if card.ID == "" || card.Key() != card.ID {
+		t.Errorf("Expected rating to give the card an ID, got key %q", card.Key())
+	}
+
+	// Both a single parse and a deck scan should see the review
+	reloaded, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	if len(reloaded.ReviewLog) != 1 {
+		t.Fatalf("Expected 1 review log after reload, got %d", len(reloaded.ReviewLog))
+	}
+	if reloaded.ReviewLog[0].Rating != fsrs.Good {
+		t.Errorf("Expected Good rating, got %v", reloaded.ReviewLog[0].Rating)
+	}
+
+	cards, err := FindCards(root)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("Expected 1 card, got %d", len(cards))
+	}
+	if len(cards[0].ReviewLog) != 1 {
+		t.Errorf("Expected 1 review log from FindCards, got %d", len(cards[0].ReviewLog))
+	}
+	if cards[0].FSRSCard.LastReview.IsZero() {
+		t.Error("Expected last review time to be recovered from history")
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID: a 48-bit millisecond timestamp followed by 80
+// random bits, encoded as 26 sortable characters
+func NewID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128 bits don't divide evenly into 5-bit groups, so the first character
+	// only carries the top 3 bits
+	var id [26]byte
+	for i := range id {
+		bit := i*5 - 2
+		var value byte
+		for j := 0; j < 5; j++ {
+			pos := bit + j
+			value <<= 1
+			if pos >= 0 && b[pos/8]&(0x80>>(pos%8)) != 0 {
+				value |= 1
+			}
+		}
+		id[i] = crockford[value]
+	}
+
+	return string(id[:])
+}
+
+// idTime returns when a ULID was made, from its timestamp
+func idTime(id string) (time.Time, bool) {
+	if len(id) != 26 {
+		return time.Time{}, false
+	}
+	var ms int64
+	for _, c := range id[:10] {
+		value := strings.IndexRune(crockford, c)
+		if value < 0 {
+			return time.Time{}, false
+		}
+		ms = ms<<5 | int64(value)
+	}
+	return time.UnixMilli(ms), true
+}
+
+// assignID gives a card without an ID a new one. Cards get their IDs when
+// they're first written, so reading a deck never changes it.
+func (c *Card) assignID() {
+	if c.ID == "" {
+		c.ID = NewID()
+	}
+}
+
+// assignIDs writes IDs to the cards in card's file that don't have one yet
+// and updates card with its own. Reviews journaled under the cards' path keys
+// are re-keyed to their IDs, rewriting the journal only if there are any.
+func assignIDs(card *Card) error {
+	cards, err := ParseCards(card.FilePath)
+	if err != nil {
+		return err
+	}
+
+	var history map[string][]ReviewRecord
+	if card.Root != "" {
+		if history, err = LoadHistory(card.Root); err != nil {
+			return err
+		}
+	}
+
+	rekeyed := make(map[string]string)
+	for _, c := range cards {
+		oldKey := c.pathKey()
+		if c.ID == "" {
+			if err := c.UpdateFSRSMetadata(); err != nil {
+				return err
+			}
+		}
+		if len(history[oldKey]) > 0 {
+			rekeyed[oldKey] = c.ID
+		}
+		if card.ID == "" && c.samePosition(card) {
+			card.ID = c.ID
+		}
+	}
+
+	if len(rekeyed) == 0 {
+		return nil
+	}
+	return rewriteHistory(card.Root, func(record ReviewRecord) (ReviewRecord, bool) {
+		if id, ok := rekeyed[record.CardID]; ok {
+			record.CardID = id
+		}
+		return record, true
+	})
+}
+
+// FindCardByID looks up a card by its stable ID within a deck, or by its path
+// key if it hasn't been written with an ID yet
+func FindCardByID(deckPath, id string) (*Card, error) {
+	cards, err := FindCards(deckPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, card := range cards {
+		if card.Key() == id {
+			return card, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no card with ID %s in %s", id, deckPath)
+}
+
+// MoveCard moves a card file to destPath, keeping its ID and history. Reviews
+// journaled under the card's old path before it had an ID are re-keyed.
+func MoveCard(card *Card, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("%s already exists", destPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+
+	// The IDs written to the file are what keep its cards' histories once
+	// their paths change
+	if err := assignIDs(card); err != nil {
+		return err
+	}
+	if err := os.Rename(card.FilePath, destPath); err != nil {
+		return err
+	}
+	card.FilePath = destPath
+	return nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestNewID(t *testing.T) {
+	first := NewID()
+	if len(first) != 26 {
+		t.Fatalf("Expected 26 character ID, got %q", first)
+	}
+	for _, c := range first {
+		if !strings.ContainsRune(crockford, c) {
+			t.Errorf("ID %q contains non-Crockford character %q", first, c)
+		}
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second := NewID()
+	if second <= first {
+		t.Errorf("Expected IDs to sort by creation time, got %q then %q", first, second)
+	}
+}
+
+func TestIDTime(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	created, ok := idTime(NewID())
+	if !ok || created.Before(before) || created.After(time.Now()) {
+		t.Errorf("Expected the ID's creation time, got %v", created)
+	}
+	for _, id := range []string{"", "card.md", "01M5!PTAHMDW76ZZ2A27ZH228A"} {
+		if _, ok := idTime(id); ok {
+			t.Errorf("Expected %q to have no creation time", id)
+		}
+	}
+}
+
+func TestCardGetsIDWhenWritten(t *testing.T) {
+	cardPath := filepath.Join(t.TempDir(), "card.md")
+	writeFile(cardPath, "Question\n---\nAnswer")
+
+	// Reading a card never writes to it
+	card, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	content, _ := os.ReadFile(cardPath)
+	if card.ID != "" || string(content) != "Question\n---\nAnswer" {
+		t.Fatalf("Expected parsing to leave the card alone, got ID %q and %q", card.ID, content)
+	}
+
+	if err := card.UpdateFSRSMetadata(); err != nil {
+		t.Fatalf("UpdateFSRSMetadata failed: %v", err)
+	}
+	if card.ID == "" {
+		t.Fatal("Expected a written card to be assigned an ID")
+	}
+
+	content, _ = os.ReadFile(cardPath)
+	if !strings.Contains(string(content), "id:"+card.ID) {
+		t.Errorf("Expected ID to be written to the metadata comment, got %q", content)
+	}
+
+	reparsed, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	if reparsed.ID != card.ID {
+		t.Errorf("Expected ID %q to survive a reparse, got %q", card.ID, reparsed.ID)
+	}
+	if reparsed.Question != "Question" || reparsed.Answer != "Answer" {
+		t.Errorf("Expected card content to be preserved, got %q / %q", reparsed.Question, reparsed.Answer)
+	}
+}
+
+func TestMoveCardKeepsHistory(t *testing.T) {
+	root := t.TempDir()
+	InitDeckRoot(root)
+
+	cardPath := filepath.Join(root, "inbox", "card.md")
+	os.MkdirAll(filepath.Dir(cardPath), 0755)
+	writeFile(cardPath, "Question\n---\nAnswer")
+
+	card, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+
+	// A review journaled under the old path-based key, before IDs existed
+	AppendReview(root, ReviewRecord{CardID: "inbox/card.md", Time: time.Now().Add(-time.Hour), Rating: 3, State: "New"})
+
+	session := NewReviewSession([]*Card{card})
+	if err := session.RateCard(fsrs.Good); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+
+	destPath := filepath.Join(root, "spanish", "card.md")
+	if err := MoveCard(card, destPath); err != nil {
+		t.Fatalf("MoveCard failed: %v", err)
+	}
+
+	moved, err := FindCardByID(root, card.ID)
+	if err != nil {
+		t.Fatalf("FindCardByID failed: %v", err)
+	}
+	if moved.FilePath != destPath {
+		t.Errorf("Expected card at %s, got %s", destPath, moved.FilePath)
+	}
+	if len(moved.ReviewLog) != 2 {
+		t.Errorf("Expected both reviews to follow the card, got %d", len(moved.ReviewLog))
+	}
+
+	// With nothing left under a path key, moving leaves the journal alone
+	journal, _ := os.Stat(HistoryPath(root))
+	again := filepath.Join(root, "archive", "card.md")
+	if err := MoveCard(moved, again); err != nil {
+		t.Fatalf("MoveCard failed: %v", err)
+	}
+	if after, _ := os.Stat(HistoryPath(root)); !os.SameFile(journal, after) {
+		t.Error("Expected moving an ID'd card not to rewrite the journal")
+	}
+
+	writeFile(filepath.Join(root, "other.md"), "Q\n---\nA")
+	if err := MoveCard(moved, filepath.Join(root, "other.md")); err == nil {
+		t.Error("Expected error when moving onto an existing card")
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// DefaultLearnAhead is how early a learning card may be shown once nothing
+// else is left to review
+const DefaultLearnAhead = 20 * time.Minute
+
+// ParseLearnAhead parses a learn-ahead window such as 20m, 1h or 0
+func ParseLearnAhead(value string) (time.Duration, error) {
+	if value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid learn-ahead window %q (want e.g. 20m, 1h or 0)", value)
+	}
+	return d, nil
+}
+
+// IsLearning reports whether a card is in a learning or relearning step
+func IsLearning(card *Card) bool {
+	return card.FSRSCard.State == fsrs.Learning || card.FSRSCard.State == fsrs.Relearning
+}
+
+// Requeue appends the cards in queue[:next] that are due by now to the end
+// of the queue, unless they are already waiting in queue[next:]
+func Requeue(queue []*Card, next int, now time.Time) []*Card {
+	queued := make(map[string]bool)
+	for _, card := range queue[next:] {
+		queued[card.Key()] = true
+	}
+
+	for _, card := range queue[:next] {
+		if queued[card.Key()] || card.FSRSCard.Due.After(now) {
+			continue
+		}
+		queued[card.Key()] = true
+		queue = append(queue, card)
+	}
+	return queue
+}
+
+// PendingLearning returns the learning cards in queue[:next] that aren't
+// waiting in queue[next:], soonest due first. These are the cards a session
+// still has to come back to.
+func PendingLearning(queue []*Card, next int) []*Card {
+	seen := make(map[string]bool)
+	for _, card := range queue[next:] {
+		seen[card.Key()] = true
+	}
+
+	var pending []*Card
+	for _, card := range queue[:next] {
+		if seen[card.Key()] || !IsLearning(card) {
+			continue
+		}
+		seen[card.Key()] = true
+		pending = append(pending, card)
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].FSRSCard.Due.Before(pending[j].FSRSCard.Due)
+	})
+	return pending
+}
+
+// LearnAheadCards returns the learning cards that come due within the
+// learn-ahead window, for when nothing else is due, soonest first
+func LearnAheadCards(cards []*Card, now time.Time, learnAhead time.Duration) []*Card {
+	var ahead []*Card
+	for _, card := range cards {
+		if IsLearning(card) && !card.Suspended() && !card.FSRSCard.Due.After(now.Add(learnAhead)) {
+			ahead = append(ahead, card)
+		}
+	}
+
+	sort.SliceStable(ahead, func(i, j int) bool {
+		return ahead[i].FSRSCard.Due.Before(ahead[j].FSRSCard.Due)
+	})
+	return ahead
+}
+
+// FormatWait formats the time until a card is due, e.g. "3m" or "1h20m"
+func FormatWait(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d <= 0:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()+0.5))
+	case d < 24*time.Hour:
+		d = d.Round(time.Minute)
+		if m := int(d.Minutes()) % 60; m != 0 {
+			return fmt.Sprintf("%dh%dm", int(d.Hours()), m)
+		}
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24+0.5))
+}
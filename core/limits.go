@@ -0,0 +1,203 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// deckLimit is the remaining daily allowance of a deck that sets limits in
+// its settings file. A nil allowance is unlimited.
+type deckLimit struct {
+	dir         string
+	newLeft     *int
+	reviewsLeft *int
+}
+
+// ApplyDailyLimits trims the due cards of the deck at deckPath to the
+// new_per_day and reviews_per_day limits of their decks, counting the reviews
+// already done today from each card's history. A deck's limits cap every
+// card beneath it, so parent limits apply to their children too. Learning
+// and relearning cards are never held back.
+func ApplyDailyLimits(deckPath string, cards, due []*Card, now time.Time) ([]*Card, error) {
+	deckPath, err := filepath.Abs(deckPath)
+	if err != nil {
+		return nil, err
+	}
+	root := FindDeckRoot(deckPath)
+
+	limits := make(map[string]*deckLimit)
+	cardLimits := func(card *Card) ([]*deckLimit, error) {
+		return limitsFor(filepath.Dir(card.FilePath), root, deckPath, limits)
+	}
+
+	// Settle the limits for every due card first, to know how far up they reach
+	top := deckPath
+	limited := false
+	for _, card := range due {
+		chain, err := cardLimits(card)
+		if err != nil {
+			return nil, err
+		}
+		for _, limit := range chain {
+			limited = true
+			if isWithin(limit.dir, top) {
+				top = limit.dir
+			}
+		}
+	}
+	if !limited {
+		return due, nil
+	}
+
+	// Limits set above the deck being reviewed count reviews in its siblings
+	counted := cards
+	if top != deckPath {
+		if counted, err = FindCards(top); err != nil {
+			return nil, err
+		}
+	}
+
+	dayStart := startOfDay(now)
+	for _, card := range counted {
+		chain, err := cardLimits(card)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range card.ReviewLog {
+			if log.Review.Before(dayStart) {
+				continue
+			}
+			for _, limit := range chain {
+				switch log.State {
+				case fsrs.New:
+					spend(limit.newLeft)
+				case fsrs.Review:
+					spend(limit.reviewsLeft)
+				}
+			}
+		}
+	}
+
+	var allowed []*Card
+	for _, card := range due {
+		chain, _ := cardLimits(card)
+
+		var allowance func(*deckLimit) *int
+		switch card.FSRSCard.State {
+		case fsrs.New:
+			allowance = func(limit *deckLimit) *int { return limit.newLeft }
+		case fsrs.Review:
+			allowance = func(limit *deckLimit) *int { return limit.reviewsLeft }
+		default:
+			allowed = append(allowed, card)
+			continue
+		}
+
+		ok := true
+		for _, limit := range chain {
+			if left := allowance(limit); left != nil && *left <= 0 {
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+		for _, limit := range chain {
+			spend(allowance(limit))
+		}
+		allowed = append(allowed, card)
+	}
+
+	return allowed, nil
+}
+
+// DailyLimits returns the new_per_day and reviews_per_day limits capping the
+// deck at deckPath as a whole: the tightest of those set in it and every
+// deck above it, as ApplyDailyLimits applies them. Negative means no limit.
+func DailyLimits(deckPath string) (newPerDay, reviewsPerDay int, err error) {
+	deckPath, err = filepath.Abs(deckPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	chain, err := limitsFor(deckPath, FindDeckRoot(deckPath), deckPath, make(map[string]*deckLimit))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	newPerDay, reviewsPerDay = -1, -1
+	for _, limit := range chain {
+		newPerDay = tighter(newPerDay, limit.newLeft)
+		reviewsPerDay = tighter(reviewsPerDay, limit.reviewsLeft)
+	}
+	return newPerDay, reviewsPerDay, nil
+}
+
+// tighter returns the lower of two limits, where a negative current limit
+// and a nil one mean no limit
+func tighter(current int, limit *int) int {
+	if limit == nil || current >= 0 && current <= *limit {
+		return current
+	}
+	return max(*limit, 0)
+}
+
+// limitsFor returns the limits that apply to cards in dir: those set in dir
+// and every ancestor up to the deck root, or up to deckPath outside a root
+func limitsFor(dir, root, deckPath string, limits map[string]*deckLimit) ([]*deckLimit, error) {
+	top := root
+	if top == "" {
+		top = deckPath
+	}
+
+	var chain []*deckLimit
+	for current := dir; ; current = filepath.Dir(current) {
+		limit, ok := limits[current]
+		if !ok {
+			settings, err := LoadDeckSettings(current)
+			if err != nil {
+				return nil, err
+			}
+			if settings.NewPerDay != nil || settings.ReviewsPerDay != nil {
+				limit = &deckLimit{dir: current, newLeft: copyInt(settings.NewPerDay), reviewsLeft: copyInt(settings.ReviewsPerDay)}
+			}
+			limits[current] = limit
+		}
+		if limit != nil {
+			chain = append(chain, limit)
+		}
+
+		if current == top || filepath.Dir(current) == current || !isWithin(top, current) {
+			break
+		}
+	}
+	return chain, nil
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func spend(left *int) {
+	if left != nil {
+		*left--
+	}
+}
+
+func copyInt(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+// startOfDay returns local midnight at the start of the day containing t
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
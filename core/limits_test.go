@@ -0,0 +1,119 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestApplyDailyLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+	for _, dir := range []string{"spanish", "french"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	writeFile(filepath.Join(root, SettingsFileName), `{"new_per_day": 3}`)
+	writeFile(filepath.Join(root, "spanish", SettingsFileName), `{"new_per_day": 2, "reviews_per_day": 1}`)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	newCard := func(dir, name string) *Card {
+		return &Card{FilePath: filepath.Join(root, dir, name+".md"), FSRSCard: fsrs.Card{State: fsrs.New}}
+	}
+	reviewCard := func(dir, name string) *Card {
+		return &Card{FilePath: filepath.Join(root, dir, name+".md"), FSRSCard: fsrs.Card{State: fsrs.Review}}
+	}
+
+	// One French card was already introduced today, and one yesterday
+	introduced := newCard("french", "done")
+	introduced.FSRSCard.State = fsrs.Learning
+	introduced.ReviewLog = []fsrs.ReviewLog{{State: fsrs.New, Review: now.Add(-time.Hour)}}
+	yesterday := reviewCard("french", "old")
+	yesterday.ReviewLog = []fsrs.ReviewLog{{State: fsrs.New, Review: now.Add(-24 * time.Hour)}}
+
+	due := []*Card{
+		newCard("spanish", "a"), newCard("spanish", "b"), newCard("spanish", "c"),
+		reviewCard("spanish", "d"), reviewCard("spanish", "e"),
+		newCard("french", "f"), newCard("french", "g"),
+		introduced,
+	}
+	cards := append([]*Card{yesterday}, due...)
+
+	allowed, err := ApplyDailyLimits(root, cards, due, now)
+	if err != nil {
+		t.Fatalf("ApplyDailyLimits failed: %v", err)
+	}
+
+	// Spanish takes 2 of the root's 3 new cards after the one done today, so
+	// no French new cards remain; learning cards are never held back
+	want := []string{"a", "b", "d", "done"}
+	if len(allowed) != len(want) {
+		t.Fatalf("Expected %d cards, got %d", len(want), len(allowed))
+	}
+	for i, card := range allowed {
+		if name := card.Name(); name != want[i] {
+			t.Errorf("Expected %s at %d, got %s", want[i], i, name)
+		}
+	}
+
+	// Reviewing a subdeck still counts new cards introduced in its siblings
+	// today against the parent's limit
+	for _, name := range []string{"x", "y", "z"} {
+		path := filepath.Join(root, "spanish", name+".md")
+		writeFile(path, "Q\n---\nA")
+		card, err := ParseCard(path)
+		if err != nil {
+			t.Fatalf("ParseCard failed: %v", err)
+		}
+		card.ApplyReview(fsrs.SchedulingInfo{
+			Card:      fsrs.Card{State: fsrs.Learning, Due: now},
+			ReviewLog: fsrs.ReviewLog{State: fsrs.New, Rating: fsrs.Good, Review: now.Add(-time.Minute)},
+		})
+	}
+
+	allowed, err = ApplyDailyLimits(filepath.Join(root, "french"), nil, []*Card{newCard("french", "f")}, now)
+	if err != nil {
+		t.Fatalf("ApplyDailyLimits failed: %v", err)
+	}
+	if len(allowed) != 0 {
+		t.Errorf("Expected the root limit to hold back the French card, got %d cards", len(allowed))
+	}
+}
+
+func TestDailyLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+	verbs := filepath.Join(root, "spanish", "verbs")
+	os.MkdirAll(verbs, 0755)
+	os.MkdirAll(filepath.Join(root, "french"), 0755)
+	writeFile(filepath.Join(root, SettingsFileName), `{"new_per_day": 3, "reviews_per_day": 50}`)
+	writeFile(filepath.Join(root, "spanish", SettingsFileName), `{"new_per_day": 10, "reviews_per_day": 20}`)
+
+	tests := []struct {
+		dir               string
+		newCards, reviews int
+	}{
+		{verbs, 3, 20},
+		{filepath.Join(root, "french"), 3, 50},
+		{root, 3, 50},
+	}
+	for _, tt := range tests {
+		newCards, reviews, err := DailyLimits(tt.dir)
+		if err != nil {
+			t.Fatalf("DailyLimits(%s) failed: %v", tt.dir, err)
+		}
+		if newCards != tt.newCards || reviews != tt.reviews {
+			t.Errorf("DailyLimits(%s) = %d, %d, want %d, %d", tt.dir, newCards, reviews, tt.newCards, tt.reviews)
+		}
+	}
+
+	if newCards, reviews, err := DailyLimits(t.TempDir()); err != nil || newCards != -1 || reviews != -1 {
+		t.Errorf("Expected no limits without settings, got %d, %d (%v)", newCards, reviews, err)
+	}
+}
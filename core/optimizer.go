@@ -0,0 +1,203 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// MinTrainingReviews is the fewest predictable reviews Optimize will train on
+const MinTrainingReviews = 32
+
+const (
+	optimizerSteps        = 200
+	optimizerLearningRate = 0.04
+)
+
+// weightBounds keeps each FSRS weight within the range used by the reference optimizer
+var weightBounds = [len(fsrs.Weights{})][2]float64{
+	{0.001, 100}, {0.001, 100}, {0.001, 100}, {0.001, 100},
+	{1, 10}, {0.001, 4}, {0.001, 4}, {0.001, 0.75},
+	{0, 4.5}, {0, 0.8}, {0.001, 3.5}, {0.001, 5},
+	{0.001, 0.25}, {0.001, 0.9}, {0, 4}, {0, 1},
+	{1, 6}, {0, 2}, {0, 2},
+}
+
+// OptimizeResult describes the weights fitted by Optimize and how well they predict recall
+type OptimizeResult struct {
+	Weights        fsrs.Weights
+	Reviews        int
+	InitialLogLoss float64
+	InitialRMSE    float64
+	LogLoss        float64
+	RMSE           float64
+}
+
+// TrainingSequences turns a review journal into time-ordered per-card sequences.
+// Only cards whose history starts from their first review can be replayed, so
+// cards that were already studied before journaling began are skipped.
+func TrainingSequences(history map[string][]ReviewRecord) [][]ReviewRecord {
+	keys := make([]string, 0, len(history))
+	for key := range history {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sequences [][]ReviewRecord
+	for _, key := range keys {
+		records := append([]ReviewRecord(nil), history[key]...)
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Time.Before(records[j].Time)
+		})
+
+		if len(records) < 2 || records[0].State != StateToString(fsrs.New) {
+			continue
+		}
+		sequences = append(sequences, records)
+	}
+
+	return sequences
+}
+
+// Evaluate replays the sequences with the given weights and reports the
+// log-loss and RMSE of the predicted recall probabilities
+func Evaluate(w fsrs.Weights, sequences [][]ReviewRecord) (logLoss, rmse float64, reviews int) {
+	params := fsrs.DefaultParam()
+	var squaredError float64
+
+	for _, seq := range sequences {
+		var stability, difficulty float64
+
+		for i, record := range seq {
+			rating := fsrs.Rating(record.Rating)
+			if rating < fsrs.Again || rating > fsrs.Easy {
+				break
+			}
+
+			if i == 0 {
+				stability = math.Max(w[rating-1], 0.1)
+				difficulty = initDifficulty(w, rating)
+				continue
+			}
+
+			elapsed := math.Floor(record.Time.Sub(seq[i-1].Time).Hours() / 24)
+			if elapsed <= 0 {
+				// Same-day reviews only adjust short-term stability
+				stability *= math.Exp(w[17] * (float64(rating-3) + w[18]))
+			} else {
+				retrievability := math.Pow(1+params.Factor*elapsed/stability, params.Decay)
+				predicted := math.Min(math.Max(retrievability, 1e-4), 1-1e-4)
+
+				recalled := 0.0
+				if rating > fsrs.Again {
+					recalled = 1
+				}
+				logLoss -= recalled*math.Log(predicted) + (1-recalled)*math.Log(1-predicted)
+				squaredError += (recalled - retrievability) * (recalled - retrievability)
+				reviews++
+
+				if rating == fsrs.Again {
+					forget := w[11] * math.Pow(difficulty, -w[12]) *
+						(math.Pow(stability+1, w[13]) - 1) *
+						math.Exp((1-retrievability)*w[14])
+					stability = math.Min(forget, stability/math.Exp(w[17]*w[18]))
+				} else {
+					hardPenalty, easyBonus := 1.0, 1.0
+					if rating == fsrs.Hard {
+						hardPenalty = w[15]
+					}
+					if rating == fsrs.Easy {
+						easyBonus = w[16]
+					}
+					stability *= 1 + math.Exp(w[8])*(11-difficulty)*
+						math.Pow(stability, -w[9])*
+						(math.Exp((1-retrievability)*w[10])-1)*
+						hardPenalty*easyBonus
+				}
+			}
+
+			difficulty = nextDifficulty(w, difficulty, rating)
+			stability = math.Min(math.Max(stability, 0.01), params.MaximumInterval)
+		}
+	}
+
+	if reviews == 0 {
+		return 0, 0, 0
+	}
+	return logLoss / float64(reviews), math.Sqrt(squaredError / float64(reviews)), reviews
+}
+
+// Optimize fits the FSRS weights to the review sequences by minimising
+// log-loss with Adam, starting from the initial weights
+func Optimize(sequences [][]ReviewRecord, initial fsrs.Weights) (*OptimizeResult, error) {
+	initialLoss, initialRMSE, reviews := Evaluate(initial, sequences)
+	if reviews < MinTrainingReviews {
+		return nil, fmt.Errorf("not enough review history to optimize: %d reviews, need at least %d", reviews, MinTrainingReviews)
+	}
+
+	loss := func(w fsrs.Weights) float64 {
+		l, _, _ := Evaluate(w, sequences)
+		return l
+	}
+
+	const beta1, beta2, epsilon = 0.9, 0.999, 1e-8
+	w := clampWeights(initial)
+	best, bestLoss := w, loss(w)
+	var m, v fsrs.Weights
+
+	for step := 1; step <= optimizerSteps; step++ {
+		// Central finite differences are cheap enough for 19 weights and keep
+		// the model code identical to the evaluation above
+		var grad fsrs.Weights
+		for i := range w {
+			h := 1e-5 * math.Max(1, math.Abs(w[i]))
+			plus, minus := w, w
+			plus[i] += h
+			minus[i] -= h
+			grad[i] = (loss(plus) - loss(minus)) / (2 * h)
+		}
+
+		for i := range w {
+			m[i] = beta1*m[i] + (1-beta1)*grad[i]
+			v[i] = beta2*v[i] + (1-beta2)*grad[i]*grad[i]
+			mHat := m[i] / (1 - math.Pow(beta1, float64(step)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(step)))
+			w[i] -= optimizerLearningRate * mHat / (math.Sqrt(vHat) + epsilon)
+		}
+		w = clampWeights(w)
+
+		if l := loss(w); l < bestLoss {
+			best, bestLoss = w, l
+		}
+	}
+
+	logLoss, rmse, _ := Evaluate(best, sequences)
+	return &OptimizeResult{
+		Weights:        best,
+		Reviews:        reviews,
+		InitialLogLoss: initialLoss,
+		InitialRMSE:    initialRMSE,
+		LogLoss:        logLoss,
+		RMSE:           rmse,
+	}, nil
+}
+
+func clampWeights(w fsrs.Weights) fsrs.Weights {
+	for i := range w {
+		w[i] = math.Min(math.Max(w[i], weightBounds[i][0]), weightBounds[i][1])
+	}
+	return w
+}
+
+func initDifficulty(w fsrs.Weights, rating fsrs.Rating) float64 {
+	return math.Min(math.Max(w[4]-math.Exp(w[5]*float64(rating-1))+1, 1), 10)
+}
+
+func nextDifficulty(w fsrs.Weights, difficulty float64, rating fsrs.Rating) float64 {
+	delta := -w[6] * float64(rating-3)
+	next := difficulty + (10-difficulty)*delta/9
+	next = w[7]*initDifficulty(w, fsrs.Easy) + (1-w[7])*next
+	return math.Min(math.Max(next, 1), 10)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// simulateHistory schedules cards with the real FSRS scheduler, recalling
+// each card when its retrievability under trueWeights is high enough
+func simulateHistory(trueWeights fsrs.Weights, cards, reviews int) map[string][]ReviewRecord {
+	params := fsrs.DefaultParam()
+	params.W = trueWeights
+	scheduler := fsrs.NewFSRS(params)
+	history := make(map[string][]ReviewRecord)
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	for c := 0; c < cards; c++ {
+		id := fmt.Sprintf("card%d.md", c)
+		card := fsrs.NewCard()
+		now := start
+
+		for r := 0; r < reviews; r++ {
+			rating := fsrs.Good
+			if card.State != fsrs.New {
+				retrievability := scheduler.GetRetrievability(card, now)
+				// Deterministic pseudo-random threshold per card and review
+				threshold := math.Mod(float64(c*7919+r*104729), 1000) / 1000
+				if retrievability < threshold {
+					rating = fsrs.Again
+				}
+			}
+
+			info := scheduler.Next(card, now, rating)
+			history[id] = append(history[id], NewReviewRecord(id, info.ReviewLog))
+			card = info.Card
+
+			// Review a little late so most reviews span at least a day
+			now = card.Due.Add(time.Duration(c%5+1) * 24 * time.Hour)
+		}
+	}
+
+	return history
+}
+
+func TestTrainingSequencesSkipsPartialHistory(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := map[string][]ReviewRecord{
+		"full.md": {
+			{CardID: "full.md", Time: now.Add(48 * time.Hour), Rating: 3, State: "Review"},
+			{CardID: "full.md", Time: now, Rating: 3, State: "New"},
+		},
+		"partial.md": {
+			{CardID: "partial.md", Time: now, Rating: 3, State: "Review"},
+			{CardID: "partial.md", Time: now.Add(48 * time.Hour), Rating: 3, State: "Review"},
+		},
+		"single.md": {
+			{CardID: "single.md", Time: now, Rating: 3, State: "New"},
+		},
+	}
+
+	sequences := TrainingSequences(history)
+	if len(sequences) != 1 {
+		t.Fatalf("Expected 1 training sequence, got %d", len(sequences))
+	}
+	if sequences[0][0].State != "New" {
+		t.Errorf("Expected sequence to be sorted by time, got first state %s", sequences[0][0].State)
+	}
+}
+
+func TestOptimizeNotEnoughHistory(t *testing.T) {
+	history := simulateHistory(fsrs.DefaultWeights(), 2, 3)
+	_, err := Optimize(TrainingSequences(history), fsrs.DefaultWeights())
+	if err == nil {
+		t.Error("Expected error when optimizing with too little history")
+	}
+}
+
+func TestOptimizeImprovesLogLoss(t *testing.T) {
+	trueWeights := fsrs.DefaultWeights()
+	trueWeights[0], trueWeights[1], trueWeights[2] = 0.1, 0.3, 0.8
+	trueWeights[8] = 1.0
+
+	sequences := TrainingSequences(simulateHistory(trueWeights, 40, 6))
+
+	result, err := Optimize(sequences, fsrs.DefaultWeights())
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+
+	t.Logf("log-loss %.4f -> %.4f, RMSE %.4f -> %.4f over %d reviews", result.InitialLogLoss, result.LogLoss, result.InitialRMSE, result.RMSE, result.Reviews)
+	if result.LogLoss > result.InitialLogLoss {
+		t.Errorf("Expected log-loss not to increase, got %.4f -> %.4f", result.InitialLogLoss, result.LogLoss)
+	}
+
+	for i, w := range result.Weights {
+		if w < weightBounds[i][0] || w > weightBounds[i][1] {
+			t.Errorf("Weight %d = %f outside bounds %v", i, w, weightBounds[i])
+		}
+	}
+}
+
+func TestSetWeights(t *testing.T) {
+	defer SetWeights(fsrs.DefaultWeights())
+
+	tuned := fsrs.DefaultWeights()
+	tuned[0] = 1.5
+	SetWeights(tuned)
+
+	if DefaultParameters().W[0] != 1.5 {
+		t.Errorf("Expected tuned weight 1.5, got %f", DefaultParameters().W[0])
+	}
+
+	card := &Card{FSRSCard: fsrs.NewCard()}
+	if card.Scheduler().W[0] != 1.5 {
+		t.Errorf("Expected card scheduler to use tuned weights, got %f", card.Scheduler().W[0])
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func queueNames(cards []*Card) string {
+	names := make([]string, len(cards))
+	for i, card := range cards {
+		names[i] = card.Name()
+	}
+	return strings.Join(names, " ")
+}
+
+func TestOrderQueue(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	review := func(path string, overdue time.Duration, stability, difficulty float64) *Card {
+		return &Card{
+			ID:       path,
+			FilePath: filepath.FromSlash(path + ".md"),
+			FSRSCard: fsrs.Card{
+				State:      fsrs.Review,
+				Due:        now.Add(-overdue),
+				LastReview: now.Add(-overdue - 24*time.Hour),
+				Stability:  stability,
+				Difficulty: difficulty,
+			},
+		}
+	}
+	fresh := func(path string) *Card {
+		return &Card{ID: path, FilePath: filepath.FromSlash(path + ".md"), FSRSCard: fsrs.Card{State: fsrs.New}}
+	}
+
+	cards := []*Card{
+		review("go/a", time.Hour, 10, 3),
+		review("go/b", 72*time.Hour, 1, 8),
+		fresh("go/n1"),
+		review("spanish/c", 24*time.Hour, 50, 5),
+		fresh("spanish/n2"),
+		review("spanish/d", 0, 0.5, 9),
+	}
+
+	tests := []struct {
+		order QueueOrder
+		want  string
+	}{
+		{QueueOrder{}, "a b n1 c n2 d"},
+		{QueueOrder{Sort: OrderDue}, "n1 n2 b c a d"},
+		{QueueOrder{Sort: OrderRetrievability}, "b d a c n1 n2"},
+		{QueueOrder{Sort: OrderDifficulty}, "d b c a n1 n2"},
+		{QueueOrder{Sort: OrderInterleaved}, "a c b n2 n1 d"},
+		{QueueOrder{NewCards: NewCardsAfter}, "a b c d n1 n2"},
+		{QueueOrder{Sort: OrderDue, NewCards: NewCardsMixed}, "b n1 c n2 a d"},
+	}
+
+	for _, test := range tests {
+		if got := queueNames(OrderQueue(cards, test.order, now)); got != test.want {
+			t.Errorf("%+v: expected %q, got %q", test.order, test.want, got)
+		}
+	}
+
+	// Random order is a shuffle that holds for the whole day
+	first := queueNames(OrderQueue(cards, QueueOrder{Sort: OrderRandom}, now))
+	if again := queueNames(OrderQueue(cards, QueueOrder{Sort: OrderRandom}, now.Add(time.Hour))); again != first {
+		t.Errorf("Expected the same shuffle later in the day, got %q then %q", first, again)
+	}
+	if len(strings.Fields(first)) != len(cards) {
+		t.Errorf("Expected every card in the shuffle, got %q", first)
+	}
+}
+
+func TestQueueOrderValidate(t *testing.T) {
+	if err := (QueueOrder{Sort: OrderDue, NewCards: NewCardsAfter}).Validate(); err != nil {
+		t.Errorf("Expected valid order, got %v", err)
+	}
+	if err := (QueueOrder{Sort: "alphabetical"}).Validate(); err == nil {
+		t.Error("Expected an unknown order to be rejected")
+	}
+	if err := (QueueOrder{NewCards: "before"}).Validate(); err == nil {
+		t.Error("Expected an unknown new card placement to be rejected")
+	}
+}
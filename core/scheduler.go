@@ -7,16 +7,42 @@ import (
 	"github.com/open-spaced-repetition/go-fsrs/v3"
 )
 
+// weights are the FSRS weights every scheduler is built with
+var weights = fsrs.DefaultWeights()
+
+// SetWeights replaces the FSRS weights used by every scheduler, e.g. with
+// weights tuned by Optimize
+func SetWeights(w fsrs.Weights) {
+	weights = w
+}
+
+// DefaultParameters returns the FSRS parameters schedulers start from
+func DefaultParameters() fsrs.Parameters {
+	params := fsrs.DefaultParam()
+	params.W = weights
+	return params
+}
+
 // NewReviewSession creates a new review session with the given cards
 func NewReviewSession(cards []*Card) *ReviewSession {
-	params := fsrs.DefaultParam()
 	return &ReviewSession{
-		scheduler: fsrs.NewFSRS(params),
-		cards:     cards,
-		current:   0,
+		cards:   cards,
+		current: 0,
 	}
 }
 
+// SetLearnAhead sets how early learning cards are shown once nothing else
+// is left in the session. By default they aren't shown before they're due.
+func (rs *ReviewSession) SetLearnAhead(d time.Duration) {
+	rs.learnAhead = d
+}
+
+// Pending returns the learning cards rated in this session that aren't due
+// yet, soonest first
+func (rs *ReviewSession) Pending() []*Card {
+	return PendingLearning(rs.cards, rs.current)
+}
+
 // CurrentCard returns the current card in the session
 func (rs *ReviewSession) CurrentCard() (*Card, error) {
 	if rs.current >= len(rs.cards) {
@@ -44,37 +70,20 @@ func (rs *ReviewSession) RateCard(rating fsrs.Rating) error {
 	card := rs.cards[rs.current]
 	now := time.Now()
 	
-	schedulingCards := rs.scheduler.Repeat(card.FSRSCard, now)
+	schedulingCards := card.Scheduler().Repeat(card.FSRSCard, now)
 	selectedInfo := schedulingCards[rating]
-	card.FSRSCard = selectedInfo.Card
-	
-	card.ReviewLog = append(card.ReviewLog, selectedInfo.ReviewLog)
 	
-	err := card.UpdateFSRSMetadata()
+	err := card.ApplyReview(selectedInfo)
 	if err != nil {
 		return fmt.Errorf("failed to update card metadata: %v", err)
 	}
 	
-	// Check all cards in the session to see if any have become due
-	// and add them to the end of the queue if they're not already in the remaining cards
-	remainingCards := rs.cards[rs.current+1:] // Cards we haven't reviewed yet
-	
-	for i := 0; i <= rs.current; i++ { // Check all cards we've seen so far
-		checkCard := rs.cards[i]
-		if checkCard.FSRSCard.Due.Before(now) || checkCard.FSRSCard.Due.Equal(now) {
-			// Check if this card is already in the remaining queue
-			alreadyQueued := false
-			for _, remainingCard := range remainingCards {
-				if remainingCard.FilePath == checkCard.FilePath {
-					alreadyQueued = true
-					break
-				}
-			}
-			
-			// If not already queued, add it to the end
-			if !alreadyQueued {
-				rs.cards = append(rs.cards, checkCard)
-			}
+	// Requeue cards that have become due, and once the queue runs out pull
+	// in the next learning card if it's due within the learn-ahead window
+	rs.cards = Requeue(rs.cards, rs.current+1, now)
+	if rs.current+1 >= len(rs.cards) {
+		if pending := PendingLearning(rs.cards, rs.current+1); len(pending) > 0 && !pending[0].FSRSCard.Due.After(now.Add(rs.learnAhead)) {
+			rs.cards = append(rs.cards, pending[0])
 		}
 	}
 	
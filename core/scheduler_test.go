@@ -3,6 +3,7 @@ package core
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/open-spaced-repetition/go-fsrs/v3"
 )
@@ -222,4 +223,71 @@ func TestUpdateCurrentCard(t *testing.T) {
 // Helper function to write file content
 func writeFile(path, content string) error {
 	return os.WriteFile(path, []byte(content), 0644)
-}
\ No newline at end of file
+}
+
+func TestRateCardLearnsAhead(t *testing.T) {
+	tmpDir := t.TempDir()
+	var cards []*Card
+	for _, name := range []string{"a", "b"} {
+		card := &Card{
+			Question: name,
+			Answer:   name,
+			FilePath: tmpDir + "/" + name + ".md",
+			FSRSCard: fsrs.NewCard(),
+		}
+		if err := writeFile(card.FilePath, name+"\n---\n"+name); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		cards = append(cards, card)
+	}
+
+	// Rating a new card Again leaves it in learning a minute from now
+	session := NewReviewSession(cards)
+	session.SetLearnAhead(DefaultLearnAhead)
+	if err := session.RateCard(fsrs.Again); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+	if pending := session.Pending(); len(pending) != 1 || pending[0] != cards[0] {
+		t.Fatalf("Expected card a pending, got %d cards", len(pending))
+	}
+
+	// Once b is done the queue runs out, so a is learned ahead
+	if err := session.RateCard(fsrs.Good); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+	card, err := session.CurrentCard()
+	if err != nil || card != cards[0] {
+		t.Fatalf("Expected card a to be learned ahead, got %v, %v", card, err)
+	}
+	if len(session.Pending()) != 1 {
+		t.Errorf("Expected only b pending while a is queued, got %d", len(session.Pending()))
+	}
+
+	// Without a learn-ahead window the session waits instead
+	session = NewReviewSession([]*Card{cards[1]})
+	if err := session.RateCard(fsrs.Again); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+	if session.HasNext() {
+		t.Error("Expected no card before the learning step is due")
+	}
+	if len(session.Pending()) != 1 {
+		t.Errorf("Expected 1 pending card, got %d", len(session.Pending()))
+	}
+}
+
+func TestFormatWait(t *testing.T) {
+	tests := map[time.Duration]string{
+		-time.Second:                "now",
+		30 * time.Second:            "30s",
+		3*time.Minute + time.Second: "3m",
+		90 * time.Minute:            "1h30m",
+		2 * time.Hour:               "2h",
+		72 * time.Hour:              "3d",
+	}
+	for d, want := range tests {
+		if got := FormatWait(d); got != want {
+			t.Errorf("FormatWait(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Query is a parsed search query. A card matches if it matches every term.
+type Query struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	negate bool
+	match  func(card *Card, now time.Time) bool
+}
+
+// predicatePattern matches field<op>value terms such as lapses>3
+var predicatePattern = regexp.MustCompile(`^([a-z_]+)(<=|>=|!=|:|=|<|>)(.+)$`)
+
+// numericFields are the FSRS fields that can be compared as numbers
+var numericFields = map[string]func(card *Card, now time.Time) float64{
+	"reps":       func(c *Card, _ time.Time) float64 { return float64(c.FSRSCard.Reps) },
+	"lapses":     func(c *Card, _ time.Time) float64 { return float64(c.FSRSCard.Lapses) },
+	"stability":  func(c *Card, _ time.Time) float64 { return c.FSRSCard.Stability },
+	"difficulty": func(c *Card, _ time.Time) float64 { return c.FSRSCard.Difficulty },
+	"elapsed":    func(c *Card, _ time.Time) float64 { return float64(c.FSRSCard.ElapsedDays) },
+	"interval":   func(c *Card, _ time.Time) float64 { return float64(c.FSRSCard.ScheduledDays) },
+	"retrievability": func(c *Card, now time.Time) float64 {
+		if c.FSRSCard.State == fsrs.New {
+			return 0
+		}
+		return c.Scheduler().GetRetrievability(c.FSRSCard, now)
+	},
+}
+
+// ParseQuery parses a search query. Plain words match the question and
+// answer text, case-insensitively; quote a phrase to match it as a whole.
+// Predicates filter on card fields:
+//
+//	state:review  tag:go  id:01JH3Q
+//	reps, lapses, stability, difficulty, elapsed, interval, retrievability
+//	  compared with : = != < <= > >=, e.g. lapses>3 stability<2
+//	due<7d  due>=2025-01-31  (days d, hours h or weeks w from now, or a date)
+//
+// Prefix any term with - to negate it.
+func ParseQuery(query string) (Query, error) {
+	var q Query
+	for _, token := range splitQuery(query) {
+		term := queryTerm{}
+		if len(token) > 1 && strings.HasPrefix(token, "-") {
+			term.negate = true
+			token = token[1:]
+		}
+
+		match, err := parseQueryTerm(token)
+		if err != nil {
+			return Query{}, err
+		}
+		term.match = match
+		q.terms = append(q.terms, term)
+	}
+	return q, nil
+}
+
+// Match reports whether a card matches every term of the query
+func (q Query) Match(card *Card, now time.Time) bool {
+	for _, term := range q.terms {
+		if term.match(card, now) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the cards matching the query, soonest due first
+func Search(cards []*Card, q Query, now time.Time) []*Card {
+	var matched []*Card
+	for _, card := range cards {
+		if q.Match(card, now) {
+			matched = append(matched, card)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].FSRSCard.Due.Before(matched[j].FSRSCard.Due)
+	})
+	return matched
+}
+
+// splitQuery splits a query on whitespace, keeping "quoted phrases" together
+func splitQuery(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func parseQueryTerm(token string) (func(*Card, time.Time) bool, error) {
+	match := predicatePattern.FindStringSubmatch(token)
+	if match == nil {
+		return textMatcher(token), nil
+	}
+	field, op, value := match[1], match[2], match[3]
+
+	if get, ok := numericFields[field]; ok {
+		want, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q for %s", value, field)
+		}
+		return func(card *Card, now time.Time) bool {
+			return compare(get(card, now), op, want)
+		}, nil
+	}
+
+	switch field {
+	case "state":
+		state, ok := parseStateName(value)
+		if !ok {
+			return nil, fmt.Errorf("unknown state %q (want new, learning, review or relearning)", value)
+		}
+		return equalityMatcher(op, func(card *Card, _ time.Time) bool {
+			return card.FSRSCard.State == state
+		})
+	case "tag":
+		return equalityMatcher(op, func(card *Card, _ time.Time) bool {
+			return card.HasTag(value)
+		})
+	case "id":
+		prefix := strings.ToUpper(value)
+		return equalityMatcher(op, func(card *Card, _ time.Time) bool {
+			return strings.HasPrefix(card.ID, prefix)
+		})
+	case "due":
+		offset, date, err := parseDue(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == ":" {
+			op = "<="
+		}
+		return func(card *Card, now time.Time) bool {
+			limit := date
+			if limit.IsZero() {
+				limit = now.Add(offset)
+			}
+			return compare(float64(card.FSRSCard.Due.Sub(limit)), op, 0)
+		}, nil
+	}
+
+	// Unknown fields, as in "note:this", are searched for as text
+	return textMatcher(token), nil
+}
+
+// textMatcher matches cards whose question or answer contains text
+func textMatcher(text string) func(*Card, time.Time) bool {
+	text = strings.ToLower(text)
+	return func(card *Card, _ time.Time) bool {
+		return strings.Contains(strings.ToLower(card.Question), text) ||
+			strings.Contains(strings.ToLower(card.Answer), text)
+	}
+}
+
+// equalityMatcher applies an = or != comparison to a yes/no field
+func equalityMatcher(op string, match func(*Card, time.Time) bool) (func(*Card, time.Time) bool, error) {
+	switch op {
+	case ":", "=":
+		return match, nil
+	case "!=":
+		return func(card *Card, now time.Time) bool { return !match(card, now) }, nil
+	}
+	return nil, fmt.Errorf("operator %s can't be used here, only : = !=", op)
+}
+
+func compare(got float64, op string, want float64) bool {
+	switch op {
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "!=":
+		return got != want
+	}
+	return got == want
+}
+
+func parseStateName(name string) (fsrs.State, bool) {
+	switch strings.ToLower(name) {
+	case "new":
+		return fsrs.New, true
+	case "learning":
+		return fsrs.Learning, true
+	case "review":
+		return fsrs.Review, true
+	case "relearning":
+		return fsrs.Relearning, true
+	}
+	return fsrs.New, false
+}
+
+// parseDue parses a due value: an offset from now such as 7d, 12h, 2w or
+// -3d, "now", or a YYYY-MM-DD date
+func parseDue(value string) (time.Duration, time.Time, error) {
+	if value == "now" {
+		return 0, time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return 0, date, nil
+	}
+
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[value[len(value)-1]]; ok {
+		if n, err := strconv.ParseFloat(value[:len(value)-1], 64); err == nil {
+			return time.Duration(n * float64(unit)), time.Time{}, nil
+		}
+	}
+	return 0, time.Time{}, fmt.Errorf("invalid due %q (want e.g. 7d, 12h, 2w, now or 2025-01-31)", value)
+}
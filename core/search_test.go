@@ -0,0 +1,65 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestSearch(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cards := []*Card{
+		{ID: "A", Question: "What is a goroutine?", Answer: "A lightweight thread", Tags: []string{"go"},
+			FSRSCard: fsrs.Card{State: fsrs.Review, Lapses: 5, Stability: 1.5, Due: now.Add(48 * time.Hour)}},
+		{ID: "B", Question: "What is a channel?", Answer: "A typed conduit", Tags: []string{"go"},
+			FSRSCard: fsrs.Card{State: fsrs.Review, Lapses: 1, Stability: 30, Due: now.Add(20 * 24 * time.Hour)}},
+		{ID: "C", Question: "Capital of France?", Answer: "Paris",
+			FSRSCard: fsrs.Card{State: fsrs.New, Due: now.Add(-time.Hour)}},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"C", "A", "B"}},
+		{"GOROUTINE", []string{"A"}},
+		{"conduit", []string{"B"}},
+		{`"typed conduit"`, []string{"B"}},
+		{"state:review lapses>3", []string{"A"}},
+		{"stability<2", []string{"C", "A"}},
+		{"due<7d", []string{"C", "A"}},
+		{"due<now", []string{"C"}},
+		{"due>=2025-03-10", []string{"B"}},
+		{"tag:go -goroutine", []string{"B"}},
+		{"state!=new what", []string{"A", "B"}},
+		{"id:c", []string{"C"}},
+	}
+
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", test.query, err)
+			continue
+		}
+
+		got := Search(cards, q, now)
+		if len(got) != len(test.want) {
+			t.Errorf("%q: expected %v, got %d cards", test.query, test.want, len(got))
+			continue
+		}
+		for i, card := range got {
+			if card.ID != test.want[i] {
+				t.Errorf("%q: expected %s at %d, got %s", test.query, test.want[i], i, card.ID)
+			}
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{"state:done", "lapses>many", "due<soon", "tag>go"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("Expected ParseQuery(%q) to fail", query)
+		}
+	}
+}
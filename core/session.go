@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// SessionsFileName is the log of finished review sessions inside DataDirName
+const SessionsFileName = "sessions.jsonl"
+
+// SessionRecord summarizes one review session
+type SessionRecord struct {
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Deck    string          `json:"deck"` // reviewed deck, relative to the base deck
+	Reviews []SessionReview `json:"reviews"`
+}
+
+// SessionReview is a rating made during a session and how long it took
+type SessionReview struct {
+	ReviewRecord
+	Seconds float64 `json:"seconds"` // from showing the question to the rating
+}
+
+// NewSessionReview records a rating that took the given time
+func NewSessionReview(record ReviewRecord, took time.Duration) SessionReview {
+	return SessionReview{ReviewRecord: record, Seconds: took.Seconds()}
+}
+
+// Duration returns how long the rating took
+func (r SessionReview) Duration() time.Duration {
+	return time.Duration(r.Seconds * float64(time.Second))
+}
+
+// Counts returns the number of ratings of each kind
+func (s SessionRecord) Counts() map[fsrs.Rating]int {
+	counts := make(map[fsrs.Rating]int)
+	for _, review := range s.Reviews {
+		counts[fsrs.Rating(review.Rating)]++
+	}
+	return counts
+}
+
+// Retention returns the share of cards in review that were recalled, and how
+// many reviews that is out of. New and learning cards aren't counted.
+func (s SessionRecord) Retention() (float64, int) {
+	passed, total := 0, 0
+	for _, review := range s.Reviews {
+		if StringToState(review.State) != fsrs.Review {
+			continue
+		}
+		total++
+		if fsrs.Rating(review.Rating) != fsrs.Again {
+			passed++
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(passed) / float64(total), total
+}
+
+// Lapses returns the IDs of cards in review that were forgotten
+func (s SessionRecord) Lapses() []string {
+	var ids []string
+	for _, review := range s.Reviews {
+		if StringToState(review.State) == fsrs.Review && fsrs.Rating(review.Rating) == fsrs.Again {
+			ids = append(ids, review.CardID)
+		}
+	}
+	return ids
+}
+
+// Timing returns the average time per rating and the slowest rating
+func (s SessionRecord) Timing() (average time.Duration, slowest SessionReview) {
+	if len(s.Reviews) == 0 {
+		return 0, slowest
+	}
+
+	var total time.Duration
+	for _, review := range s.Reviews {
+		total += review.Duration()
+		if review.Seconds > slowest.Seconds {
+			slowest = review
+		}
+	}
+	return total / time.Duration(len(s.Reviews)), slowest
+}
+
+// SessionsPath returns the location of the session log for a base deck
+func SessionsPath(root string) string {
+	return filepath.Join(root, DataDirName, SessionsFileName)
+}
+
+// AppendSession appends a finished session to the session log of a base deck
+func AppendSession(root string, session SessionRecord) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(SessionsPath(root), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// LoadSessions reads the session log of a base deck, oldest first
+func LoadSessions(root string) ([]SessionRecord, error) {
+	var sessions []SessionRecord
+
+	file, err := os.Open(SessionsPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sessions, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var session SessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", SessionsPath(root), lineNum, err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, scanner.Err()
+}
+
+// DueOn counts the cards that will be due by the end of the day containing
+// day, including any overdue ones but not suspended ones
+func DueOn(cards []*Card, day time.Time) int {
+	end := startOfDay(day).AddDate(0, 0, 1)
+	count := 0
+	for _, card := range cards {
+		if !card.Suspended() && card.FSRSCard.Due.Before(end) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestSessionRecord(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	session := SessionRecord{
+		Start: start,
+		End:   start.Add(5 * time.Minute),
+		Deck:  "spanish",
+		Reviews: []SessionReview{
+			NewSessionReview(ReviewRecord{CardID: "a", Rating: 3, State: "Review"}, 4*time.Second),
+			NewSessionReview(ReviewRecord{CardID: "b", Rating: 1, State: "Review"}, 20*time.Second),
+			NewSessionReview(ReviewRecord{CardID: "c", Rating: 1, State: "New"}, 6*time.Second),
+			NewSessionReview(ReviewRecord{CardID: "c", Rating: 4, State: "Learning"}, 2*time.Second),
+		},
+	}
+
+	if err := AppendSession(root, session); err != nil {
+		t.Fatalf("AppendSession failed: %v", err)
+	}
+	sessions, err := LoadSessions(root)
+	if err != nil {
+		t.Fatalf("LoadSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || len(sessions[0].Reviews) != 4 || sessions[0].Deck != "spanish" {
+		t.Fatalf("Unexpected sessions after reload: %+v", sessions)
+	}
+	loaded := sessions[0]
+
+	counts := loaded.Counts()
+	if counts[fsrs.Again] != 2 || counts[fsrs.Good] != 1 || counts[fsrs.Easy] != 1 || counts[fsrs.Hard] != 0 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+
+	// Only cards in review count toward retention and lapses
+	if retention, total := loaded.Retention(); retention != 0.5 || total != 2 {
+		t.Errorf("Expected 50%% retention of 2 reviews, got %v of %d", retention, total)
+	}
+	if lapses := loaded.Lapses(); len(lapses) != 1 || lapses[0] != "b" {
+		t.Errorf("Expected b to have lapsed, got %v", lapses)
+	}
+
+	average, slowest := loaded.Timing()
+	if average != 8*time.Second {
+		t.Errorf("Expected 8s average, got %v", average)
+	}
+	if slowest.CardID != "b" || slowest.Duration() != 20*time.Second {
+		t.Errorf("Expected b to be slowest at 20s, got %s at %v", slowest.CardID, slowest.Duration())
+	}
+}
+
+func TestDueOn(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	var cards []*Card
+	for _, due := range []time.Time{now.AddDate(0, 0, -3), now.Add(20 * time.Hour), now.AddDate(0, 0, 2)} {
+		cards = append(cards, &Card{FSRSCard: fsrs.Card{Due: due}})
+	}
+
+	if got := DueOn(cards, now.AddDate(0, 0, 1)); got != 2 {
+		t.Errorf("Expected 2 cards due tomorrow, got %d", got)
+	}
+
+	// Suspended cards aren't due
+	cards = append(cards, &Card{FSRSCard: fsrs.Card{Due: now}, Tags: []string{SuspendedTag}})
+	if got := DueOn(cards, now.AddDate(0, 0, 1)); got != 2 {
+		t.Errorf("Expected a suspended card not to be due, got %d cards", got)
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// SettingsFileName is the per-deck settings file, inherited by subdirectories
+const SettingsFileName = "deck.json"
+
+// DeckSettings holds per-deck scheduling options. Unset fields inherit from
+// the parent deck, falling back to the FSRS defaults. The daily limits are
+// not inherited: each caps the whole subtree of the deck that sets it.
+type DeckSettings struct {
+	DesiredRetention *float64 `json:"desired_retention,omitempty"`
+	MaximumInterval  *float64 `json:"maximum_interval,omitempty"`
+	EnableFuzz       *bool    `json:"enable_fuzz,omitempty"`
+	ShortTerm        *bool    `json:"short_term,omitempty"`
+	NewPerDay        *int     `json:"new_per_day,omitempty"`
+	ReviewsPerDay    *int     `json:"reviews_per_day,omitempty"`
+	Order            *string  `json:"order,omitempty"`
+	NewCards         *string  `json:"new_cards,omitempty"`
+}
+
+// LoadDeckSettings reads the settings file in dir, returning empty settings if there is none
+func LoadDeckSettings(dir string) (DeckSettings, error) {
+	var settings DeckSettings
+
+	data, err := os.ReadFile(filepath.Join(dir, SettingsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, err
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("%s: %v", filepath.Join(dir, SettingsFileName), err)
+	}
+
+	if err := settings.validate(); err != nil {
+		return settings, fmt.Errorf("%s: %v", filepath.Join(dir, SettingsFileName), err)
+	}
+
+	return settings, nil
+}
+
+// EffectiveSettings resolves the settings for dir by layering every settings
+// file from root (or dir itself if root is empty) down to dir
+func EffectiveSettings(root, dir string) (DeckSettings, error) {
+	dirs := []string{dir}
+	if root != "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return DeckSettings{}, err
+		}
+
+		// Collect ancestors up to the deck root, nearest first
+		dirs = nil
+		for current := absDir; ; current = filepath.Dir(current) {
+			dirs = append(dirs, current)
+			if current == root || filepath.Dir(current) == current {
+				break
+			}
+		}
+	}
+
+	var settings DeckSettings
+	for i := len(dirs) - 1; i >= 0; i-- {
+		own, err := LoadDeckSettings(dirs[i])
+		if err != nil {
+			return settings, err
+		}
+		settings = settings.Merge(own)
+	}
+
+	return settings, nil
+}
+
+// Merge returns the settings with any fields set in child taking precedence
+func (s DeckSettings) Merge(child DeckSettings) DeckSettings {
+	if child.DesiredRetention != nil {
+		s.DesiredRetention = child.DesiredRetention
+	}
+	if child.MaximumInterval != nil {
+		s.MaximumInterval = child.MaximumInterval
+	}
+	if child.EnableFuzz != nil {
+		s.EnableFuzz = child.EnableFuzz
+	}
+	if child.ShortTerm != nil {
+		s.ShortTerm = child.ShortTerm
+	}
+	if child.Order != nil {
+		s.Order = child.Order
+	}
+	if child.NewCards != nil {
+		s.NewCards = child.NewCards
+	}
+	return s
+}
+
+// QueueOrder returns the review order these settings ask for
+func (s DeckSettings) QueueOrder() QueueOrder {
+	var order QueueOrder
+	if s.Order != nil {
+		order.Sort = *s.Order
+	}
+	if s.NewCards != nil {
+		order.NewCards = *s.NewCards
+	}
+	return order
+}
+
+// Parameters returns the FSRS parameters for cards using these settings
+func (s DeckSettings) Parameters() fsrs.Parameters {
+	params := DefaultParameters()
+	if s.DesiredRetention != nil {
+		params.RequestRetention = *s.DesiredRetention
+	}
+	if s.MaximumInterval != nil {
+		params.MaximumInterval = *s.MaximumInterval
+	}
+	if s.EnableFuzz != nil {
+		params.EnableFuzz = *s.EnableFuzz
+	}
+	if s.ShortTerm != nil {
+		params.EnableShortTerm = *s.ShortTerm
+	}
+	return params
+}
+
+func (s DeckSettings) validate() error {
+	if s.DesiredRetention != nil && (*s.DesiredRetention <= 0 || *s.DesiredRetention >= 1) {
+		return fmt.Errorf("desired_retention must be between 0 and 1, got %v", *s.DesiredRetention)
+	}
+	if s.MaximumInterval != nil && *s.MaximumInterval < 1 {
+		return fmt.Errorf("maximum_interval must be at least 1 day, got %v", *s.MaximumInterval)
+	}
+	if s.NewPerDay != nil && *s.NewPerDay < 0 {
+		return fmt.Errorf("new_per_day can't be negative, got %d", *s.NewPerDay)
+	}
+	if s.ReviewsPerDay != nil && *s.ReviewsPerDay < 0 {
+		return fmt.Errorf("reviews_per_day can't be negative, got %d", *s.ReviewsPerDay)
+	}
+	return s.QueueOrder().Validate()
+}
+
+// Scheduler returns an FSRS scheduler configured with the card's deck settings
+func (c *Card) Scheduler() *fsrs.FSRS {
+	return fsrs.NewFSRS(c.Settings.Parameters())
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestLoadDeckSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	settings, err := LoadDeckSettings(dir)
+	if err != nil {
+		t.Fatalf("LoadDeckSettings failed: %v", err)
+	}
+	if settings.DesiredRetention != nil {
+		t.Error("Expected no desired retention without a settings file")
+	}
+
+	writeFile(filepath.Join(dir, SettingsFileName), `{"desired_retention": 0.85, "enable_fuzz": true}`)
+	settings, err = LoadDeckSettings(dir)
+	if err != nil {
+		t.Fatalf("LoadDeckSettings failed: %v", err)
+	}
+	if settings.DesiredRetention == nil || *settings.DesiredRetention != 0.85 {
+		t.Errorf("Expected desired retention 0.85, got %v", settings.DesiredRetention)
+	}
+	if settings.EnableFuzz == nil || !*settings.EnableFuzz {
+		t.Error("Expected fuzz to be enabled")
+	}
+
+	writeFile(filepath.Join(dir, SettingsFileName), `{"desired_retention": 1.5}`)
+	if _, err := LoadDeckSettings(dir); err == nil {
+		t.Error("Expected error for out-of-range desired retention")
+	}
+}
+
+func TestFindCardsInheritsSettings(t *testing.T) {
+	root := t.TempDir()
+	InitDeckRoot(root)
+
+	os.MkdirAll(filepath.Join(root, "spanish", "verbs"), 0755)
+	writeFile(filepath.Join(root, SettingsFileName), `{"desired_retention": 0.8, "maximum_interval": 365}`)
+	writeFile(filepath.Join(root, "spanish", SettingsFileName), `{"desired_retention": 0.95}`)
+	writeFile(filepath.Join(root, "top.md"), "Q\n---\nA")
+	writeFile(filepath.Join(root, "spanish", "verbs", "ser.md"), "Q\n---\nA")
+
+	cards, err := FindCards(root)
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+
+	byName := make(map[string]*Card)
+	for _, card := range cards {
+		byName[filepath.Base(card.FilePath)] = card
+	}
+
+	top := byName["top.md"].Settings.Parameters()
+	if top.RequestRetention != 0.8 || top.MaximumInterval != 365 {
+		t.Errorf("Expected top-level retention 0.8 and max interval 365, got %v and %v", top.RequestRetention, top.MaximumInterval)
+	}
+
+	ser := byName["ser.md"].Settings.Parameters()
+	if ser.RequestRetention != 0.95 || ser.MaximumInterval != 365 {
+		t.Errorf("Expected nested retention 0.95 and inherited max interval 365, got %v and %v", ser.RequestRetention, ser.MaximumInterval)
+	}
+
+	// Scanning only a subdeck still inherits from its ancestors
+	subCards, err := FindCards(filepath.Join(root, "spanish", "verbs"))
+	if err != nil {
+		t.Fatalf("FindCards failed: %v", err)
+	}
+	if len(subCards) != 1 || subCards[0].Settings.Parameters().RequestRetention != 0.95 {
+		t.Errorf("Expected subdeck scan to inherit retention 0.95")
+	}
+
+	parsed, err := ParseCard(filepath.Join(root, "spanish", "verbs", "ser.md"))
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	if parsed.Settings.Parameters().MaximumInterval != 365 {
+		t.Errorf("Expected ParseCard to inherit max interval 365")
+	}
+}
+
+func TestRateCardUsesDeckRetention(t *testing.T) {
+	intervalFor := func(retention float64) uint64 {
+		dir := t.TempDir()
+		writeFile(filepath.Join(dir, SettingsFileName), fmt.Sprintf(`{"desired_retention": %g}`, retention))
+		cardPath := filepath.Join(dir, "card.md")
+		writeFile(cardPath, "Q\n---\nA")
+
+		card, err := ParseCard(cardPath)
+		if err != nil {
+			t.Fatalf("ParseCard failed: %v", err)
+		}
+		card.FSRSCard = fsrs.Card{
+			Due:        time.Now(),
+			Stability:  10,
+			Difficulty: 5,
+			State:      fsrs.Review,
+			LastReview: time.Now().Add(-10 * 24 * time.Hour),
+		}
+
+		session := NewReviewSession([]*Card{card})
+		if err := session.RateCard(fsrs.Good); err != nil {
+			t.Fatalf("RateCard failed: %v", err)
+		}
+		return card.FSRSCard.ScheduledDays
+	}
+
+	low, high := intervalFor(0.7), intervalFor(0.97)
+	if low <= high {
+		t.Errorf("Expected lower retention to schedule a longer interval, got %d (0.7) vs %d (0.97)", low, high)
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Default time taken per rating when the deck has no logged sessions
+const (
+	DefaultReviewSeconds = 8.0
+	DefaultNewSeconds    = 20.0
+)
+
+// Ratings given in simulations: the share of first reviews of new cards that
+// are forgotten, and how recalled cards split between Hard, Good and Easy
+const (
+	simulatedNewAgain = 0.2
+	simulatedHard     = 0.1
+	simulatedEasy     = 0.1
+)
+
+// SimulationConfig describes a what-if scenario for Simulate
+type SimulationConfig struct {
+	Days          int
+	Retention     float64 // desired retention; 0 keeps each card's own setting
+	NewPerDay     int     // new cards introduced per day; negative for no limit
+	ReviewsPerDay int     // reviews of studied cards per day; negative for no limit
+	ReviewSeconds float64 // time per review of a card already studied
+	NewSeconds    float64 // time per first review of a new card
+	Seed          int64   // seeds the simulated recall, so runs are repeatable
+}
+
+// SimulationResult is the projected workload of a scenario, day by day
+type SimulationResult struct {
+	Reviews   []int     // ratings each day, first reviews of new cards included
+	NewCards  []int     // new cards introduced each day
+	Seconds   []float64 // study time each day
+	Memorized float64   // expected number of cards recalled after the last day
+	NewLeft   int       // new cards never introduced
+}
+
+// TotalReviews returns the number of ratings over the whole simulation
+func (r SimulationResult) TotalReviews() int {
+	total := 0
+	for _, n := range r.Reviews {
+		total += n
+	}
+	return total
+}
+
+// TotalSeconds returns the study time over the whole simulation
+func (r SimulationResult) TotalSeconds() float64 {
+	total := 0.0
+	for _, s := range r.Seconds {
+		total += s
+	}
+	return total
+}
+
+// PeakReviews returns the busiest day's number of ratings
+func (r SimulationResult) PeakReviews() int {
+	peak := 0
+	for _, n := range r.Reviews {
+		peak = max(peak, n)
+	}
+	return peak
+}
+
+// simulatedCard is a card's state during a simulation
+type simulatedCard struct {
+	fsrs.Card
+	scheduler *fsrs.FSRS
+}
+
+// Simulate projects the daily workload of reviewing cards for cfg.Days days
+// from now, using each card's FSRS model and current state. Recall is drawn
+// at random with the card's retrievability at review time, and reviews happen
+// once a day, so learning steps within a day aren't modelled. Cards over the
+// day's review limit wait for the next day, most overdue first. Suspended
+// cards are left out.
+func Simulate(cards []*Card, cfg SimulationConfig, now time.Time) SimulationResult {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	schedulers := make(map[fsrs.Parameters]*fsrs.FSRS)
+
+	var studied, unseen []*simulatedCard
+	for _, card := range cards {
+		if card.Suspended() {
+			continue
+		}
+		params := card.Settings.Parameters()
+		if cfg.Retention > 0 {
+			params.RequestRetention = cfg.Retention
+		}
+		params.EnableShortTerm = false
+		params.EnableFuzz = false
+		if schedulers[params] == nil {
+			schedulers[params] = fsrs.NewFSRS(params)
+		}
+
+		sim := &simulatedCard{Card: card.FSRSCard, scheduler: schedulers[params]}
+		if sim.State == fsrs.New {
+			unseen = append(unseen, sim)
+			continue
+		}
+		if sim.LastReview.IsZero() {
+			// Reviews journaled before last_review was stored
+			sim.LastReview = sim.Due.AddDate(0, 0, -int(sim.ScheduledDays))
+		}
+		studied = append(studied, sim)
+	}
+
+	result := SimulationResult{
+		Reviews:  make([]int, cfg.Days),
+		NewCards: make([]int, cfg.Days),
+		Seconds:  make([]float64, cfg.Days),
+	}
+	today := startOfDay(now)
+	for day := 0; day < cfg.Days; day++ {
+		reviewAt := today.AddDate(0, 0, day).Add(12 * time.Hour)
+		if day == 0 && now.After(reviewAt) {
+			reviewAt = now
+		}
+		end := today.AddDate(0, 0, day+1)
+
+		var due []*simulatedCard
+		for _, sim := range studied {
+			if sim.Due.Before(end) {
+				due = append(due, sim)
+			}
+		}
+		if cfg.ReviewsPerDay >= 0 && len(due) > cfg.ReviewsPerDay {
+			sort.SliceStable(due, func(i, j int) bool { return due[i].Due.Before(due[j].Due) })
+			due = due[:cfg.ReviewsPerDay]
+		}
+
+		for _, sim := range due {
+			rating := fsrs.Again
+			if rng.Float64() < sim.scheduler.GetRetrievability(sim.Card, reviewAt) {
+				rating = recalledRating(rng)
+			}
+			sim.Card = sim.scheduler.Next(sim.Card, reviewAt, rating).Card
+			result.Reviews[day]++
+			result.Seconds[day] += cfg.ReviewSeconds
+		}
+
+		introduce := len(unseen)
+		if cfg.NewPerDay >= 0 {
+			introduce = min(introduce, cfg.NewPerDay)
+		}
+		for _, sim := range unseen[:introduce] {
+			rating := fsrs.Again
+			if rng.Float64() >= simulatedNewAgain {
+				rating = recalledRating(rng)
+			}
+			sim.Card = sim.scheduler.Next(sim.Card, reviewAt, rating).Card
+			studied = append(studied, sim)
+		}
+		unseen = unseen[introduce:]
+		result.NewCards[day] = introduce
+		result.Reviews[day] += introduce
+		result.Seconds[day] += float64(introduce) * cfg.NewSeconds
+	}
+
+	end := today.AddDate(0, 0, cfg.Days)
+	for _, sim := range studied {
+		result.Memorized += sim.scheduler.GetRetrievability(sim.Card, end)
+	}
+	result.NewLeft = len(unseen)
+	return result
+}
+
+// recalledRating draws the rating of a card that was recalled
+func recalledRating(rng *rand.Rand) fsrs.Rating {
+	switch p := rng.Float64(); {
+	case p < simulatedHard:
+		return fsrs.Hard
+	case p < simulatedHard+simulatedEasy:
+		return fsrs.Easy
+	}
+	return fsrs.Good
+}
+
+// StudyTimes returns the average seconds per review of studied cards and per
+// first review of new cards in logged sessions, falling back to the defaults
+func StudyTimes(sessions []SessionRecord) (review, newCard float64) {
+	var reviewTotal, newTotal float64
+	var reviews, news int
+	for _, session := range sessions {
+		for _, r := range session.Reviews {
+			if StringToState(r.State) == fsrs.New {
+				newTotal += r.Seconds
+				news++
+			} else {
+				reviewTotal += r.Seconds
+				reviews++
+			}
+		}
+	}
+
+	review, newCard = DefaultReviewSeconds, DefaultNewSeconds
+	if reviews > 0 {
+		review = reviewTotal / float64(reviews)
+	}
+	if news > 0 {
+		newCard = newTotal / float64(news)
+	}
+	return review, newCard
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestSimulate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, time.Local)
+	var cards []*Card
+	for i := 0; i < 100; i++ {
+		cards = append(cards, &Card{FSRSCard: fsrs.NewCard()})
+	}
+	for i := 0; i < 50; i++ {
+		cards = append(cards, &Card{FSRSCard: fsrs.Card{
+			Due:           now.AddDate(0, 0, i%10),
+			LastReview:    now.AddDate(0, 0, i%10-10),
+			Stability:     10,
+			Difficulty:    5,
+			ScheduledDays: 10,
+			Reps:          3,
+			State:         fsrs.Review,
+		}})
+	}
+
+	cfg := SimulationConfig{Days: 90, NewPerDay: 10, ReviewsPerDay: -1, ReviewSeconds: 10, NewSeconds: 30, Seed: 1}
+	result := Simulate(cards, cfg, now)
+
+	if len(result.Reviews) != 90 {
+		t.Fatalf("Expected 90 days of results, got %d", len(result.Reviews))
+	}
+	for day := 0; day < 10; day++ {
+		if result.NewCards[day] != 10 {
+			t.Errorf("Expected 10 new cards on day %d, got %d", day, result.NewCards[day])
+		}
+	}
+	if result.NewCards[10] != 0 || result.NewLeft != 0 {
+		t.Errorf("Expected new cards to run out after 10 days, got %d on day 10 and %d left", result.NewCards[10], result.NewLeft)
+	}
+	if result.Memorized <= 0 || result.Memorized > 150 {
+		t.Errorf("Expected between 0 and 150 cards recalled, got %v", result.Memorized)
+	}
+	if want := float64(result.TotalReviews()-100)*10 + 100*30; result.TotalSeconds() != want {
+		t.Errorf("Expected %v seconds of study, got %v", want, result.TotalSeconds())
+	}
+
+	// The simulation is repeatable, and the cards themselves are untouched
+	if again := Simulate(cards, cfg, now); again.TotalReviews() != result.TotalReviews() {
+		t.Errorf("Expected the same seed to give %d reviews, got %d", result.TotalReviews(), again.TotalReviews())
+	}
+	if cards[0].FSRSCard.State != fsrs.New {
+		t.Error("Simulate must not change the cards")
+	}
+
+	// Asking for lower retention means fewer reviews
+	cfg.Retention = 0.7
+	if lower := Simulate(cards, cfg, now); lower.TotalReviews() >= result.TotalReviews() {
+		t.Errorf("Expected fewer reviews at 0.7 retention than at 0.9, got %d and %d", lower.TotalReviews(), result.TotalReviews())
+	}
+
+	// A review limit caps every day's reviews, new cards aside
+	cfg.Retention = 0
+	cfg.ReviewsPerDay = 5
+	capped := Simulate(cards, cfg, now)
+	for day, reviews := range capped.Reviews {
+		if reviews-capped.NewCards[day] > 5 {
+			t.Errorf("Expected at most 5 reviews on day %d, got %d", day, reviews-capped.NewCards[day])
+		}
+	}
+	if capped.TotalReviews() >= result.TotalReviews() {
+		t.Errorf("Expected fewer reviews under a limit, got %d and %d", capped.TotalReviews(), result.TotalReviews())
+	}
+}
+
+func TestSimulateSkipsSuspended(t *testing.T) {
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, time.Local)
+	suspended := []string{SuspendedTag}
+	cards := []*Card{
+		{FSRSCard: fsrs.NewCard(), Tags: suspended},
+		{FSRSCard: fsrs.Card{Due: now, LastReview: now.AddDate(0, 0, -10), Stability: 10, Difficulty: 5, ScheduledDays: 10, Reps: 3, State: fsrs.Review}, Tags: suspended},
+	}
+
+	cfg := SimulationConfig{Days: 30, NewPerDay: 10, ReviewsPerDay: -1, Seed: 1}
+	result := Simulate(cards, cfg, now)
+
+	if result.TotalReviews() != 0 || result.NewLeft != 0 || result.Memorized != 0 {
+		t.Errorf("Expected suspended cards to be left out, got %d reviews, %d new left and %v recalled", result.TotalReviews(), result.NewLeft, result.Memorized)
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// retentionWindows are the days looked back over for true retention
+var retentionWindows = []int{7, 30, 365}
+
+const (
+	forecastDays    = 30  // days of due forecast, today first
+	heatmapDays     = 365 // days of review history, ending today
+	mostLapsedLimit = 10  // cards listed as most lapsed
+)
+
+// Stats holds analytics for a set of cards
+type Stats struct {
+	Cards      int               `json:"cards"`
+	States     map[string]int    `json:"states"`
+	Retention  []RetentionWindow `json:"retention"`
+	Forecast   []int             `json:"forecast"` // cards due each day, today (with overdue cards) first
+	Heatmap    []DayCount        `json:"heatmap"`  // reviews each day, oldest first
+	Stability  []Bucket          `json:"stability"`
+	Difficulty []Bucket          `json:"difficulty"`
+	MostLapsed []LapsedCard      `json:"most_lapsed"`
+}
+
+// RetentionWindow is the true retention over the last Days days: the share
+// of reviews of cards in review that weren't rated Again
+type RetentionWindow struct {
+	Days         int     `json:"days"`
+	Reviews      int     `json:"reviews"`
+	Passed       int     `json:"passed"`
+	Retention    float64 `json:"retention"`
+	StudySeconds float64 `json:"study_seconds"` // time spent in logged TUI sessions
+}
+
+// DayCount is the number of reviews on a day
+type DayCount struct {
+	Date    string `json:"date"` // YYYY-MM-DD, local time
+	Reviews int    `json:"reviews"`
+}
+
+// Bucket is one bar of a distribution
+type Bucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// LapsedCard is a card that has often been forgotten
+type LapsedCard struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"`
+	Lapses   uint64 `json:"lapses"`
+}
+
+// stabilityBuckets are the upper bounds, in days, of the stability distribution
+var stabilityBuckets = []struct {
+	label string
+	max   float64
+}{
+	{"< 1d", 1}, {"1-7d", 7}, {"1-4w", 30}, {"1-3mo", 90}, {"3-12mo", 365}, {"> 1y", 0},
+}
+
+// ComputeStats gathers analytics for cards from their review history and the
+// deck's logged sessions
+func ComputeStats(cards []*Card, sessions []SessionRecord, now time.Time) Stats {
+	today := startOfDay(now)
+	stats := Stats{
+		Cards:    len(cards),
+		States:   make(map[string]int),
+		Forecast: make([]int, forecastDays),
+	}
+
+	// Daily review counts and retention come from each card's review log
+	heatmap := make(map[string]int)
+	stats.Retention = make([]RetentionWindow, len(retentionWindows))
+	for i, days := range retentionWindows {
+		stats.Retention[i].Days = days
+	}
+
+	keys := make(map[string]bool)
+	for _, card := range cards {
+		keys[card.Key()] = true
+		stats.States[StateToString(card.FSRSCard.State)]++
+
+		// Overdue cards count toward today, and suspended ones aren't due
+		if day := daysBetween(today, card.FSRSCard.Due); day < forecastDays && !card.Suspended() {
+			stats.Forecast[max(day, 0)]++
+		}
+
+		for _, log := range card.ReviewLog {
+			age := daysBetween(log.Review, today)
+			if age < heatmapDays {
+				heatmap[log.Review.Local().Format("2006-01-02")]++
+			}
+			if log.State != fsrs.Review {
+				continue
+			}
+			for i, days := range retentionWindows {
+				if age < days {
+					stats.Retention[i].Reviews++
+					if log.Rating != fsrs.Again {
+						stats.Retention[i].Passed++
+					}
+				}
+			}
+		}
+	}
+
+	for i := range stats.Retention {
+		if window := &stats.Retention[i]; window.Reviews > 0 {
+			window.Retention = float64(window.Passed) / float64(window.Reviews)
+		}
+	}
+
+	// Study time only covers ratings of these cards made in logged sessions
+	for _, session := range sessions {
+		for _, review := range session.Reviews {
+			if !keys[review.CardID] {
+				continue
+			}
+			age := daysBetween(review.Time, today)
+			for i, days := range retentionWindows {
+				if age < days {
+					stats.Retention[i].StudySeconds += review.Seconds
+				}
+			}
+		}
+	}
+
+	for i := heatmapDays - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format("2006-01-02")
+		stats.Heatmap = append(stats.Heatmap, DayCount{Date: date, Reviews: heatmap[date]})
+	}
+
+	stats.Stability, stats.Difficulty = distributions(cards)
+	stats.MostLapsed = mostLapsed(cards)
+	return stats
+}
+
+// distributions buckets the stability and difficulty of cards that have been
+// reviewed
+func distributions(cards []*Card) (stability, difficulty []Bucket) {
+	for _, bucket := range stabilityBuckets {
+		stability = append(stability, Bucket{Label: bucket.label})
+	}
+	for d := 1; d < 10; d++ {
+		difficulty = append(difficulty, Bucket{Label: fmt.Sprintf("%d-%d", d, d+1)})
+	}
+
+	for _, card := range cards {
+		if card.FSRSCard.State == fsrs.New {
+			continue
+		}
+
+		i := 0
+		for i < len(stabilityBuckets)-1 && card.FSRSCard.Stability >= stabilityBuckets[i].max {
+			i++
+		}
+		stability[i].Count++
+
+		d := int(card.FSRSCard.Difficulty) - 1
+		difficulty[min(max(d, 0), len(difficulty)-1)].Count++
+	}
+	return stability, difficulty
+}
+
+// mostLapsed returns the cards forgotten most often, most lapses first
+func mostLapsed(cards []*Card) []LapsedCard {
+	var lapsed []*Card
+	for _, card := range cards {
+		if card.FSRSCard.Lapses > 0 {
+			lapsed = append(lapsed, card)
+		}
+	}
+
+	sort.SliceStable(lapsed, func(i, j int) bool {
+		return lapsed[i].FSRSCard.Lapses > lapsed[j].FSRSCard.Lapses
+	})
+	if len(lapsed) > mostLapsedLimit {
+		lapsed = lapsed[:mostLapsedLimit]
+	}
+
+	result := []LapsedCard{}
+	for _, card := range lapsed {
+		result = append(result, LapsedCard{
+			ID:       card.Key(),
+			Name:     card.Name(),
+			FilePath: card.FilePath,
+			Lapses:   card.FSRSCard.Lapses,
+		})
+	}
+	return result
+}
+
+// daysBetween counts the calendar days from the day of a to the day of b
+func daysBetween(a, b time.Time) int {
+	from, to := startOfDay(a), startOfDay(b)
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.Local)
+	review := func(daysAgo int, rating fsrs.Rating, state fsrs.State) fsrs.ReviewLog {
+		return fsrs.ReviewLog{Review: now.AddDate(0, 0, -daysAgo), Rating: rating, State: state}
+	}
+
+	cards := []*Card{
+		{ID: "NEW", FSRSCard: fsrs.Card{Due: now.AddDate(0, 0, -1), State: fsrs.New}},
+		{
+			ID:       "EASY",
+			FSRSCard: fsrs.Card{Due: now.AddDate(0, 0, 3), State: fsrs.Review, Stability: 40, Difficulty: 2.5},
+			ReviewLog: []fsrs.ReviewLog{
+				review(100, fsrs.Good, fsrs.New),
+				review(20, fsrs.Good, fsrs.Review),
+				review(2, fsrs.Good, fsrs.Review),
+			},
+		},
+		{
+			ID:       "HARD",
+			FSRSCard: fsrs.Card{Due: now.AddDate(0, 0, 60), State: fsrs.Relearning, Stability: 0.5, Difficulty: 9.7, Lapses: 2},
+			ReviewLog: []fsrs.ReviewLog{
+				review(40, fsrs.Again, fsrs.Review),
+				review(2, fsrs.Again, fsrs.Review),
+			},
+		},
+	}
+	sessions := []SessionRecord{{Reviews: []SessionReview{
+		NewSessionReview(ReviewRecord{CardID: "EASY", Time: now.AddDate(0, 0, -2)}, 10*time.Second),
+		NewSessionReview(ReviewRecord{CardID: "OTHER", Time: now.AddDate(0, 0, -2)}, time.Minute),
+	}}}
+
+	stats := ComputeStats(cards, sessions, now)
+
+	if stats.Cards != 3 || stats.States["New"] != 1 || stats.States["Relearning"] != 1 {
+		t.Errorf("Unexpected card counts %d %v", stats.Cards, stats.States)
+	}
+
+	// Only reviews of cards in review count, within each window
+	want := []struct{ reviews, passed int }{{2, 1}, {3, 2}, {4, 2}}
+	for i, w := range want {
+		got := stats.Retention[i]
+		if got.Reviews != w.reviews || got.Passed != w.passed {
+			t.Errorf("%d days: expected %d of %d passed, got %d of %d", got.Days, w.passed, w.reviews, got.Passed, got.Reviews)
+		}
+	}
+	if stats.Retention[0].StudySeconds != 10 {
+		t.Errorf("Expected 10s studied this week, got %v", stats.Retention[0].StudySeconds)
+	}
+
+	// The overdue card counts toward today and the far-off one isn't forecast
+	if len(stats.Forecast) != forecastDays || stats.Forecast[0] != 1 || stats.Forecast[3] != 1 {
+		t.Errorf("Unexpected forecast %v", stats.Forecast)
+	}
+
+	if len(stats.Heatmap) != heatmapDays || stats.Heatmap[heatmapDays-1].Date != "2025-06-15" {
+		t.Fatalf("Unexpected heatmap range ending %+v", stats.Heatmap[len(stats.Heatmap)-1])
+	}
+	if got := stats.Heatmap[heatmapDays-3]; got.Reviews != 2 {
+		t.Errorf("Expected 2 reviews on %s, got %d", got.Date, got.Reviews)
+	}
+
+	if stats.Stability[0].Count != 1 || stats.Stability[3].Count != 1 {
+		t.Errorf("Unexpected stability distribution %v", stats.Stability)
+	}
+	if stats.Difficulty[1].Count != 1 || stats.Difficulty[8].Count != 1 {
+		t.Errorf("Unexpected difficulty distribution %v", stats.Difficulty)
+	}
+
+	if len(stats.MostLapsed) != 1 || stats.MostLapsed[0].ID != "HARD" {
+		t.Errorf("Expected HARD to be the most lapsed card, got %v", stats.MostLapsed)
+	}
+}
+
+func TestForecastSkipsSuspended(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.Local)
+	cards := []*Card{
+		{ID: "DUE", FSRSCard: fsrs.Card{Due: now, State: fsrs.Review}},
+		{ID: "SUSPENDED", FSRSCard: fsrs.Card{Due: now, State: fsrs.Review}, Tags: []string{SuspendedTag}},
+	}
+
+	stats := ComputeStats(cards, nil, now)
+
+	if stats.Cards != 2 || stats.Forecast[0] != 1 {
+		t.Errorf("Expected 2 cards with 1 due today, got %d cards and forecast %v", stats.Cards, stats.Forecast)
+	}
+}
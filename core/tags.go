@@ -0,0 +1,138 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inlineTag matches a single #tag on a tag line
+var inlineTag = regexp.MustCompile(`^#[\p{L}\p{N}_][\p{L}\p{N}_/-]*$`)
+
+// parseTagLine returns the tags on a line made up only of #tags, such as
+// "#go #concurrency". Headings need a space after the #, so they never match.
+func parseTagLine(line string) ([]string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	tags := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !inlineTag.MatchString(field) {
+			return nil, false
+		}
+		tags = append(tags, normalizeTag(field))
+	}
+	return tags, true
+}
+
+// normalizeTag lowercases a tag and drops its leading #
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// mergeTags appends the tags not already present
+func mergeTags(tags []string, more ...string) []string {
+	for _, tag := range more {
+		if tag = normalizeTag(tag); tag != "" && !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns a list field, accepting a single or comma-separated scalar too
+func (f frontMatter) List(key string) []string {
+	var values []string
+	switch value := f[key].(type) {
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// HasTag reports whether the card carries a tag
+func (c *Card) HasTag(tag string) bool {
+	return containsTag(c.Tags, normalizeTag(tag))
+}
+
+// SuspendedTag marks cards that are left out of reviews until it's removed
+const SuspendedTag = "suspended"
+
+// Suspended reports whether the card is tagged to be left out of reviews
+func (c *Card) Suspended() bool {
+	return c.HasTag(SuspendedTag)
+}
+
+// TagFilter selects cards by tag: a card must carry every Include tag and
+// none of the Exclude tags
+type TagFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// IsEmpty reports whether the filter lets every card through
+func (f TagFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether a card passes the filter
+func (f TagFilter) Match(card *Card) bool {
+	for _, tag := range f.Include {
+		if !card.HasTag(tag) {
+			return false
+		}
+	}
+	for _, tag := range f.Exclude {
+		if card.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the cards that pass the filter
+func (f TagFilter) Apply(cards []*Card) []*Card {
+	if f.IsEmpty() {
+		return cards
+	}
+
+	var matched []*Card
+	for _, card := range cards {
+		if f.Match(card) {
+			matched = append(matched, card)
+		}
+	}
+	return matched
+}
+
+// String describes the filter, e.g. "go, concurrency, not draft"
+func (f TagFilter) String() string {
+	parts := make([]string, 0, len(f.Include)+len(f.Exclude))
+	for _, tag := range f.Include {
+		parts = append(parts, normalizeTag(tag))
+	}
+	for _, tag := range f.Exclude {
+		parts = append(parts, "not "+normalizeTag(tag))
+	}
+	return strings.Join(parts, ", ")
+}
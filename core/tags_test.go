@@ -0,0 +1,73 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	content := `---
+tags: [Go, concurrency]
+---
+What does a nil channel do on send?
+#go #channels
+
+` + "```c\n#endif\n```" + `
+---
+Blocks forever.`
+
+	cardPath := filepath.Join(t.TempDir(), "nil-channel.md")
+	writeFile(cardPath, content)
+
+	card, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+
+	want := []string{"go", "concurrency", "channels"}
+	if len(card.Tags) != len(want) {
+		t.Fatalf("Expected tags %v, got %v", want, card.Tags)
+	}
+	for i, tag := range want {
+		if card.Tags[i] != tag {
+			t.Errorf("Expected tag %q at %d, got %q", tag, i, card.Tags[i])
+		}
+	}
+
+	if card.Question != "What does a nil channel do on send?\n\n```c\n#endif\n```" {
+		t.Errorf("Expected tag line removed and code kept, got %q", card.Question)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	cards := []*Card{
+		{Question: "both", Tags: []string{"go", "concurrency"}},
+		{Question: "go only", Tags: []string{"go"}},
+		{Question: "draft", Tags: []string{"go", "concurrency", "draft"}},
+		{Question: "untagged"},
+	}
+
+	tests := []struct {
+		filter TagFilter
+		want   []string
+	}{
+		{TagFilter{}, []string{"both", "go only", "draft", "untagged"}},
+		{TagFilter{Include: []string{"go"}}, []string{"both", "go only", "draft"}},
+		{TagFilter{Include: []string{"Go", "#concurrency"}}, []string{"both", "draft"}},
+		{TagFilter{Include: []string{"go", "concurrency"}, Exclude: []string{"draft"}}, []string{"both"}},
+		{TagFilter{Exclude: []string{"go"}}, []string{"untagged"}},
+	}
+
+	for _, test := range tests {
+		got := test.filter.Apply(cards)
+		if len(got) != len(test.want) {
+			t.Errorf("%+v: expected %d cards, got %d", test.filter, len(test.want), len(got))
+			continue
+		}
+		for i, card := range got {
+			if card.Question != test.want[i] {
+				t.Errorf("%+v: expected %q at %d, got %q", test.filter, test.want[i], i, card.Question)
+			}
+		}
+	}
+}
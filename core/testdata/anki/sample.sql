@@ -0,0 +1,104 @@
+-- A small Anki collection in the legacy (schema 11) format, for the import
+-- tests. Build sample.apkg with:
+--
+--   sqlite3 collection.anki21 < sample.sql
+--   echo '{"0": "heart.png"}' > media && printf 'PNG' > 0
+--   zip sample.apkg collection.anki21 media 0
+
+CREATE TABLE col (
+    id              integer primary key,
+    crt             integer not null,
+    mod             integer not null,
+    scm             integer not null,
+    ver             integer not null,
+    dty             integer not null,
+    usn             integer not null,
+    ls              integer not null,
+    conf            text not null,
+    models          text not null,
+    decks           text not null,
+    dconf           text not null,
+    tags            text not null
+);
+CREATE TABLE notes (
+    id              integer primary key,   /* 0 */
+    guid            text not null,         /* 1 */
+    mid             integer not null,      /* 2 */
+    mod             integer not null,      /* 3 */
+    usn             integer not null,      /* 4 */
+    tags            text not null,         /* 5 */
+    flds            text not null,         /* 6 */
+    sfld            integer not null,      /* 7 */
+    csum            integer not null,      /* 8 */
+    flags           integer not null,      /* 9 */
+    data            text not null          /* 10 */
+);
+CREATE TABLE cards (
+    id              integer primary key,   /* 0 */
+    nid             integer not null,      /* 1 */
+    did             integer not null,      /* 2 */
+    ord             integer not null,      /* 3 */
+    mod             integer not null,      /* 4 */
+    usn             integer not null,      /* 5 */
+    type            integer not null,      /* 6 */
+    queue           integer not null,      /* 7 */
+    due             integer not null,      /* 8 */
+    ivl             integer not null,      /* 9 */
+    factor          integer not null,      /* 10 */
+    reps            integer not null,      /* 11 */
+    lapses          integer not null,      /* 12 */
+    left            integer not null,      /* 13 */
+    odue            integer not null,      /* 14 */
+    odid            integer not null,      /* 15 */
+    flags           integer not null,      /* 16 */
+    data            text not null          /* 17 */
+);
+CREATE TABLE revlog (
+    id              integer primary key,
+    cid             integer not null,
+    usn             integer not null,
+    ease            integer not null,
+    ivl             integer not null,
+    lastIvl         integer not null,
+    factor          integer not null,
+    time            integer not null,
+    type            integer not null
+);
+CREATE TABLE graves (
+    usn             integer not null,
+    oid             integer not null,
+    type            integer not null
+);
+
+-- Created 2024-01-01 00:00 UTC
+INSERT INTO col VALUES (1, 1704067200, 0, 0, 11, 0, 0, 0, '{}',
+'{"100": {"id": 100, "name": "Basic", "type": 0,
+   "flds": [{"name": "Front", "ord": 0}, {"name": "Back", "ord": 1}],
+   "tmpls": [{"name": "Card 1", "ord": 0, "qfmt": "{{Front}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"}]},
+  "200": {"id": 200, "name": "Basic (and reversed card)", "type": 0,
+   "flds": [{"name": "Front", "ord": 0}, {"name": "Back", "ord": 1}],
+   "tmpls": [{"name": "Card 1", "ord": 0, "qfmt": "{{Front}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"},
+             {"name": "Card 2", "ord": 1, "qfmt": "{{Back}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Front}}"}]},
+  "300": {"id": 300, "name": "Cloze", "type": 1,
+   "flds": [{"name": "Text", "ord": 0}, {"name": "Back Extra", "ord": 1}],
+   "tmpls": [{"name": "Cloze", "ord": 0, "qfmt": "{{cloze:Text}}", "afmt": "{{cloze:Text}}<br>\n{{Back Extra}}"}]}}',
+'{"1": {"id": 1, "name": "Default"}, "10": {"id": 10, "name": "Languages::Spanish"}}',
+'{}', '{}');
+
+-- Basic, in Default, reviewed twice, with an image
+INSERT INTO notes VALUES (1000, 'a', 100, 0, 0, ' biology anki::imported ',
+    'What does the <b>heart</b> pump?' || char(31) || 'Blood<br><img src="heart.png">', 'What', 0, 0, '');
+INSERT INTO cards VALUES (1001, 1000, 1, 0, 0, 0, 2, 2, 10, 7, 2500, 2, 0, 0, 0, 0, 0, '');
+INSERT INTO revlog VALUES (1704103200000, 1001, 0, 3, -600, 0, 0, 5000, 0);
+INSERT INTO revlog VALUES (1704189600000, 1001, 0, 3, 7, -600, 2500, 4000, 1);
+
+-- Basic and reversed, in Languages::Spanish, reviewed in Anki without a log
+INSERT INTO notes VALUES (2000, 'b', 200, 0, 0, '', 'perro' || char(31) || 'dog', 'perro', 0, 0, '');
+INSERT INTO cards VALUES (2001, 2000, 10, 0, 0, 0, 2, 2, 20, 10, 2500, 4, 1, 0, 0, 0, 0, '');
+INSERT INTO cards VALUES (2002, 2000, 10, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, '');
+
+-- Cloze with two deletions and an extra, suspended
+INSERT INTO notes VALUES (3000, 'c', 300, 0, 0, '',
+    'The {{c1::mitochondria}} is the {{c2::powerhouse::role}} of the cell.' || char(31) || 'Cells have many.', 'The', 0, 0, '');
+INSERT INTO cards VALUES (3001, 3000, 1, 0, 0, 0, 0, -1, 2, 0, 0, 0, 0, 0, 0, 0, 0, '');
+INSERT INTO cards VALUES (3002, 3000, 1, 1, 0, 0, 0, -1, 3, 0, 0, 0, 0, 0, 0, 0, 0, '');
@@ -8,12 +8,21 @@ import (
 
 // Card represents a flashcard with its content and scheduling information
 type Card struct {
+	ID           string // stable ID stored in the metadata comment
 	Question     string
 	Answer       string
 	FilePath     string
 	FSRSCard     fsrs.Card
 	ReviewLog    []fsrs.ReviewLog
 	LastModified time.Time
+	Root         string       // base deck whose journal holds this card's history
+	Settings     DeckSettings // effective settings of the card's deck
+	Block        int          // index of the card's block within its file
+	MultiCard    bool         // the file holds several cards separated by ===
+	Cloze        int          // cloze index this card tests, 0 for question/answer cards
+	Text         string       // cloze text with its deletions, shared by the block's cloze cards
+	Reverse      bool         // answer→question direction of a reversible card
+	Tags         []string     // lowercase tags from front matter and #tag lines
 }
 
 // DeckStats contains statistics about a deck
@@ -27,9 +36,9 @@ type DeckStats struct {
 
 // ReviewSession manages a review session for multiple cards
 type ReviewSession struct {
-	scheduler *fsrs.FSRS
-	cards     []*Card
-	current   int
+	cards      []*Card
+	current    int
+	learnAhead time.Duration // how early learning cards are shown once the queue runs out
 }
 
 // Config holds application configuration
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// UndoFileName is the stack of recent ratings inside DataDirName
+const UndoFileName = "undo.jsonl"
+
+// maxUndo is how many ratings are kept on the undo stack
+const maxUndo = 100
+
+// UndoRecord holds what a rating changed, so that it can be reverted
+type UndoRecord struct {
+	CardID   string       `json:"card"`
+	FilePath string       `json:"file"`   // relative to the deck root
+	Before   string       `json:"before"` // card file content before the rating
+	After    string       `json:"after"`  // card file content after the rating
+	Previous fsrs.Card    `json:"previous"`
+	Review   ReviewRecord `json:"review"`
+}
+
+// UndoPath returns the location of the undo stack for a base deck
+func UndoPath(root string) string {
+	return filepath.Join(root, DataDirName, UndoFileName)
+}
+
+// pushUndo adds a record to the top of the undo stack, dropping the oldest
+// records beyond maxUndo
+func pushUndo(root string, record UndoRecord) error {
+	records, err := loadUndo(root)
+	if err != nil {
+		return err
+	}
+
+	records = append(records, record)
+	if len(records) > maxUndo {
+		records = records[len(records)-maxUndo:]
+	}
+	return saveUndo(root, records)
+}
+
+// UndoLastReview reverts the most recent rating in a base deck: the card file
+// is restored and the review is removed from the journal. If cardID is set,
+// the most recent rating must be for that card. Returns the restored card.
+func UndoLastReview(root, cardID string) (*Card, error) {
+	records, err := loadUndo(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+
+	last := records[len(records)-1]
+	if cardID != "" && last.CardID != cardID {
+		return nil, fmt.Errorf("the last rating was for another card (%s)", last.CardID)
+	}
+
+	if err := last.restore(root); err != nil {
+		return nil, err
+	}
+
+	if err := removeReview(root, last.Review); err != nil {
+		return nil, fmt.Errorf("failed to remove review from history: %v", err)
+	}
+
+	if err := saveUndo(root, records[:len(records)-1]); err != nil {
+		return nil, err
+	}
+
+	return FindCardByID(root, last.CardID)
+}
+
+// restore puts the card back as it was before the rating. If the file has
+// changed since, e.g. it was edited, only the card's scheduling is restored.
+func (r UndoRecord) restore(root string) error {
+	path := filepath.Join(root, filepath.FromSlash(r.FilePath))
+	if content, err := os.ReadFile(path); err == nil && string(content) == r.After {
+		return os.WriteFile(path, []byte(r.Before), 0644)
+	}
+
+	card, err := FindCardByID(root, r.CardID)
+	if err != nil {
+		return err
+	}
+	card.FSRSCard = r.Previous
+	return card.UpdateFSRSMetadata()
+}
+
+// removeReview drops the most recent journal entry matching record
+func removeReview(root string, record ReviewRecord) error {
+	history, err := loadHistoryRecords(root)
+	if err != nil {
+		return err
+	}
+
+	last := -1
+	for i, r := range history {
+		if r.CardID == record.CardID && r.Time.Equal(record.Time) && r.Rating == record.Rating {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	i := -1
+	return rewriteHistory(root, func(r ReviewRecord) (ReviewRecord, bool) {
+		i++
+		return r, i != last
+	})
+}
+
+func loadUndo(root string) ([]UndoRecord, error) {
+	var records []UndoRecord
+
+	file, err := os.Open(UndoPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record UndoRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s: %v", UndoPath(root), err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func saveUndo(root string, records []UndoRecord) error {
+	var buf []byte
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	tmpPath := UndoPath(root) + ".tmp"
+	if err := os.WriteFile(tmpPath, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, UndoPath(root))
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestUndoLastReview(t *testing.T) {
+	root := t.TempDir()
+	if err := InitDeckRoot(root); err != nil {
+		t.Fatalf("InitDeckRoot failed: %v", err)
+	}
+
+	cardPath := filepath.Join(root, "hola.md")
+	if err := writeFile(cardPath, "Hola?\n---\nHello"); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	card, err := ParseCard(cardPath)
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	before, _ := os.ReadFile(cardPath)
+
+	session := NewReviewSession([]*Card{card})
+	if err := session.RateCard(fsrs.Good); err != nil {
+		t.Fatalf("RateCard failed: %v", err)
+	}
+
+	if _, err := UndoLastReview(root, "OTHER"); err == nil {
+		t.Error("Expected an error undoing another card's rating")
+	}
+
+	restored, err := UndoLastReview(root, card.ID)
+	if err != nil {
+		t.Fatalf("UndoLastReview failed: %v", err)
+	}
+	if restored.FSRSCard.State != fsrs.New || restored.FSRSCard.Reps != 0 {
+		t.Errorf("Expected a new card after undo, got %+v", restored.FSRSCard)
+	}
+	if len(restored.ReviewLog) != 0 {
+		t.Errorf("Expected no review logs after undo, got %d", len(restored.ReviewLog))
+	}
+
+	// The card keeps the ID its first rating gave it
+	after, _ := os.ReadFile(cardPath)
+	if !strings.HasSuffix(string(after), string(before)) || !strings.Contains(string(after), "id:"+card.ID) {
+		t.Errorf("Expected file restored to:\n%s\nwith ID %s, got:\n%s", before, card.ID, after)
+	}
+
+	if _, err := UndoLastReview(root, ""); err == nil {
+		t.Error("Expected an error with nothing left to undo")
+	}
+}
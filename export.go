@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"srs/core"
+)
+
+// exportCommand exports a subdeck, the base deck by default, for another
+// program
+func exportCommand(args []string, config *Config, filter core.TagFilter) error {
+	const usage = "usage: srs export anki [DECK] [-o FILE.apkg] [--scheduling]"
+	if len(args) == 0 || args[0] != "anki" {
+		return fmt.Errorf(usage)
+	}
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	output := flags.String("o", "", "Package to write")
+	flags.StringVar(output, "output", "", "Package to write")
+	scheduling := flags.Bool("scheduling", false, "Include due dates, memory state and review history")
+
+	// Flags may come before or after the deck
+	var decks []string
+	rest := args[1:]
+	for {
+		if err := flags.Parse(rest); err != nil {
+			return fmt.Errorf("%s: %v", usage, err)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		decks = append(decks, flags.Arg(0))
+		rest = flags.Args()[1:]
+	}
+	if len(decks) > 1 {
+		return fmt.Errorf(usage)
+	}
+
+	deck := "."
+	if len(decks) == 1 {
+		deck = decks[0]
+	}
+	deckPath, err := resolveDeckPath(deck, config)
+	if err != nil {
+		return fmt.Errorf("invalid deck %s: %v", deck, err)
+	}
+	if _, err := os.Stat(deckPath); err != nil {
+		return fmt.Errorf("deck %s does not exist", deck)
+	}
+	if *output == "" {
+		*output = filepath.Base(deckPath) + ".apkg"
+	}
+
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+	cards = filter.Apply(cards)
+	if len(cards) == 0 {
+		return fmt.Errorf("no cards to export in %s", relativeToBase(deckPath, config))
+	}
+
+	result, err := core.ExportAnki(cards, deckPath, *output, *scheduling)
+	if result != nil {
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", *output, err)
+	}
+
+	fmt.Printf("Exported %d notes (%d cards) to %s\n", result.Notes, result.Cards, *output)
+	if result.Media > 0 {
+		fmt.Printf("Bundled %d media files\n", result.Media)
+	}
+	if *scheduling {
+		fmt.Printf("Included scheduling with %d reviews\n", result.Reviews)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"srs/core"
+)
+
+// importCommand imports cards from another program into a subdeck, the base
+// deck by default
+func importCommand(args []string, config *Config) error {
+	if len(args) < 2 || len(args) > 3 || args[0] != "anki" {
+		return fmt.Errorf("usage: srs import anki FILE.apkg [DECK]")
+	}
+
+	deck := "."
+	if len(args) == 3 {
+		deck = args[2]
+	}
+	deckPath, err := resolveDeckPath(deck, config)
+	if err != nil {
+		return fmt.Errorf("invalid deck %s: %v", deck, err)
+	}
+
+	result, err := core.ImportAnki(args[1], deckPath)
+	if result != nil {
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d notes (%d cards, %d reviews) into %s\n", result.Notes, result.Cards, result.Reviews, relativeToBase(deckPath, config))
+	if result.Media > 0 {
+		fmt.Printf("Copied %d media files to %s\n", result.Media, relativeToBase(filepath.Join(deckPath, core.AnkiMediaDir), config))
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("Skipped %d notes already in the deck\n", result.Skipped)
+	}
+	return nil
+}
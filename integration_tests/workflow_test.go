@@ -288,8 +288,10 @@ func TestErrorRecovery(t *testing.T) {
 		t.Fatalf("Failed to send request: %v", err)
 	}
 
-	if response.Error == nil {
-		t.Errorf("Expected error for nonexistent card")
+	// Tool failures come back as results flagged isError
+	toolResult, _ := response.Result.(map[string]interface{})
+	if response.Error != nil || toolResult["isError"] != true {
+		t.Errorf("Expected an isError result for nonexistent card, got %+v", response)
 	}
 
 	// Test 3: Invalid MCP request format
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
+
+	"srs/core"
 )
 
 const usage = `srs - A Unix-style spaced repetition system
@@ -15,8 +19,21 @@ USAGE:
 COMMANDS:
     review                     Show next card (turn-based) or rate current card
     list [SUBDECK]             Show deck tree with due dates and stats
+    mv CARD... DEST            Move cards to another subdeck, keeping their IDs
+    import anki FILE [SUBDECK] Import an Anki .apkg package with its review history
+    export anki [SUBDECK] [-o FILE] [--scheduling]
+                               Export cards as an Anki .apkg package, optionally
+                               with their scheduling and review history
+    optimize [DECK]            Tune FSRS weights to your review history
+    migrate-format [DECK]      Rewrite card metadata in the configured format
+    search QUERY               Find cards by text and fields (lapses>3 due<7d)
+    stats [DECK]               Show retention, due forecast and review history
+    simulate [DECK] [--retention R] [--new-per-day N] [--reviews-per-day N] [--days D]
+                               Project your workload under other settings
     config                     Set up base deck directory
-    mcp                        Start MCP server for AI integration
+    mcp [--http ADDR] [--token TOKEN]
+                               Start MCP server for AI integration (stdio,
+                               or HTTP for several clients)
     update                     Update to the latest version
     version                    Show version information
 
@@ -24,6 +41,19 @@ OPTIONS:
     -i, --interactive          Use interactive TUI mode for review
     -d, --deck SUBDECK         Specify subdeck path for review command
     -r, --rating RATING        Specify rating (1-4) for review command
+    -t, --tag TAG              Only cards with this tag (repeat to require several)
+    --exclude-tag TAG          Skip cards with this tag (repeatable)
+    -f, --format FORMAT        Metadata format (comment or yaml) for migrate-format
+    -o, --order ORDER          Review order: path, due, retrievability, difficulty,
+                               random or interleaved
+    --new-cards PLACEMENT      Put new cards "after" reviews or "mixed" among them
+    --learn-ahead DURATION     Show learning cards up to this early once nothing
+                               else is due (default 20m, 0 to wait)
+    --grader GRADER            Suggest ratings for typed answers: exact, fuzzy,
+                               command or off (default from the config)
+    --undo                     Undo the last rating, then show that card again
+    --json                     Print search results or stats as JSON
+    --stdin                    Review the card IDs listed on stdin (e.g. from search)
     -h, --help                 Show this help message
     -v, --version              Show version information
 
@@ -33,10 +63,26 @@ EXAMPLES:
     srs -d spanish review      # Show next due card from spanish subdirectory
     srs -r 3 review            # Rate current card as "Good" and show next
     srs -d spanish -r 3 review # Rate current card in spanish subdeck as "Good"
+    srs --undo review          # Take back the last rating
     srs -i review              # Start interactive TUI review mode
     srs -i -d spanish review   # Start interactive TUI for spanish subdeck
+    srs -i -o retrievability --new-cards after review # Weakest cards first, new last
     srs list                   # Show tree with due dates and deck stats
     srs list spanish           # Show tree for spanish subdirectory
+    srs -t go -t concurrency review # Review cards tagged both go and concurrency
+    srs --exclude-tag draft list    # Show the deck without draft cards
+    srs mv inbox/ser.md spanish # Move a card into the spanish subdeck
+    srs import anki Spanish.apkg languages # Import an Anki deck under languages
+    srs export anki spanish -o spanish.apkg # Share the spanish subdeck with Anki users
+    srs optimize               # Fit scheduler weights to all your reviews
+    srs -f yaml migrate-format # Move all card metadata into YAML front matter
+    srs search 'state:review lapses>3'       # Find cards you keep forgetting
+    srs -d go search goroutine 'due<7d'      # Text search within the go subdeck
+    srs search 'lapses>3' | srs -i --stdin review # Drill the matches
+    srs stats spanish          # Retention, forecast and heatmap for a subdeck
+    srs --json stats           # The same statistics as JSON
+    srs simulate --retention 0.85 --new-per-day 20 --days 365 # What would it cost?
+    srs mcp --http :8080 --token s3cret # Share the deck with AI clients over HTTP
 
 CARD FORMAT:
     Cards are markdown files:
@@ -45,7 +91,23 @@ CARD FORMAT:
     ---
     O(∛n) per element when load factor is set to the cube root of n. Default load factor is 1,000 ([grantjenks.com](https://grantjenks.com/docs/sortedcontainers/performance-scale.html))
 
-Guidelines for creating excellent flashcards:
+    Several cards can share a file: separate them with a line containing only
+    === (after a blank line).
+
+    Cloze deletions make one card per index, with an optional hint:
+    The {{c1::mitochondria}} is the {{c2::powerhouse::role}} of the cell.
+
+    Front matter with "reverse: true" also quizzes answer→question. Card
+    metadata can live there too (metadata_format=yaml in the config).
+
+    Tag cards with "tags: [go, concurrency]" in front matter, or with a line
+    of hashtags such as "#go #concurrency".
+
+` + cardGuidelines
+
+// cardGuidelines are the rules for writing good cards, shown in the help and
+// given to AI assistants by the MCP server
+const cardGuidelines = `Guidelines for creating excellent flashcards:
 • Be EXTREMELY concise - answers should be 1-2 sentences maximum!
 • Focus on core concepts, relationships, and techniques rather than trivia or isolated facts
 • Break complex ideas into smaller, atomic concepts
@@ -62,9 +124,22 @@ Guidelines for creating excellent flashcards:
 • If quantities are involved, they should be relative, or the unit of measure should be specified in the question
 `
 
+// stringsFlag is a flag that can be given several times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func main() {
-	var help, version, interactive bool
-	var subdeck, rating string
+	var help, version, interactive, jsonOutput, fromStdin, undo bool
+	var subdeck, rating, format, order, newCards, learnAhead, grader string
+	var tags, excludeTags stringsFlag
 	flag.BoolVar(&help, "h", false, "Show help")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "v", false, "Show version")
@@ -75,6 +150,19 @@ func main() {
 	flag.StringVar(&subdeck, "deck", "", "Subdeck path for review command")
 	flag.StringVar(&rating, "r", "", "Rating (1-4) for review command")
 	flag.StringVar(&rating, "rating", "", "Rating (1-4) for review command")
+	flag.Var(&tags, "t", "Only cards with this tag")
+	flag.Var(&tags, "tag", "Only cards with this tag")
+	flag.Var(&excludeTags, "exclude-tag", "Skip cards with this tag")
+	flag.StringVar(&order, "o", "", "Review order")
+	flag.StringVar(&order, "order", "", "Review order")
+	flag.StringVar(&newCards, "new-cards", "", "Placement of new cards: after or mixed")
+	flag.StringVar(&learnAhead, "learn-ahead", "", "How early learning cards are shown once nothing else is due")
+	flag.StringVar(&grader, "grader", "", "Grader suggesting ratings for typed answers")
+	flag.BoolVar(&undo, "undo", false, "Undo the last rating")
+	flag.BoolVar(&jsonOutput, "json", false, "Print search results or stats as JSON")
+	flag.BoolVar(&fromStdin, "stdin", false, "Review the card IDs listed on stdin")
+	flag.StringVar(&format, "f", "", "Metadata format for migrate-format")
+	flag.StringVar(&format, "format", "", "Metadata format for migrate-format")
 	flag.Usage = func() {
 		fmt.Print(usage)
 		
@@ -82,7 +170,7 @@ func main() {
 		config, err := loadConfig()
 		if err == nil && config.BaseDeckPath != "" {
 			fmt.Printf("\nCURRENT DECK:\n")
-			err := statusCommand(config.BaseDeckPath)
+			err := statusCommand(config.BaseDeckPath, core.TagFilter{})
 			if err != nil {
 				fmt.Printf("(Unable to load deck: %v)\n", err)
 			}
@@ -132,15 +220,48 @@ func main() {
 		}
 	}
 	
+	// Use tuned FSRS weights for every scheduler if they've been optimized
+	if weights, ok := config.fsrsWeights(); ok {
+		core.SetWeights(weights)
+	}
+	
+	// Write card metadata in the configured format
+	if config.MetadataFormat != "" {
+		if metadataFormat, err := core.ParseMetadataFormat(config.MetadataFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			core.SetMetadataFormat(metadataFormat)
+		}
+	}
+	
+	// Make sure the base deck has a data directory so reviews get journaled
+	if config.BaseDeckPath != "" {
+		if _, err := os.Stat(config.BaseDeckPath); err == nil {
+			if err := core.InitDeckRoot(config.BaseDeckPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to create data directory: %v\n", err)
+			}
+		}
+	}
+	
+	filter := core.TagFilter{Include: tags, Exclude: excludeTags}
+	
 	var deckPath string
+	var simulation simulateOptions
 	
 	// Handle subdeck path
-	if command == "review" {
+	if command == "review" || command == "search" {
 		if subdeck != "" {
 			deckPath = subdeck
 		} else {
 			deckPath = "."
 		}
+	} else if command == "simulate" {
+		// simulate takes its own flags around the deck
+		deckPath, simulation, err = parseSimulateArgs(args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	} else {
 		// For other commands, use positional argument
 		if len(args) > 1 {
@@ -151,7 +272,7 @@ func main() {
 	}
 
 	// Resolve deck path using config (unless it's a command that doesn't need a deck)
-	if command != "config" && command != "version" && command != "update" && command != "mcp" {
+	if command != "config" && command != "version" && command != "update" && command != "mcp" && command != "mv" && command != "import" && command != "export" {
 		resolvedPath, err := resolveDeckPath(deckPath, config)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Invalid path %s: %v\n", deckPath, err)
@@ -171,13 +292,93 @@ func main() {
 		// Check for updates before starting review (non-blocking)
 		go checkForUpdates()
 		
-		err := reviewCommand(deckPath, rating, interactive)
+		if undo {
+			if rating != "" {
+				fmt.Fprintf(os.Stderr, "Error: --undo can't be combined with a rating\n")
+				os.Exit(1)
+			}
+			if err := undoCommand(deckPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		
+		var only []string
+		if fromStdin {
+			only, err = readCardList(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to read cards from stdin: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		
+		window, err := config.learnAhead(learnAhead)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		answerGrader, err := config.grader(grader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		
+		err = reviewCommand(deckPath, rating, interactive, filter, only, core.QueueOrder{Sort: order, NewCards: newCards}, window, answerGrader)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 	case "list":
-		err := statusCommand(deckPath)
+		err := statusCommand(deckPath, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "mv":
+		err := moveCommand(args[1:], config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "import":
+		err := importCommand(args[1:], config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "export":
+		err := exportCommand(args[1:], config, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "optimize":
+		err := optimizeCommand(deckPath, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "simulate":
+		err := simulateCommand(deckPath, simulation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "stats":
+		err := statsCommand(deckPath, filter, jsonOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "search":
+		err := searchCommand(deckPath, strings.Join(args[1:], " "), filter, jsonOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "migrate-format":
+		err := migrateFormatCommand(deckPath, format, config)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -191,7 +392,7 @@ func main() {
 	case "version":
 		printVersion()
 	case "mcp":
-		err := mcpSimpleCommand()
+		err := mcpSimpleCommand(args[1:])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -209,19 +410,77 @@ func main() {
 	}
 }
 
-func reviewCommand(deckPath, rating string, interactive bool) error {
+// reviewQueue orders the cards due in a deck and applies its daily limits,
+// returning the queue and how many due cards the limits held back. With
+// nothing else due, learning cards coming up within learnAhead are queued.
+func reviewQueue(deckPath string, cards []*Card, filter core.TagFilter, order core.QueueOrder, learnAhead time.Duration, now time.Time) ([]*Card, int, error) {
+	settings, err := core.EffectiveSettings(core.FindDeckRoot(deckPath), deckPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load deck settings: %v", err)
+	}
+	order = settings.QueueOrder().Override(order)
+
+	allDue := core.OrderQueue(getDueCards(filter.Apply(cards)), order, now)
+	dueCards, err := core.ApplyDailyLimits(deckPath, cards, allDue, now)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to apply daily limits: %v", err)
+	}
+	heldBack := len(allDue) - len(dueCards)
+
+	if len(dueCards) == 0 {
+		dueCards = core.LearnAheadCards(filter.Apply(cards), now, learnAhead)
+	}
+	return dueCards, heldBack, nil
+}
+
+// reviewCommand reviews the due cards in a deck, or when only is non-nil the
+// cards it lists whether or not they're due. The order flags override the
+// deck's configured order. A non-nil grader suggests ratings for typed answers.
+func reviewCommand(deckPath, rating string, interactive bool, filter core.TagFilter, only []string, order core.QueueOrder, learnAhead time.Duration, grader core.Grader) error {
+	if err := order.Validate(); err != nil {
+		return err
+	}
+
 	cards, err := findCards(deckPath)
 	if err != nil {
 		return fmt.Errorf("failed to load cards: %v", err)
 	}
 
-	dueCards := getDueCards(cards)
+	now := time.Now()
+	var dueCards []*Card
+	heldBack := 0
+	if only != nil {
+		// Piped cards keep their order unless one is asked for
+		dueCards = core.OrderQueue(filter.Apply(selectCards(cards, only)), order, now)
+	} else {
+		dueCards, heldBack, err = reviewQueue(deckPath, cards, filter, order, learnAhead, now)
+		if err != nil {
+			return err
+		}
+	}
 	if len(dueCards) == 0 {
+		if heldBack > 0 {
+			fmt.Printf("Daily limits reached in %s: %d more cards are due but held back until tomorrow\n", deckPath, heldBack)
+			return nil
+		}
+		if only != nil {
+			fmt.Println("None of the listed cards were found")
+			return nil
+		}
+		if !filter.IsEmpty() {
+			fmt.Printf("No cards tagged %s are due for review in %s\n", filter, deckPath)
+			return nil
+		}
 		fmt.Printf("No cards are due for review in %s\n", deckPath)
+		fmt.Print(pendingSummary(core.LearnAheadCards(cards, now, 24*time.Hour), now))
 		return nil
 	}
 
 	session := NewReviewSession(dueCards)
+	session.learnAhead = learnAhead
+	session.grader = grader
+	session.deckPath = deckPath
+	session.deck = filter.Apply(cards)
 	
 	if interactive {
 		// Use TUI mode
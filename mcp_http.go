@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpEndpoint is the path the Streamable HTTP transport is served on
+const mcpEndpoint = "/mcp"
+
+// Headers of the Streamable HTTP transport
+const (
+	mcpSessionHeader  = "Mcp-Session-Id"
+	mcpProtocolHeader = "Mcp-Protocol-Version"
+)
+
+// mcpTokenEnv holds the bearer token when --token isn't given
+const mcpTokenEnv = "SRS_MCP_TOKEN"
+
+// Idle sessions are dropped after mcpSessionIdle. A session buffers up to
+// mcpEventBuffer notifications for its event stream, which gets a comment
+// every mcpKeepAlive so proxies keep it open.
+const (
+	mcpSessionIdle = 24 * time.Hour
+	mcpEventBuffer = 64
+	mcpKeepAlive   = 30 * time.Second
+)
+
+// mcpSession is one client of the HTTP transport
+type mcpSession struct {
+	server   *mcpServer
+	events   chan []byte // notifications waiting for the client's event stream
+	lastUsed time.Time
+}
+
+// mcpHTTPHandler serves MCP over the Streamable HTTP transport: clients POST
+// JSON-RPC messages and get the responses back as JSON, and may GET a
+// server-sent event stream for notifications. Every session has its own
+// server state, but they share one lock, since they share the deck.
+type mcpHTTPHandler struct {
+	config *Config
+	token  string // required bearer token; empty allows anyone
+
+	mu       sync.Mutex // guards sessions
+	sessions map[string]*mcpSession
+	deck     sync.Mutex // serializes messages across sessions
+}
+
+func newMCPHTTPHandler(config *Config, token string) *mcpHTTPHandler {
+	return &mcpHTTPHandler{
+		config:   config,
+		token:    token,
+		sessions: make(map[string]*mcpSession),
+	}
+}
+
+func (h *mcpHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="srs"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Browsers send an Origin; only accept pages served from this host, so
+	// web pages can't reach a server on localhost through DNS rebinding
+	if origin := r.Header.Get("Origin"); origin != "" && !sameHost(origin, r.Host) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if version := r.Header.Get(mcpProtocolHeader); version != "" && !supportedVersion(version) {
+		http.Error(w, "Unsupported protocol version "+version, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodGet:
+		h.stream(w, r)
+	case http.MethodDelete:
+		h.mu.Lock()
+		_, ok := h.sessions[r.Header.Get(mcpSessionHeader)]
+		delete(h.sessions, r.Header.Get(mcpSessionHeader))
+		h.mu.Unlock()
+		if !ok {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks the request's bearer token, if one is required
+func (h *mcpHTTPHandler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// sameHost reports whether an Origin header names the host being served
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+func supportedVersion(version string) bool {
+	for _, v := range mcpProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// post handles a JSON-RPC message sent by a client. An initialize request
+// starts a new session; everything else must carry the session's ID.
+func (h *mcpHTTPHandler) post(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMCPMessage))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		http.Error(w, "Empty request", http.StatusBadRequest)
+		return
+	}
+
+	var session *mcpSession
+	var probe struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &probe) == nil && probe.Method == "initialize" {
+		id, err := newSessionID()
+		if err != nil {
+			http.Error(w, "Failed to start session", http.StatusInternalServerError)
+			return
+		}
+		session = h.newSession()
+		h.mu.Lock()
+		h.sessions[id] = session
+		h.mu.Unlock()
+		w.Header().Set(mcpSessionHeader, id)
+	} else {
+		id := r.Header.Get(mcpSessionHeader)
+		if id == "" {
+			http.Error(w, "Missing "+mcpSessionHeader+" header; send initialize first", http.StatusBadRequest)
+			return
+		}
+		if session = h.session(id); session == nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	reply := session.server.handleMessage(body)
+	if reply == nil {
+		// Notifications and responses are only acknowledged
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
+}
+
+// stream sends a session's notifications as server-sent events until the
+// client disconnects, watching the deck for changes meanwhile
+func (h *mcpHTTPHandler) stream(w http.ResponseWriter, r *http.Request) {
+	session := h.session(r.Header.Get(mcpSessionHeader))
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go session.server.watch(stop)
+
+	keepAlive := time.NewTicker(mcpKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-session.events:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// newSession creates a session whose notifications queue up for its event
+// stream, dropping them if the client isn't listening
+func (h *mcpHTTPHandler) newSession() *mcpSession {
+	session := &mcpSession{
+		server:   newMCPServer(h.config),
+		events:   make(chan []byte, mcpEventBuffer),
+		lastUsed: time.Now(),
+	}
+	session.server.mu = &h.deck
+	session.server.notify = func(method string, params interface{}) {
+		data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+		if err != nil {
+			return
+		}
+		select {
+		case session.events <- data:
+		default:
+		}
+	}
+	return session
+}
+
+// session looks up a session by ID, dropping sessions that have been idle
+// too long
+func (h *mcpHTTPHandler) session(id string) *mcpSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for key, session := range h.sessions {
+		if now.Sub(session.lastUsed) > mcpSessionIdle {
+			delete(h.sessions, key)
+		}
+	}
+
+	session := h.sessions[id]
+	if session != nil {
+		session.lastUsed = now
+	}
+	return session
+}
+
+// newSessionID returns a random, unguessable session ID
+func newSessionID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// serveMCPHTTP runs the MCP server on an HTTP address such as ":8080"
+func serveMCPHTTP(config *Config, addr, token string) error {
+	if token == "" && !loopbackAddr(addr) {
+		return fmt.Errorf("refusing to serve %s without a token; set --token or %s, or listen on a loopback address such as 127.0.0.1:8080", addr, mcpTokenEnv)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "Warning: no --token or %s set; anyone on this machine can read and change your cards\n", mcpTokenEnv)
+	}
+	fmt.Fprintf(os.Stderr, "MCP server listening on http://%s%s\n", listener.Addr(), mcpEndpoint)
+
+	mux := http.NewServeMux()
+	mux.Handle(mcpEndpoint, newMCPHTTPHandler(config, token))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.Serve(listener)
+}
+
+// loopbackAddr reports whether an address such as "127.0.0.1:8080" only
+// listens on the loopback interface. An empty host listens on every
+// interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mcpPromptArgument is an argument a prompt takes
+type mcpPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// mcpPrompt is a prompt template offered to clients
+type mcpPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Arguments   []mcpPromptArgument `json:"arguments"`
+}
+
+var mcpPrompts = []mcpPrompt{
+	{
+		Name:        "quiz",
+		Description: "Quiz me on the cards due in a deck, rating each one as we go",
+		Arguments: []mcpPromptArgument{
+			{Name: "deck_path", Description: "Deck to quiz on (relative to base deck path, defaults to '.')"},
+			{Name: "count", Description: "Stop after this many cards"},
+		},
+	},
+	{
+		Name:        "create_cards",
+		Description: "Turn a text into flashcards that follow the srs card guidelines",
+		Arguments: []mcpPromptArgument{
+			{Name: "text", Description: "Text to make cards from", Required: true},
+			{Name: "deck_path", Description: "Deck to add the cards to (relative to base deck path, defaults to '.')"},
+		},
+	},
+}
+
+func (s *mcpServer) getPrompt(raw json.RawMessage) (interface{}, *MCPError) {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+
+	var prompt *mcpPrompt
+	for i := range mcpPrompts {
+		if mcpPrompts[i].Name == params.Name {
+			prompt = &mcpPrompts[i]
+		}
+	}
+	if prompt == nil {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "unknown prompt: " + params.Name}
+	}
+	for _, argument := range prompt.Arguments {
+		if argument.Required && strings.TrimSpace(params.Arguments[argument.Name]) == "" {
+			return nil, &MCPError{Code: rpcInvalidParams, Message: fmt.Sprintf("prompt %s needs argument %s", prompt.Name, argument.Name)}
+		}
+	}
+
+	deckPath := params.Arguments["deck_path"]
+	if deckPath == "" {
+		deckPath = "."
+	}
+
+	var text string
+	switch prompt.Name {
+	case "quiz":
+		limit := ""
+		if count := params.Arguments["count"]; count != "" {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 {
+				return nil, &MCPError{Code: rpcInvalidParams, Message: "count must be a positive number, got " + count}
+			}
+			limit = fmt.Sprintf(" Stop after %d cards.", n)
+		}
+		text = fmt.Sprintf(`Quiz me on my flashcards in the deck %q. Start a session with the srs/start_session tool (deck_path %q) and go through its cards one at a time.%s
+
+For each card, get the question with srs/next_card and show it to me, then wait for my answer. Only then call srs/reveal_answer, show me the answer, say briefly how mine compares, and suggest a rating:
+1 = Again (I forgot), 2 = Hard (recalled with serious difficulty), 3 = Good (recalled after some thought), 4 = Easy (recalled instantly).
+Once I accept or change the rating, record it with srs/rate_current, then move on to the next card.
+
+If no cards are due, tell me so. When the cards run out or we stop, call srs/end_session and summarize how I did and which cards I struggled with.`, deckPath, deckPath, limit)
+	case "create_cards":
+		text = fmt.Sprintf(`Create flashcards from the text below and add each one with the srs/create_card tool (deck_path %q). Add tags where they help group the cards. If a card is refused because a card already asks the same question, skip it or make it ask something new.
+
+%s
+Text:
+%s`, deckPath, cardGuidelines, params.Arguments["text"])
+	}
+
+	return map[string]interface{}{
+		"description": prompt.Description,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": map[string]interface{}{"type": "text", "text": text},
+			},
+		},
+	}, nil
+}
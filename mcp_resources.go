@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"srs/core"
+)
+
+// Resource URIs: srs://deck/ is the base deck, srs://deck/spanish/verbs a
+// subdeck, and srs://card/ID a card
+const (
+	deckURIPrefix = "srs://deck/"
+	cardURIPrefix = "srs://card/"
+)
+
+// rpcResourceNotFound is the MCP error code for reading a missing resource
+const rpcResourceNotFound = -32002
+
+// resourcePageSize is the number of resources per resources/list page
+const resourcePageSize = 200
+
+// mcpResource is an entry of resources/list
+type mcpResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+var resourceTemplates = []map[string]interface{}{
+	{
+		"uriTemplate": deckURIPrefix + "{path}",
+		"name":        "Deck",
+		"description": "A deck or subdeck by path relative to the base deck: its cards, due counts and subdecks",
+		"mimeType":    "application/json",
+	},
+	{
+		"uriTemplate": cardURIPrefix + "{id}",
+		"name":        "Card",
+		"description": "A card by its ID, or its path until it has one: question, answer, tags and schedule",
+		"mimeType":    "application/json",
+	},
+}
+
+func deckURI(rel string) string {
+	if rel == "." {
+		rel = ""
+	}
+	return deckURIPrefix + filepath.ToSlash(rel)
+}
+
+func cardURI(card *Card) string {
+	return cardURIPrefix + card.Key()
+}
+
+// deckOf returns the path of a card's deck relative to the base deck
+func (s *mcpServer) deckOf(card *Card) string {
+	return s.relativeDeck(filepath.Dir(card.FilePath))
+}
+
+// deckPaths returns every deck holding cards, with their parent decks, as
+// paths relative to the base deck, sorted
+func (s *mcpServer) deckPaths(cards []*Card) []string {
+	seen := map[string]bool{".": true}
+	for _, card := range cards {
+		for dir := s.deckOf(card); dir != "." && !strings.HasPrefix(dir, ".."); dir = filepath.Dir(dir) {
+			seen[dir] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// resourceList lists all decks and then all cards as resources
+func (s *mcpServer) resourceList() ([]mcpResource, error) {
+	cards, err := findCards(s.config.BaseDeckPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cards: %v", err)
+	}
+
+	var resources []mcpResource
+	for _, path := range s.deckPaths(cards) {
+		name := "Deck " + filepath.ToSlash(path)
+		if path == "." {
+			name = "Base deck"
+		}
+		resources = append(resources, mcpResource{URI: deckURI(path), Name: name, MimeType: "application/json"})
+	}
+	for _, card := range cards {
+		resources = append(resources, mcpResource{
+			URI:         cardURI(card),
+			Name:        card.Name(),
+			Description: firstLine(card.Question),
+			MimeType:    "application/json",
+		})
+	}
+	return resources, nil
+}
+
+// firstLine shortens text to its first line, for descriptions
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > 80 {
+		line = string(runes[:79]) + "…"
+	}
+	return line
+}
+
+func (s *mcpServer) listResources(raw json.RawMessage) (interface{}, *MCPError) {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+		}
+	}
+
+	// Cursors are offsets into the list
+	offset := 0
+	if params.Cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(params.Cursor); err != nil || offset < 0 {
+			return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: bad cursor " + params.Cursor}
+		}
+	}
+
+	resources, err := s.resourceList()
+	if err != nil {
+		return nil, &MCPError{Code: rpcInternalError, Message: err.Error()}
+	}
+
+	offset = min(offset, len(resources))
+	end := min(offset+resourcePageSize, len(resources))
+	result := map[string]interface{}{"resources": resources[offset:end]}
+	if end < len(resources) {
+		result["nextCursor"] = strconv.Itoa(end)
+	}
+	return result, nil
+}
+
+func (s *mcpServer) readResource(raw json.RawMessage) (interface{}, *MCPError) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || params.URI == "" {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: uri is required"}
+	}
+
+	var content interface{}
+	var err error
+	switch {
+	case strings.HasPrefix(params.URI, deckURIPrefix):
+		content, err = s.readDeck(strings.TrimPrefix(params.URI, deckURIPrefix))
+	case strings.HasPrefix(params.URI, cardURIPrefix):
+		content, err = s.readCard(strings.TrimPrefix(params.URI, cardURIPrefix))
+	default:
+		err = fmt.Errorf("unknown resource %s", params.URI)
+	}
+	if err != nil {
+		return nil, &MCPError{Code: rpcResourceNotFound, Message: fmt.Sprintf("Resource not found: %v", err)}
+	}
+
+	text, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return nil, &MCPError{Code: rpcInternalError, Message: fmt.Sprintf("failed to encode resource: %v", err)}
+	}
+	return map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"uri": params.URI, "mimeType": "application/json", "text": string(text)},
+		},
+	}, nil
+}
+
+// readDeck describes a deck: counts, subdecks and its cards
+func (s *mcpServer) readDeck(path string) (interface{}, error) {
+	path = strings.Trim(path, "/")
+	resolved, err := resolveDeckPathWithin(path, s.config)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("no deck %s", path)
+	}
+
+	cards, err := findCards(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cards: %v", err)
+	}
+
+	rel := s.relativeDeck(resolved)
+	states := make(map[string]int)
+	var subdecks []string
+	var list []map[string]interface{}
+	for _, card := range cards {
+		states[stateString(card.FSRSCard.State)]++
+		list = append(list, map[string]interface{}{
+			"uri":      cardURI(card),
+			"card_id":  card.Key(),
+			"name":     card.Name(),
+			"question": card.Question,
+			"due":      card.FSRSCard.Due.Format("2006-01-02T15:04:05Z"),
+			"state":    stateString(card.FSRSCard.State),
+		})
+	}
+	for _, deck := range s.deckPaths(cards) {
+		if deck != "." && filepath.Dir(deck) == rel {
+			subdecks = append(subdecks, deckURI(deck))
+		}
+	}
+
+	return map[string]interface{}{
+		"uri":         deckURI(rel),
+		"deck_path":   filepath.ToSlash(rel),
+		"total_cards": len(cards),
+		"due_count":   len(getDueCards(cards)),
+		"states":      states,
+		"subdecks":    subdecks,
+		"cards":       list,
+	}, nil
+}
+
+// relativeDeck returns a deck directory relative to the base deck
+func (s *mcpServer) relativeDeck(dir string) string {
+	base, _ := filepath.Abs(s.config.BaseDeckPath)
+	dir, _ = filepath.Abs(dir)
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return "."
+	}
+	return rel
+}
+
+// readCard describes a card with its full schedule
+func (s *mcpServer) readCard(id string) (interface{}, error) {
+	card, err := core.FindCardByID(s.config.BaseDeckPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := cardResult(s.config, card)
+	result["uri"] = cardURI(card)
+	result["deck"] = deckURI(s.deckOf(card))
+	result["difficulty"] = card.FSRSCard.Difficulty
+	result["stability"] = card.FSRSCard.Stability
+	result["lapses"] = card.FSRSCard.Lapses
+	return result, nil
+}
+
+func (s *mcpServer) subscribe(raw json.RawMessage, on bool) (interface{}, *MCPError) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || params.URI == "" {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: uri is required"}
+	}
+	if !strings.HasPrefix(params.URI, deckURIPrefix) && !strings.HasPrefix(params.URI, cardURIPrefix) {
+		return nil, &MCPError{Code: rpcResourceNotFound, Message: "Resource not found: " + params.URI}
+	}
+
+	if on {
+		s.subscriptions[params.URI] = true
+	} else {
+		delete(s.subscriptions, params.URI)
+	}
+	return map[string]interface{}{}, nil
+}
+
+// checkChanges notifies the client of resources that changed since the last
+// check
+func (s *mcpServer) checkChanges() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkChangesLocked()
+}
+
+// checkChangesLocked is checkChanges with s.mu held. Card files are only
+// reparsed when their names, sizes or modification times changed.
+func (s *mcpServer) checkChangesLocked() {
+	files := filesFingerprint(s.config.BaseDeckPath)
+	if s.resources != nil && files == s.files {
+		return
+	}
+
+	current, err := s.resourceFingerprints()
+	if err != nil {
+		return
+	}
+	previous := s.resources
+	s.resources, s.files = current, files
+	if previous == nil || !s.initialized || s.notify == nil {
+		return
+	}
+
+	listChanged := false
+	for uri, fingerprint := range current {
+		old, ok := previous[uri]
+		if !ok {
+			listChanged = true
+		}
+		if ok && old != fingerprint && s.subscriptions[uri] {
+			s.notify("notifications/resources/updated", map[string]interface{}{"uri": uri})
+		}
+	}
+	for uri := range previous {
+		if _, ok := current[uri]; !ok {
+			listChanged = true
+			if s.subscriptions[uri] {
+				s.notify("notifications/resources/updated", map[string]interface{}{"uri": uri})
+			}
+		}
+	}
+	if listChanged {
+		s.notify("notifications/resources/list_changed", map[string]interface{}{})
+	}
+}
+
+// resourceFingerprints maps each resource URI to a fingerprint of its
+// content. A deck's fingerprint covers all the cards in it and its subdecks.
+func (s *mcpServer) resourceFingerprints() (map[string]string, error) {
+	cards, err := findCards(s.config.BaseDeckPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make(map[string]string)
+	decks := make(map[string]*strings.Builder)
+	for _, path := range s.deckPaths(cards) {
+		decks[path] = &strings.Builder{}
+	}
+	for _, card := range cards {
+		fingerprint := fmt.Sprintf("%s|%s|%s|%v|%v|%s|%d|%s", card.FilePath, card.Question, card.Answer, card.Tags,
+			card.FSRSCard.Due.Unix(), stateString(card.FSRSCard.State), card.FSRSCard.Reps, card.Name())
+		fingerprints[cardURI(card)] = fingerprint
+
+		for dir := s.deckOf(card); ; dir = filepath.Dir(dir) {
+			if deck := decks[dir]; deck != nil {
+				deck.WriteString(cardURI(card) + fingerprint + "\n")
+			}
+			if dir == "." || strings.HasPrefix(dir, "..") {
+				break
+			}
+		}
+	}
+	for path, deck := range decks {
+		hash := fnv.New64a()
+		hash.Write([]byte(deck.String()))
+		fingerprints[deckURI(path)] = strconv.FormatUint(hash.Sum64(), 16)
+	}
+	return fingerprints, nil
+}
+
+// filesFingerprint summarizes the names, sizes and modification times of the
+// card files under a deck
+func filesFingerprint(deckPath string) string {
+	hash := fnv.New64a()
+	filepath.Walk(deckPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && info.Name() == core.DataDirName {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
+			fmt.Fprintf(hash, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// mcpProtocolVersions are the MCP revisions the server speaks, newest first
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// maxMCPMessage is the largest message read from stdin
+const maxMCPMessage = 16 * 1024 * 1024
+
+// MCP Protocol types
+type MCPRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type MCPResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *MCPError        `json:"error,omitempty"`
+}
+
+type MCPError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type ToolCallParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// mcpTool is a tool offered to clients, with the function that runs it
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	handler     func(config *Config, args map[string]interface{}) (interface{}, error)
+}
+
+// watchInterval is how often the deck is checked for changes made outside
+// the server, for resource notifications
+const watchInterval = 2 * time.Second
+
+// mcpServer answers MCP requests for one client connection
+type mcpServer struct {
+	config          *Config
+	tools           []mcpTool
+	protocolVersion string // negotiated in initialize
+	initialized     bool   // the client has finished the handshake
+
+	// notify sends a notification to the client; nil drops them
+	notify func(method string, params interface{})
+
+	mu            *sync.Mutex       // serializes messages and change checks, shared by HTTP sessions
+	resources     map[string]string // fingerprint of each resource, nil until first checked
+	files         string            // fingerprint of the deck's files at the last check
+	subscriptions map[string]bool   // resource URIs the client subscribed to
+}
+
+func newMCPServer(config *Config) *mcpServer {
+	return &mcpServer{
+		config:          config,
+		tools:           mcpTools(),
+		protocolVersion: mcpProtocolVersions[0],
+		mu:              &sync.Mutex{},
+		subscriptions:   make(map[string]bool),
+	}
+}
+
+// serve reads newline-delimited JSON-RPC messages from r and writes the
+// responses and notifications to w, one per line
+func (s *mcpServer) serve(r io.Reader, w io.Writer) error {
+	var out sync.Mutex
+	var writeErr error
+	write := func(data []byte) {
+		out.Lock()
+		defer out.Unlock()
+		if _, err := fmt.Fprintf(w, "%s\n", data); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+	s.notify = func(method string, params interface{}) {
+		data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+		if err == nil {
+			write(data)
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watch(stop)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMCPMessage)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if reply := s.handleMessage(line); reply != nil {
+			write(reply)
+		}
+		out.Lock()
+		err := writeErr
+		out.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// watch checks the deck for changes until stop is closed
+func (s *mcpServer) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.checkChanges()
+		}
+	}
+}
+
+// handleMessage answers a single message or batch, returning nil when
+// nothing needs to be sent back
+func (s *mcpServer) handleMessage(data []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return marshalResponse(errorResponse(nil, rpcParseError, "Parse error: "+err.Error()))
+		}
+		if len(batch) == 0 {
+			return marshalResponse(errorResponse(nil, rpcInvalidRequest, "Invalid Request: empty batch"))
+		}
+		var responses []*MCPResponse
+		for _, message := range batch {
+			if resp := s.handleRaw(message); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		reply, _ := json.Marshal(responses)
+		return reply
+	}
+
+	if resp := s.handleRaw(data); resp != nil {
+		return marshalResponse(resp)
+	}
+	return nil
+}
+
+// handleRaw decodes and answers one message of a batch or line
+func (s *mcpServer) handleRaw(data []byte) *MCPResponse {
+	var req MCPRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		if json.Valid(data) {
+			return errorResponse(nil, rpcInvalidRequest, "Invalid Request: "+err.Error())
+		}
+		return errorResponse(nil, rpcParseError, "Parse error: "+err.Error())
+	}
+
+	// Missing "jsonrpc" is tolerated for older clients, anything else but
+	// 2.0 is not
+	if req.JSONRPC != "" && req.JSONRPC != "2.0" {
+		return errorResponse(req.ID, rpcInvalidRequest, fmt.Sprintf("Invalid Request: unsupported jsonrpc version %q", req.JSONRPC))
+	}
+	if req.Method == "" {
+		// Responses to requests we never send, or a request without a method
+		if req.ID == nil {
+			return nil
+		}
+		return errorResponse(req.ID, rpcInvalidRequest, "Invalid Request: missing method")
+	}
+
+	result, rpcErr := s.dispatch(req)
+	if req.ID == nil {
+		// Notifications never get a response
+		return nil
+	}
+	if rpcErr != nil {
+		return &MCPResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return &MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// dispatch runs a request's method
+func (s *mcpServer) dispatch(req MCPRequest) (interface{}, *MCPError) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "notifications/initialized":
+		// Changes are reported from here on
+		s.initialized = true
+		s.checkChangesLocked()
+		return nil, nil
+	case "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.tools}, nil
+	case "tools/call":
+		result, rpcErr := s.callTool(req.Params)
+		// Tools may have changed cards, so tell the client right away
+		s.checkChangesLocked()
+		return result, rpcErr
+	case "resources/list":
+		return s.listResources(req.Params)
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": resourceTemplates}, nil
+	case "resources/read":
+		return s.readResource(req.Params)
+	case "resources/subscribe", "resources/unsubscribe":
+		return s.subscribe(req.Params, req.Method == "resources/subscribe")
+	case "prompts/list":
+		return map[string]interface{}{"prompts": mcpPrompts}, nil
+	case "prompts/get":
+		return s.getPrompt(req.Params)
+	}
+	return nil, &MCPError{Code: rpcMethodNotFound, Message: "Method not found: " + req.Method}
+}
+
+// initialize negotiates the protocol version and describes the server
+func (s *mcpServer) initialize(raw json.RawMessage) (interface{}, *MCPError) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+		}
+	}
+
+	// Use the client's version if we speak it, otherwise offer our latest
+	s.protocolVersion = mcpProtocolVersions[0]
+	for _, version := range mcpProtocolVersions {
+		if version == params.ProtocolVersion {
+			s.protocolVersion = version
+		}
+	}
+
+	return map[string]interface{}{
+		"protocolVersion": s.protocolVersion,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{"listChanged": false},
+			"resources": map[string]interface{}{"subscribe": true, "listChanged": true},
+			"prompts":   map[string]interface{}{"listChanged": false},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "srs",
+			"version": Version,
+		},
+	}, nil
+}
+
+// callTool runs a tool. Failures of the tool itself are reported in the
+// result with isError, so the model can see them and recover.
+func (s *mcpServer) callTool(raw json.RawMessage) (interface{}, *MCPError) {
+	var params ToolCallParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+
+	var tool *mcpTool
+	for i := range s.tools {
+		if s.tools[i].Name == params.Name {
+			tool = &s.tools[i]
+		}
+	}
+	if tool == nil {
+		return nil, &MCPError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+
+	result, err := tool.handler(s.config, params.Arguments)
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+
+	text, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, &MCPError{Code: rpcInternalError, Message: fmt.Sprintf("failed to encode result: %v", err)}
+	}
+	response := toolResult(string(text), false)
+	if s.protocolVersion >= "2025-06-18" {
+		// Structured content must be a JSON object
+		var structured map[string]interface{}
+		if json.Unmarshal(text, &structured) == nil {
+			response["structuredContent"] = structured
+		}
+	}
+	return response, nil
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]interface{}{
+			{"type": "text", "text": text},
+		},
+		"isError": isError,
+	}
+}
+
+func errorResponse(id *json.RawMessage, code int, message string) *MCPResponse {
+	return &MCPResponse{JSONRPC: "2.0", ID: id, Error: &MCPError{Code: code, Message: message}}
+}
+
+func marshalResponse(resp *MCPResponse) []byte {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(resp.ID, rpcInternalError, fmt.Sprintf("failed to encode response: %v", err)))
+	}
+	return data
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"srs/core"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// quizSessionIdle is how long an unused quiz session is kept before it's
+// ended for the client
+const quizSessionIdle = 2 * time.Hour
+
+// quizSession is a review session driven one step at a time through MCP
+// tools, so an assistant can quiz the user without seeing answers early
+type quizSession struct {
+	review   *ReviewSession
+	shown    bool // the current card's question has been given out
+	revealed bool // the current card's answer has been given out
+	heldBack int  // due cards the daily limits left out
+	lastUsed time.Time
+}
+
+// quizSessions holds the quiz sessions of every MCP client by ID
+var quizSessions = struct {
+	sync.Mutex
+	byID map[string]*quizSession
+}{byID: make(map[string]*quizSession)}
+
+// getQuizSession looks up the session named by a tool's session_id argument,
+// ending sessions that have been idle too long
+func getQuizSession(args map[string]interface{}) (*quizSession, string, error) {
+	id, _ := args["session_id"].(string)
+	if id == "" {
+		return nil, "", fmt.Errorf("session_id is required; start one with srs/start_session")
+	}
+
+	now := time.Now()
+	quizSessions.Lock()
+	defer quizSessions.Unlock()
+	for key, session := range quizSessions.byID {
+		if now.Sub(session.lastUsed) > quizSessionIdle {
+			session.review.saveRecord(session.review.record(session.lastUsed))
+			delete(quizSessions.byID, key)
+		}
+	}
+
+	session := quizSessions.byID[id]
+	if session == nil {
+		return nil, "", fmt.Errorf("no session %s; it may have ended or expired after %s idle", id, quizSessionIdle)
+	}
+	session.lastUsed = now
+	return session, id, nil
+}
+
+func handleStartSession(config *Config, args map[string]interface{}) (interface{}, error) {
+	deckPath := "."
+	if path, ok := args["deck_path"].(string); ok && path != "" {
+		deckPath = path
+	}
+	sort, _ := args["order"].(string)
+	order := core.QueueOrder{Sort: sort}
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+
+	resolvedPath, err := resolveDeckPathWithin(deckPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving deck path: %v", err)
+	}
+	learnAhead, err := config.learnAhead("")
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := findCards(resolvedPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cards: %v", err)
+	}
+
+	now := time.Now()
+	filter := core.TagFilter{Include: stringArgs(args, "tags"), Exclude: stringArgs(args, "exclude_tags")}
+	queue, heldBack, err := reviewQueue(resolvedPath, cards, filter, order, learnAhead, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("error starting session: %v", err)
+	}
+	review := NewReviewSession(queue)
+	review.learnAhead = learnAhead
+	review.deckPath = resolvedPath
+	review.deck = filter.Apply(cards)
+	review.started = now
+
+	quizSessions.Lock()
+	quizSessions.byID[id] = &quizSession{review: review, heldBack: heldBack, lastUsed: now}
+	quizSessions.Unlock()
+
+	result := map[string]interface{}{
+		"session_id": id,
+		"deck_path":  deckPath,
+		"cards_due":  len(queue),
+		"held_back":  heldBack,
+	}
+	if len(queue) == 0 {
+		result["message"] = "No cards are due. Call srs/end_session."
+	} else {
+		result["message"] = "Call srs/next_card to get the first question."
+	}
+	return result, nil
+}
+
+func handleNextCard(config *Config, args map[string]interface{}) (interface{}, error) {
+	session, id, err := getQuizSession(args)
+	if err != nil {
+		return nil, err
+	}
+	review := session.review
+	now := time.Now()
+
+	early, _ := args["early"].(bool)
+	if !review.learnAheadCard(now, early) {
+		pending := review.pending()
+		if len(pending) == 0 {
+			return map[string]interface{}{
+				"session_id": id,
+				"done":       true,
+				"reviewed":   len(review.reviews),
+				"message":    "Every card has been reviewed. Call srs/end_session for the summary.",
+			}, nil
+		}
+		wait := pending[0].FSRSCard.Due.Sub(now)
+		return map[string]interface{}{
+			"session_id":  id,
+			"done":        false,
+			"waiting":     true,
+			"pending":     len(pending),
+			"next_due_in": core.FormatWait(wait),
+			"message":     fmt.Sprintf("%d learning cards are still pending, the next in %s. Call srs/next_card again then, or with early=true to review it now.", len(pending), core.FormatWait(wait)),
+		}, nil
+	}
+
+	card := review.cards[review.current]
+	if !session.shown {
+		session.shown = true
+		session.revealed = false
+		review.shownAt = now
+	}
+	return map[string]interface{}{
+		"session_id": id,
+		"done":       false,
+		"card_id":    card.Key(),
+		"question":   card.Question,
+		"tags":       card.Tags,
+		"state":      stateString(card.FSRSCard.State),
+		"position":   review.current + 1,
+		"queued":     len(review.cards),
+		"reviewed":   len(review.reviews),
+		"message":    "The answer is withheld. Wait for the user's answer, then call srs/reveal_answer.",
+	}, nil
+}
+
+// currentQuizCard returns the card whose question was last given out
+func currentQuizCard(session *quizSession) (*Card, error) {
+	if !session.shown || session.review.current >= len(session.review.cards) {
+		return nil, fmt.Errorf("no card is being reviewed; call srs/next_card first")
+	}
+	return session.review.cards[session.review.current], nil
+}
+
+func handleRevealAnswer(config *Config, args map[string]interface{}) (interface{}, error) {
+	session, id, err := getQuizSession(args)
+	if err != nil {
+		return nil, err
+	}
+	card, err := currentQuizCard(session)
+	if err != nil {
+		return nil, err
+	}
+	session.revealed = true
+
+	// Show what each rating would do, as the review screens do
+	now := time.Now()
+	scheduling := card.Scheduler().Repeat(card.FSRSCard, now)
+	intervals := make(map[string]string)
+	for _, rating := range []fsrs.Rating{fsrs.Again, fsrs.Hard, fsrs.Good, fsrs.Easy} {
+		intervals[core.RatingToString(rating)] = core.FormatWait(scheduling[rating].Card.Due.Sub(now))
+	}
+
+	return map[string]interface{}{
+		"session_id": id,
+		"card_id":    card.Key(),
+		"question":   card.Question,
+		"answer":     card.Answer,
+		"intervals":  intervals,
+		"message":    "Rate how well the user recalled it with srs/rate_current (1=Again, 2=Hard, 3=Good, 4=Easy).",
+	}, nil
+}
+
+func handleRateCurrent(config *Config, args map[string]interface{}) (interface{}, error) {
+	session, id, err := getQuizSession(args)
+	if err != nil {
+		return nil, err
+	}
+	card, err := currentQuizCard(session)
+	if err != nil {
+		return nil, err
+	}
+	if !session.revealed {
+		return nil, fmt.Errorf("reveal the answer with srs/reveal_answer before rating")
+	}
+
+	ratingFloat, ok := args["rating"].(float64)
+	rating := int(ratingFloat)
+	if !ok || rating < 1 || rating > 4 || float64(rating) != ratingFloat {
+		return nil, fmt.Errorf("rating must be an integer between 1-4")
+	}
+
+	review := session.review
+	if err := review.updateCard(card, fsrs.Rating(rating)); err != nil {
+		return nil, fmt.Errorf("error rating card: %v", err)
+	}
+	review.undo = append(review.undo, undoStep{current: review.current, queued: len(review.cards)})
+	review.advance(time.Now())
+	session.shown, session.revealed = false, false
+
+	return map[string]interface{}{
+		"session_id":   id,
+		"card_id":      card.Key(),
+		"rating":       core.RatingToString(fsrs.Rating(rating)),
+		"new_due_date": card.FSRSCard.Due.Format("2006-01-02T15:04:05Z"),
+		"new_state":    stateString(card.FSRSCard.State),
+		"reviewed":     len(review.reviews),
+		"remaining":    len(review.cards) - review.current,
+		"pending":      len(review.pending()),
+		"done":         review.done(),
+	}, nil
+}
+
+func handleEndSession(config *Config, args map[string]interface{}) (interface{}, error) {
+	session, id, err := getQuizSession(args)
+	if err != nil {
+		return nil, err
+	}
+	quizSessions.Lock()
+	delete(quizSessions.byID, id)
+	quizSessions.Unlock()
+
+	review := session.review
+	now := time.Now()
+	record := review.record(now)
+	if err := review.saveRecord(record); err != nil {
+		return nil, fmt.Errorf("error saving session log: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for rating, n := range record.Counts() {
+		counts[core.RatingToString(rating)] = n
+	}
+	result := map[string]interface{}{
+		"session_id": id,
+		"reviewed":   len(record.Reviews),
+		"ratings":    counts,
+		"minutes":    record.End.Sub(record.Start).Minutes(),
+		"complete":   review.done(),
+	}
+	if len(record.Reviews) > 0 {
+		average, _ := record.Timing()
+		result["seconds_per_card"] = average.Seconds()
+	}
+	if retention, total := record.Retention(); total > 0 {
+		result["retention"] = retention
+	}
+	var lapsed []string
+	for _, key := range record.Lapses() {
+		lapsed = append(lapsed, review.cardName(key))
+	}
+	result["lapsed"] = lapsed
+
+	var pending []map[string]interface{}
+	for _, card := range review.pending() {
+		pending = append(pending, map[string]interface{}{
+			"card_id": card.Key(),
+			"due_in":  core.FormatWait(card.FSRSCard.Due.Sub(now)),
+		})
+	}
+	result["pending_learning"] = pending
+	if review.deck != nil {
+		result["due_tomorrow"] = core.DueOn(review.deck, now.AddDate(0, 0, 1))
+	}
+	return result, nil
+}
@@ -1,36 +1,18 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"github.com/open-spaced-repetition/go-fsrs/v3"
-)
-
-// MCP Protocol types
-type MCPRequest struct {
-	ID     *json.RawMessage `json:"id"`
-	Method string           `json:"method"`
-	Params json.RawMessage  `json:"params,omitempty"`
-}
-
-type MCPResponse struct {
-	ID     *json.RawMessage `json:"id"`
-	Result interface{}      `json:"result,omitempty"`
-	Error  *MCPError        `json:"error,omitempty"`
-}
+	"strings"
+	"time"
 
-type MCPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+	"srs/core"
 
-type ToolCallParams struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
-}
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
 
 // Tool implementations
 func handleGetDueCards(config *Config, args map[string]interface{}) (interface{}, error) {
@@ -39,7 +21,7 @@ func handleGetDueCards(config *Config, args map[string]interface{}) (interface{}
 		deckPath = path
 	}
 	
-	resolvedPath, err := resolveDeckPath(deckPath, config)
+	resolvedPath, err := resolveDeckPathWithin(deckPath, config)
 	if err != nil {
 		return nil, fmt.Errorf("error resolving deck path: %v", err)
 	}
@@ -49,7 +31,19 @@ func handleGetDueCards(config *Config, args map[string]interface{}) (interface{}
 		return nil, fmt.Errorf("error loading cards: %v", err)
 	}
 	
-	dueCards := getDueCards(cards)
+	filter := core.TagFilter{Include: stringArgs(args, "tags"), Exclude: stringArgs(args, "exclude_tags")}
+	cards = filter.Apply(cards)
+	
+	settings, err := core.EffectiveSettings(core.FindDeckRoot(resolvedPath), resolvedPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading deck settings: %v", err)
+	}
+	now := time.Now()
+	dueCards := core.OrderQueue(getDueCards(cards), settings.QueueOrder(), now)
+	dueCards, err = core.ApplyDailyLimits(resolvedPath, cards, dueCards, now)
+	if err != nil {
+		return nil, fmt.Errorf("error applying daily limits: %v", err)
+	}
 	
 	result := map[string]interface{}{
 		"deck_path":   deckPath,
@@ -60,7 +54,9 @@ func handleGetDueCards(config *Config, args map[string]interface{}) (interface{}
 	
 	for i, card := range dueCards {
 		result["due_cards"].([]map[string]interface{})[i] = map[string]interface{}{
+			"id":         card.Key(),
 			"file_path":  card.FilePath,
+			"tags":       card.Tags,
 			"question":   card.Question,
 			"answer":     card.Answer,
 			"due":        card.FSRSCard.Due.Format("2006-01-02T15:04:05Z"),
@@ -74,12 +70,19 @@ func handleGetDueCards(config *Config, args map[string]interface{}) (interface{}
 	return result, nil
 }
 
-func handleRateCard(config *Config, args map[string]interface{}) (interface{}, error) {
-	filePath, ok := args["file_path"].(string)
-	if !ok || filePath == "" {
-		return nil, fmt.Errorf("file_path is required")
+// stringArgs reads a tool argument holding a list of strings
+func stringArgs(args map[string]interface{}, key string) []string {
+	var values []string
+	items, _ := args[key].([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			values = append(values, s)
+		}
 	}
-	
+	return values
+}
+
+func handleRateCard(config *Config, args map[string]interface{}) (interface{}, error) {
 	ratingFloat, ok := args["rating"].(float64)
 	if !ok {
 		return nil, fmt.Errorf("rating is required (1-4)")
@@ -90,15 +93,11 @@ func handleRateCard(config *Config, args map[string]interface{}) (interface{}, e
 		return nil, fmt.Errorf("rating must be an integer between 1-4")
 	}
 	
-	// Resolve file path if it's relative
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(config.BaseDeckPath, filePath)
-	}
-	
-	card, err := parseCard(filePath)
+	card, err := lookupCard(config, args)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing card: %v", err)
+		return nil, err
 	}
+	filePath := card.FilePath
 	
 	// Convert rating and update card using existing review logic
 	err = rateCard(card, rating)
@@ -108,6 +107,7 @@ func handleRateCard(config *Config, args map[string]interface{}) (interface{}, e
 	
 	result := map[string]interface{}{
 		"success":      true,
+		"card_id":      card.Key(),
 		"card_path":    filePath,
 		"rating":       fmt.Sprintf("%d", rating),
 		"new_due_date": card.FSRSCard.Due.Format("2006-01-02T15:04:05Z"),
@@ -120,13 +120,166 @@ func handleRateCard(config *Config, args map[string]interface{}) (interface{}, e
 	return result, nil
 }
 
+// lookupCard finds the card named by a tool's card_id or file_path argument
+func lookupCard(config *Config, args map[string]interface{}) (*Card, error) {
+	cardID, _ := args["card_id"].(string)
+	filePath, _ := args["file_path"].(string)
+	if cardID == "" && filePath == "" {
+		return nil, fmt.Errorf("card_id or file_path is required")
+	}
+
+	// IDs stay valid when cards are moved, so prefer them over paths
+	if cardID != "" {
+		return core.FindCardByID(config.BaseDeckPath, cardID)
+	}
+
+	filePath, err := resolveDeckPathWithin(filePath, config)
+	if err != nil {
+		return nil, err
+	}
+	card, err := parseCard(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing card: %v", err)
+	}
+	return card, nil
+}
+
+// resolveDeckPathWithin resolves a deck or card path like resolveDeckPath,
+// but refuses paths outside the base deck, so tools can't touch other files
+func resolveDeckPathWithin(deckPath string, config *Config) (string, error) {
+	resolved, err := resolveDeckPath(deckPath, config)
+	if err != nil {
+		return "", err
+	}
+	base, err := filepath.Abs(config.BaseDeckPath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(base, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("deck path %s is outside the base deck %s", deckPath, base)
+	}
+	if rel == core.DataDirName || strings.HasPrefix(rel, core.DataDirName+string(filepath.Separator)) {
+		return "", fmt.Errorf("deck path %s is inside srs's data directory", deckPath)
+	}
+	return resolved, nil
+}
+
+// cardResult describes a card in a tool result
+func cardResult(config *Config, card *Card) map[string]interface{} {
+	return map[string]interface{}{
+		"card_id":   card.Key(),
+		"file_path": relativeToBase(card.FilePath, config),
+		"question":  card.Question,
+		"answer":    card.Answer,
+		"tags":      card.Tags,
+		"due":       card.FSRSCard.Due.Format("2006-01-02T15:04:05Z"),
+		"state":     stateString(card.FSRSCard.State),
+		"reps":      card.FSRSCard.Reps,
+	}
+}
+
+func handleCreateCard(config *Config, args map[string]interface{}) (interface{}, error) {
+	deckPath := "."
+	if path, ok := args["deck_path"].(string); ok && path != "" {
+		deckPath = path
+	}
+	question, _ := args["question"].(string)
+	answer, _ := args["answer"].(string)
+	if strings.TrimSpace(question) == "" || strings.TrimSpace(answer) == "" {
+		return nil, fmt.Errorf("question and answer are required")
+	}
+	
+	resolvedPath, err := resolveDeckPathWithin(deckPath, config)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Refuse a second card asking the same question anywhere in the deck
+	cards, err := findCards(config.BaseDeckPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cards: %v", err)
+	}
+	if duplicate := core.FindDuplicate(cards, question); duplicate != nil {
+		return nil, fmt.Errorf("card %s (%s) already asks this question", duplicate.Key(), relativeToBase(duplicate.FilePath, config))
+	}
+	
+	card, err := core.CreateCard(resolvedPath, question, answer, stringArgs(args, "tags"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating card: %v", err)
+	}
+	
+	result := cardResult(config, card)
+	result["success"] = true
+	return result, nil
+}
+
+func handleUpdateCard(config *Config, args map[string]interface{}) (interface{}, error) {
+	card, err := lookupCard(config, args)
+	if err != nil {
+		return nil, err
+	}
+	
+	question, answer := card.Question, card.Answer
+	if value, ok := args["question"].(string); ok {
+		question = value
+	}
+	if value, ok := args["answer"].(string); ok {
+		answer = value
+	}
+	
+	// Without a tags argument the card's tag lines are kept
+	var tags []string
+	if _, ok := args["tags"]; ok {
+		tags = append([]string{}, stringArgs(args, "tags")...)
+	}
+	
+	updated, err := card.UpdateContent(question, answer, tags)
+	if err != nil {
+		return nil, fmt.Errorf("error updating card: %v", err)
+	}
+	
+	result := cardResult(config, updated)
+	result["success"] = true
+	return result, nil
+}
+
+func handleDeleteCard(config *Config, args map[string]interface{}) (interface{}, error) {
+	card, err := lookupCard(config, args)
+	if err != nil {
+		return nil, err
+	}
+	
+	root := card.Root
+	if root == "" {
+		root = config.BaseDeckPath
+		if err := core.InitDeckRoot(root); err != nil {
+			return nil, fmt.Errorf("error creating data directory: %v", err)
+		}
+	}
+	
+	trashPath, err := core.TrashCard(card, root)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting card: %v", err)
+	}
+	
+	result := map[string]interface{}{
+		"success":    true,
+		"card_id":    card.Key(),
+		"file_path":  relativeToBase(card.FilePath, config),
+		"trash_path": trashPath,
+	}
+	return result, nil
+}
+
 func handleGetDeckStats(config *Config, args map[string]interface{}) (interface{}, error) {
 	deckPath := "."
 	if path, ok := args["deck_path"].(string); ok && path != "" {
 		deckPath = path
 	}
 	
-	resolvedPath, err := resolveDeckPath(deckPath, config)
+	resolvedPath, err := resolveDeckPathWithin(deckPath, config)
 	if err != nil {
 		return nil, fmt.Errorf("error resolving deck path: %v", err)
 	}
@@ -213,141 +366,284 @@ func rateCard(card *Card, rating int) error {
 }
 
 // Helper functions for FSRS types
-func stateString(state interface{}) string {
-	// This is a simplified state string conversion
-	return fmt.Sprintf("%v", state)
+func stateString(state fsrs.State) string {
+	return StateToString(state)
 }
 
-// Simple MCP server implementation
-func mcpSimpleCommand() error {
-	config, err := loadConfig()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %v", err)
-	}
-	
-	if config.BaseDeckPath == "" {
-		return fmt.Errorf("no base deck path configured. Please run 'srs config' first")
-	}
-	
-	scanner := bufio.NewScanner(os.Stdin)
-	
-	// Send initialization message
-	initResp := MCPResponse{
-		ID: nil,
-		Result: map[string]interface{}{
-			"protocol_version": "1.0",
-			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{
-					"srs/get_due_cards": map[string]interface{}{
-						"description": "Get cards that are due for review",
-						"inputSchema": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"deck_path": map[string]interface{}{
-									"type":        "string",
-									"description": "Path to deck (relative to base deck path, defaults to '.')",
-								},
-							},
-						},
+// mcpTools lists the tools the MCP server offers
+func mcpTools() []mcpTool {
+	return []mcpTool{
+		{
+			Name:        "srs/get_due_cards",
+			Description: "Get cards that are due for review",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"deck_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to deck (relative to base deck path, defaults to '.')",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Only cards carrying all of these tags",
+					},
+					"exclude_tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Skip cards carrying any of these tags",
+					},
+				},
+			},
+			handler: handleGetDueCards,
+		},
+		{
+			Name:        "srs/rate_card",
+			Description: "Rate a card and update its scheduling",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"card_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Stable ID of the card (preferred, survives moves)",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the card file (used if card_id is not given)",
+					},
+					"rating": map[string]interface{}{
+						"type":        "number",
+						"description": "Rating (1=Again, 2=Hard, 3=Good, 4=Easy)",
+					},
+				},
+				"required": []string{"rating"},
+			},
+			handler: handleRateCard,
+		},
+		{
+			Name:        "srs/create_card",
+			Description: "Create a new card in its own file, named after the question. Fails if a card already asks the same question.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"deck_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Subdeck to create the card in (relative to base deck path, defaults to '.')",
 					},
-					"srs/rate_card": map[string]interface{}{
-						"description": "Rate a card and update its scheduling",
-						"inputSchema": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"file_path": map[string]interface{}{
-									"type":        "string",
-									"description": "Path to the card file",
-								},
-								"rating": map[string]interface{}{
-									"type":        "number",
-									"description": "Rating (1=Again, 2=Hard, 3=Good, 4=Easy)",
-								},
-							},
-							"required": []string{"file_path", "rating"},
-						},
+					"question": map[string]interface{}{
+						"type":        "string",
+						"description": "Front of the card (markdown)",
 					},
-					"srs/get_deck_stats": map[string]interface{}{
-						"description": "Get statistics for a deck",
-						"inputSchema": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"deck_path": map[string]interface{}{
-									"type":        "string",
-									"description": "Path to deck (relative to base deck path, defaults to '.')",
-								},
-							},
-						},
+					"answer": map[string]interface{}{
+						"type":        "string",
+						"description": "Back of the card (markdown)",
 					},
-					"srs/list_decks": map[string]interface{}{
-						"description": "List all available decks",
-						"inputSchema": map[string]interface{}{
-							"type":       "object",
-							"properties": map[string]interface{}{},
-						},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Tags for the card",
 					},
 				},
+				"required": []string{"question", "answer"},
 			},
+			handler: handleCreateCard,
 		},
+		{
+			Name:        "srs/update_card",
+			Description: "Change a card's question, answer or tags, keeping its ID and review schedule",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"card_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Stable ID of the card (preferred, survives moves)",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the card file (used if card_id is not given)",
+					},
+					"question": map[string]interface{}{
+						"type":        "string",
+						"description": "New front of the card (unchanged if omitted)",
+					},
+					"answer": map[string]interface{}{
+						"type":        "string",
+						"description": "New back of the card (unchanged if omitted)",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Replaces the card's tags (unchanged if omitted; tags in front matter are kept)",
+					},
+				},
+			},
+			handler: handleUpdateCard,
+		},
+		{
+			Name:        "srs/delete_card",
+			Description: "Delete a card by moving it to the deck's trash folder (.srs/trash), from where it can be restored",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"card_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Stable ID of the card (preferred, survives moves)",
+					},
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the card file (used if card_id is not given)",
+					},
+				},
+			},
+			handler: handleDeleteCard,
+		},
+		{
+			Name:        "srs/get_deck_stats",
+			Description: "Get statistics for a deck",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"deck_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to deck (relative to base deck path, defaults to '.')",
+					},
+				},
+			},
+			handler: handleGetDeckStats,
+		},
+		{
+			Name:        "srs/list_decks",
+			Description: "List all available decks",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			handler: handleListDecks,
+		},
+		{
+			Name:        "srs/start_session",
+			Description: "Start a quiz on the cards due in a deck, applying its daily limits. Returns a session_id; get questions with srs/next_card. Sessions expire after 2 hours idle.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"deck_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to deck (relative to base deck path, defaults to '.')",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Only cards carrying all of these tags",
+					},
+					"exclude_tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Skip cards carrying any of these tags",
+					},
+					"order": map[string]interface{}{
+						"type":        "string",
+						"description": "Review order (path, due, retrievability, difficulty, random or interleaved), overriding the deck's",
+					},
+				},
+			},
+			handler: handleStartSession,
+		},
+		{
+			Name:        "srs/next_card",
+			Description: "Get the question of the session's current card. The answer is withheld until srs/reveal_answer, so ask the user first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID returned by srs/start_session",
+					},
+					"early": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Review the next pending learning card even if it isn't due yet",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+			handler: handleNextCard,
+		},
+		{
+			Name:        "srs/reveal_answer",
+			Description: "Reveal the answer of the card last given by srs/next_card, with the interval each rating would give",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID returned by srs/start_session",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+			handler: handleRevealAnswer,
+		},
+		{
+			Name:        "srs/rate_current",
+			Description: "Rate the session's current card once its answer is revealed, and move on",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID returned by srs/start_session",
+					},
+					"rating": map[string]interface{}{
+						"type":        "number",
+						"description": "Rating (1=Again, 2=Hard, 3=Good, 4=Easy)",
+					},
+				},
+				"required": []string{"session_id", "rating"},
+			},
+			handler: handleRateCurrent,
+		},
+		{
+			Name:        "srs/end_session",
+			Description: "End a quiz session, logging it and returning a summary of the ratings",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID returned by srs/start_session",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+			handler: handleEndSession,
+		},
+	}
+}
+
+// Simple MCP server implementation, speaking JSON-RPC over stdio, or over
+// HTTP with 'srs mcp --http ADDR [--token TOKEN]'
+func mcpSimpleCommand(args []string) error {
+	flags := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	addr := flags.String("http", "", "Serve the Streamable HTTP transport on this address")
+	token := flags.String("token", os.Getenv(mcpTokenEnv), "Bearer token HTTP clients must send")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("usage: srs mcp [--http ADDR] [--token TOKEN]: %v", err)
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", flags.Arg(0))
 	}
 	
-	respBytes, _ := json.Marshal(initResp)
-	fmt.Println(string(respBytes))
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
 	
-	// Process requests
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-		
-		var req MCPRequest
-		if err := json.Unmarshal([]byte(line), &req); err != nil {
-			continue
-		}
-		
-		var resp MCPResponse
-		resp.ID = req.ID
-		
-		if req.Method == "tools/call" {
-			var params ToolCallParams
-			if err := json.Unmarshal(req.Params, &params); err != nil {
-				resp.Error = &MCPError{Code: -32602, Message: "Invalid params"}
-			} else {
-				var result interface{}
-				var err error
-				
-				switch params.Name {
-				case "srs/get_due_cards":
-					result, err = handleGetDueCards(config, params.Arguments)
-				case "srs/rate_card":
-					result, err = handleRateCard(config, params.Arguments)
-				case "srs/get_deck_stats":
-					result, err = handleGetDeckStats(config, params.Arguments)
-				case "srs/list_decks":
-					result, err = handleListDecks(config, params.Arguments)
-				default:
-					err = fmt.Errorf("unknown tool: %s", params.Name)
-				}
-				
-				if err != nil {
-					resp.Error = &MCPError{Code: -32603, Message: err.Error()}
-				} else {
-					resp.Result = map[string]interface{}{
-						"content": []map[string]interface{}{
-							{
-								"type": "text",
-								"text": fmt.Sprintf("%v", result),
-							},
-						},
-					}
-				}
-			}
-		}
-		
-		respBytes, _ := json.Marshal(resp)
-		fmt.Println(string(respBytes))
+	if config.BaseDeckPath == "" {
+		return fmt.Errorf("no base deck path configured. Please run 'srs config' first")
 	}
 	
-	return nil
-}
\ No newline at end of file
+	if *addr != "" {
+		return serveMCPHTTP(config, *addr, *token)
+	}
+	return newMCPServer(config).serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mcpReply decodes a single JSON-RPC response
+func mcpReply(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var reply map[string]interface{}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		t.Fatalf("Invalid response %q: %v", data, err)
+	}
+	if reply["jsonrpc"] != "2.0" {
+		t.Errorf("Expected jsonrpc 2.0, got %v", reply["jsonrpc"])
+	}
+	return reply
+}
+
+func errorCode(reply map[string]interface{}) float64 {
+	rpcErr, _ := reply["error"].(map[string]interface{})
+	code, _ := rpcErr["code"].(float64)
+	return code
+}
+
+func TestMCPServerHandshake(t *testing.T) {
+	dir := createTempDir(t)
+	createTempFile(t, dir, "hola.md", "Hola?\n---\nHello")
+	server := newMCPServer(&Config{BaseDeckPath: dir})
+
+	var out bytes.Buffer
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"two","method":"tools/list"}`,
+	}, "\n")
+	if err := server.serve(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 responses (none for the notification), got %d: %q", len(lines), out.String())
+	}
+
+	initialize := mcpReply(t, []byte(lines[0]))
+	if initialize["id"] != 1.0 {
+		t.Errorf("Expected id 1, got %v", initialize["id"])
+	}
+	result := initialize["result"].(map[string]interface{})
+	if result["protocolVersion"] != "2025-03-26" {
+		t.Errorf("Expected the client's protocol version, got %v", result["protocolVersion"])
+	}
+	if _, ok := result["capabilities"].(map[string]interface{})["tools"]; !ok {
+		t.Error("Expected the tools capability")
+	}
+
+	list := mcpReply(t, []byte(lines[1]))
+	if list["id"] != "two" {
+		t.Errorf("Expected id \"two\", got %v", list["id"])
+	}
+	tools := list["result"].(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != len(mcpTools()) {
+		t.Errorf("Expected %d tools, got %d", len(mcpTools()), len(tools))
+	}
+	for _, tool := range tools {
+		if _, ok := tool.(map[string]interface{})["inputSchema"]; !ok {
+			t.Errorf("Tool %v has no inputSchema", tool)
+		}
+	}
+
+	// Unknown versions get the latest one
+	reply := mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":3,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)))
+	if version := reply["result"].(map[string]interface{})["protocolVersion"]; version != mcpProtocolVersions[0] {
+		t.Errorf("Expected %s, got %v", mcpProtocolVersions[0], version)
+	}
+}
+
+func TestMCPServerToolCall(t *testing.T) {
+	dir := createTempDir(t)
+	createTempFile(t, dir, "hola.md", "Hola?\n---\nHello")
+	server := newMCPServer(&Config{BaseDeckPath: dir})
+
+	reply := mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"srs/get_deck_stats","arguments":{}}}`)))
+	result := reply["result"].(map[string]interface{})
+	if result["isError"] != false {
+		t.Errorf("Expected isError false, got %v", result["isError"])
+	}
+	content := result["content"].([]interface{})[0].(map[string]interface{})
+	var stats map[string]interface{}
+	if err := json.Unmarshal([]byte(content["text"].(string)), &stats); err != nil {
+		t.Fatalf("Expected JSON text content, got %q: %v", content["text"], err)
+	}
+	if stats["total_cards"] != 1.0 {
+		t.Errorf("Expected 1 card, got %v", stats["total_cards"])
+	}
+	if _, ok := result["structuredContent"]; !ok {
+		t.Error("Expected structuredContent for the latest protocol version")
+	}
+
+	// Failures of the tool are results the model can see
+	reply = mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"srs/rate_card","arguments":{"card_id":"MISSING","rating":3}}}`)))
+	result = reply["result"].(map[string]interface{})
+	if result["isError"] != true {
+		t.Errorf("Expected isError true, got %v", result["isError"])
+	}
+
+	reply = mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"srs/nope"}}`)))
+	if code := errorCode(reply); code != rpcInvalidParams {
+		t.Errorf("Expected %d for an unknown tool, got %v", rpcInvalidParams, code)
+	}
+}
+
+func TestMCPServerErrors(t *testing.T) {
+	server := newMCPServer(&Config{BaseDeckPath: createTempDir(t)})
+
+	tests := []struct {
+		name    string
+		message string
+		code    float64
+	}{
+		{"parse error", `{"jsonrpc":"2.0","id":1,`, rpcParseError},
+		{"not an object", `"hello"`, rpcInvalidRequest},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, rpcInvalidRequest},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`, rpcInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"nope"}`, rpcMethodNotFound},
+		{"bad params", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":[]}`, rpcInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := mcpReply(t, server.handleMessage([]byte(tt.message)))
+			if code := errorCode(reply); code != tt.code {
+				t.Errorf("Expected error %v, got %v", tt.code, reply)
+			}
+		})
+	}
+
+	if reply := server.handleMessage([]byte(`{"jsonrpc":"2.0","method":"nope"}`)); reply != nil {
+		t.Errorf("Expected no reply to a notification, got %s", reply)
+	}
+
+	var batch []map[string]interface{}
+	reply := server.handleMessage([]byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`))
+	if err := json.Unmarshal(reply, &batch); err != nil || len(batch) != 1 {
+		t.Errorf("Expected one response in the batch, got %s", reply)
+	}
+}
+
+// callMCPTool calls a tool and returns its decoded JSON result, or its error
+// text if the tool failed
+func callMCPTool(t *testing.T, server *mcpServer, name string, args map[string]interface{}) (map[string]interface{}, string) {
+	t.Helper()
+	request, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": name, "arguments": args},
+	})
+	reply := mcpReply(t, server.handleMessage(request))
+	result, ok := reply["result"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a tool result, got %v", reply)
+	}
+	text := result["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	if result["isError"] == true {
+		return nil, text
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatalf("Expected JSON text content, got %q", text)
+	}
+	return decoded, ""
+}
+
+func TestMCPCardTools(t *testing.T) {
+	dir := createTempDir(t)
+	server := newMCPServer(&Config{BaseDeckPath: dir})
+
+	created, errText := callMCPTool(t, server, "srs/create_card", map[string]interface{}{
+		"deck_path": "go",
+		"question":  "What is a goroutine?",
+		"answer":    "A lightweight thread",
+		"tags":      []string{"go"},
+	})
+	if errText != "" {
+		t.Fatalf("create_card failed: %s", errText)
+	}
+	if created["file_path"] != filepath.Join("go", "what-is-a-goroutine.md") {
+		t.Errorf("Unexpected file path %v", created["file_path"])
+	}
+	id := created["card_id"].(string)
+
+	if _, errText := callMCPTool(t, server, "srs/create_card", map[string]interface{}{
+		"question": "what is a goroutine?", "answer": "Again",
+	}); !strings.Contains(errText, id) {
+		t.Errorf("Expected the duplicate to be refused, got %q", errText)
+	}
+	for _, deck := range []string{"../outside", "/tmp", ".srs"} {
+		if _, errText := callMCPTool(t, server, "srs/create_card", map[string]interface{}{
+			"deck_path": deck, "question": "Escape?", "answer": "No",
+		}); errText == "" {
+			t.Errorf("Expected deck path %s to be refused", deck)
+		}
+		for _, tool := range []string{"srs/get_due_cards", "srs/get_deck_stats"} {
+			if _, errText := callMCPTool(t, server, tool, map[string]interface{}{"deck_path": deck}); errText == "" {
+				t.Errorf("Expected %s to refuse deck path %s", tool, deck)
+			}
+		}
+	}
+
+	// Rate the card so there is a schedule to keep
+	if _, errText := callMCPTool(t, server, "srs/rate_card", map[string]interface{}{"card_id": id, "rating": 3}); errText != "" {
+		t.Fatalf("rate_card failed: %s", errText)
+	}
+	updated, errText := callMCPTool(t, server, "srs/update_card", map[string]interface{}{
+		"card_id": id, "answer": "A function running concurrently, scheduled by the Go runtime",
+	})
+	if errText != "" {
+		t.Fatalf("update_card failed: %s", errText)
+	}
+	if updated["card_id"] != id || updated["reps"] != 1.0 || updated["question"] != "What is a goroutine?" {
+		t.Errorf("Expected the ID, schedule and question to be kept, got %v", updated)
+	}
+
+	deleted, errText := callMCPTool(t, server, "srs/delete_card", map[string]interface{}{"card_id": id})
+	if errText != "" {
+		t.Fatalf("delete_card failed: %s", errText)
+	}
+	if _, err := os.Stat(deleted["trash_path"].(string)); err != nil {
+		t.Errorf("Expected the card in the trash: %v", err)
+	}
+	if _, errText := callMCPTool(t, server, "srs/update_card", map[string]interface{}{"card_id": id, "answer": "x"}); errText == "" {
+		t.Error("Expected the deleted card to be gone from the deck")
+	}
+}
+
+func TestMCPQuizSession(t *testing.T) {
+	dir := createTempDir(t)
+	createTempFile(t, dir, "capital.md", "What is the capital of France?\n---\nParis")
+	server := newMCPServer(&Config{BaseDeckPath: dir})
+
+	started, errText := callMCPTool(t, server, "srs/start_session", map[string]interface{}{})
+	if errText != "" {
+		t.Fatalf("start_session failed: %s", errText)
+	}
+	id := started["session_id"].(string)
+	if started["cards_due"] != 1.0 {
+		t.Errorf("Expected 1 card due, got %v", started["cards_due"])
+	}
+
+	if _, errText := callMCPTool(t, server, "srs/reveal_answer", map[string]interface{}{"session_id": id}); errText == "" {
+		t.Error("Expected the answer to stay hidden before a question was given")
+	}
+	next, errText := callMCPTool(t, server, "srs/next_card", map[string]interface{}{"session_id": id})
+	if errText != "" {
+		t.Fatalf("next_card failed: %s", errText)
+	}
+	if next["question"] != "What is the capital of France?" {
+		t.Errorf("Unexpected question %v", next["question"])
+	}
+	if _, ok := next["answer"]; ok {
+		t.Error("Expected next_card to withhold the answer")
+	}
+	if _, errText := callMCPTool(t, server, "srs/rate_current", map[string]interface{}{"session_id": id, "rating": 3}); errText == "" {
+		t.Error("Expected rating before the reveal to be refused")
+	}
+
+	revealed, errText := callMCPTool(t, server, "srs/reveal_answer", map[string]interface{}{"session_id": id})
+	if errText != "" {
+		t.Fatalf("reveal_answer failed: %s", errText)
+	}
+	if revealed["answer"] != "Paris" {
+		t.Errorf("Unexpected answer %v", revealed["answer"])
+	}
+
+	rated, errText := callMCPTool(t, server, "srs/rate_current", map[string]interface{}{"session_id": id, "rating": 4})
+	if errText != "" {
+		t.Fatalf("rate_current failed: %s", errText)
+	}
+	if rated["done"] != true || rated["reviewed"] != 1.0 {
+		t.Errorf("Expected the session to be done after one rating, got %v", rated)
+	}
+	if next, _ := callMCPTool(t, server, "srs/next_card", map[string]interface{}{"session_id": id}); next["done"] != true {
+		t.Errorf("Expected no more cards, got %v", next)
+	}
+
+	ended, errText := callMCPTool(t, server, "srs/end_session", map[string]interface{}{"session_id": id})
+	if errText != "" {
+		t.Fatalf("end_session failed: %s", errText)
+	}
+	if ended["reviewed"] != 1.0 || ended["ratings"].(map[string]interface{})["Easy"] != 1.0 {
+		t.Errorf("Unexpected summary %v", ended)
+	}
+	if _, errText := callMCPTool(t, server, "srs/next_card", map[string]interface{}{"session_id": id}); errText == "" {
+		t.Error("Expected the ended session to be gone")
+	}
+}
+
+func TestMCPResources(t *testing.T) {
+	dir := createTempDir(t)
+	createTempFile(t, dir, "spanish/verbs/ser.md", "Ser?\n---\nTo be")
+	server := newMCPServer(&Config{BaseDeckPath: dir})
+
+	var notifications []string
+	server.notify = func(method string, params interface{}) {
+		notifications = append(notifications, fmt.Sprint(method, params))
+	}
+	server.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`))
+	server.handleMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+
+	reply := mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)))
+	var uris []string
+	for _, resource := range reply["result"].(map[string]interface{})["resources"].([]interface{}) {
+		uris = append(uris, resource.(map[string]interface{})["uri"].(string))
+	}
+	if len(uris) != 4 || uris[0] != "srs://deck/" || uris[1] != "srs://deck/spanish" || uris[2] != "srs://deck/spanish/verbs" {
+		t.Fatalf("Expected three decks and a card, got %v", uris)
+	}
+	cardURI := uris[3]
+
+	read := func(uri string) map[string]interface{} {
+		request, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "resources/read", "params": map[string]string{"uri": uri}})
+		reply := mcpReply(t, server.handleMessage(request))
+		result, ok := reply["result"].(map[string]interface{})
+		if !ok {
+			return reply
+		}
+		var content map[string]interface{}
+		json.Unmarshal([]byte(result["contents"].([]interface{})[0].(map[string]interface{})["text"].(string)), &content)
+		return content
+	}
+	deck := read("srs://deck/spanish")
+	if deck["total_cards"] != 1.0 || len(deck["subdecks"].([]interface{})) != 1 {
+		t.Errorf("Unexpected deck resource %v", deck)
+	}
+	if card := read(cardURI); card["question"] != "Ser?" || card["deck"] != "srs://deck/spanish/verbs" {
+		t.Errorf("Unexpected card resource %v", card)
+	}
+	for _, uri := range []string{"srs://deck/../..", "srs://card/MISSING", "file:///etc/passwd"} {
+		if code := errorCode(read(uri)); code != rpcResourceNotFound {
+			t.Errorf("Expected %s to be not found, got %v", uri, code)
+		}
+	}
+
+	server.handleMessage([]byte(`{"jsonrpc":"2.0","id":4,"method":"resources/subscribe","params":{"uri":"` + cardURI + `"}}`))
+	id := strings.TrimPrefix(cardURI, "srs://card/")
+	callMCPTool(t, server, "srs/update_card", map[string]interface{}{"card_id": id, "answer": "To be (permanent)"})
+	if len(notifications) != 1 || !strings.Contains(notifications[0], "notifications/resources/updated") || !strings.Contains(notifications[0], cardURI) {
+		t.Errorf("Expected an update notification for the card, got %v", notifications)
+	}
+
+	notifications = nil
+	callMCPTool(t, server, "srs/create_card", map[string]interface{}{"question": "Estar?", "answer": "To be (temporary)"})
+	if len(notifications) != 1 || !strings.Contains(notifications[0], "notifications/resources/list_changed") {
+		t.Errorf("Expected a list changed notification, got %v", notifications)
+	}
+}
+
+func TestMCPPrompts(t *testing.T) {
+	server := newMCPServer(&Config{BaseDeckPath: createTempDir(t)})
+
+	reply := mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)))
+	if prompts := reply["result"].(map[string]interface{})["prompts"].([]interface{}); len(prompts) != len(mcpPrompts) {
+		t.Errorf("Expected %d prompts, got %v", len(mcpPrompts), prompts)
+	}
+
+	reply = mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"create_cards","arguments":{"text":"Goroutines are cheap.","deck_path":"go"}}}`)))
+	messages := reply["result"].(map[string]interface{})["messages"].([]interface{})
+	text := messages[0].(map[string]interface{})["content"].(map[string]interface{})["text"].(string)
+	if !strings.Contains(text, cardGuidelines) || !strings.Contains(text, "Goroutines are cheap.") || !strings.Contains(text, `"go"`) {
+		t.Errorf("Expected the guidelines, text and deck in the prompt, got %q", text)
+	}
+
+	for _, params := range []string{
+		`{"name":"create_cards","arguments":{}}`,
+		`{"name":"quiz","arguments":{"count":"none"}}`,
+		`{"name":"nope"}`,
+	} {
+		reply = mcpReply(t, server.handleMessage([]byte(`{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":`+params+`}`)))
+		if code := errorCode(reply); code != rpcInvalidParams {
+			t.Errorf("Expected invalid params for %s, got %v", params, reply)
+		}
+	}
+}
+
+func TestMCPHTTPTransport(t *testing.T) {
+	dir := createTempDir(t)
+	createTempFile(t, dir, "hola.md", "Hola?\n---\nHello")
+	server := httptest.NewServer(newMCPHTTPHandler(&Config{BaseDeckPath: dir}, "s3cret"))
+	defer server.Close()
+
+	post := func(session, token, body string) *http.Response {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept", "application/json, text/event-stream")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		if session != "" {
+			request.Header.Set(mcpSessionHeader, session)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		t.Cleanup(func() { response.Body.Close() })
+		return response
+	}
+
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`
+	if response := post("", "wrong", initialize); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong token, got %d", response.StatusCode)
+	}
+
+	response := post("", "s3cret", initialize)
+	session := response.Header.Get(mcpSessionHeader)
+	if response.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("Expected a session from initialize, got %d %q", response.StatusCode, session)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if reply := mcpReply(t, body); reply["result"] == nil {
+		t.Errorf("Expected an initialize result, got %s", body)
+	}
+
+	if response := post(session, "s3cret", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); response.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for a notification, got %d", response.StatusCode)
+	}
+	if response := post("", "s3cret", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a session, got %d", response.StatusCode)
+	}
+	if response := post("nope", "s3cret", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %d", response.StatusCode)
+	}
+
+	// The event stream carries the session's notifications
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("Authorization", "Bearer s3cret")
+	request.Header.Set(mcpSessionHeader, session)
+	stream, err := http.DefaultClient.Do(request)
+	if err != nil || stream.StatusCode != http.StatusOK {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer stream.Body.Close()
+
+	response = post(session, "s3cret", `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"srs/create_card","arguments":{"question":"Adios?","answer":"Bye"}}}`)
+	body, _ = io.ReadAll(response.Body)
+	if result, _ := mcpReply(t, body)["result"].(map[string]interface{}); result["isError"] != false {
+		t.Fatalf("Expected create_card to succeed, got %s", body)
+	}
+
+	events := bufio.NewReader(stream.Body)
+	line, err := events.ReadString('\n')
+	for err == nil && !strings.HasPrefix(line, "data: ") {
+		line, err = events.ReadString('\n')
+	}
+	if !strings.Contains(line, "notifications/resources/list_changed") {
+		t.Errorf("Expected a list changed event, got %q (%v)", line, err)
+	}
+
+	request, _ = http.NewRequest(http.MethodDelete, server.URL, nil)
+	request.Header.Set("Authorization", "Bearer s3cret")
+	request.Header.Set(mcpSessionHeader, session)
+	if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the session to be deleted: %v", err)
+	}
+	if response := post(session, "s3cret", `{"jsonrpc":"2.0","id":4,"method":"ping"}`); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 after deleting the session, got %d", response.StatusCode)
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.5:8080":  false,
+		"deckbox:8080":   false,
+	} {
+		if got := loopbackAddr(addr); got != want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
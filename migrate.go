@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"srs/core"
+)
+
+// migrateFormatCommand rewrites the metadata of every card in a deck in the
+// given format, or the configured one. A new format is saved to the config
+// so later reviews keep writing it.
+func migrateFormatCommand(deckPath, format string, config *Config) error {
+	if format == "" {
+		format = config.MetadataFormat
+	}
+	if format == "" {
+		format = string(core.FormatComment)
+	}
+
+	metadataFormat, err := core.ParseMetadataFormat(format)
+	if err != nil {
+		return err
+	}
+
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+
+	migrated, err := core.MigrateFormat(cards, metadataFormat)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d cards in %s to %s metadata\n", migrated, deckPath, metadataFormat)
+
+	if config.MetadataFormat != format {
+		config.MetadataFormat = format
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf("failed to save metadata format: %v", err)
+		}
+		configPath, _ := getConfigPath()
+		fmt.Printf("Saved metadata_format=%s to %s\n", format, configPath)
+	}
+
+	return nil
+}
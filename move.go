@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"srs/core"
+)
+
+func moveCommand(args []string, config *Config) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: srs mv CARD... DEST")
+	}
+
+	sources, dest := args[:len(args)-1], args[len(args)-1]
+
+	destPath, err := resolveDeckPath(dest, config)
+	if err != nil {
+		return fmt.Errorf("invalid destination %s: %v", dest, err)
+	}
+
+	// Treat the destination as a subdeck unless it names a single new card file
+	destIsDeck := len(sources) > 1 || strings.HasSuffix(dest, "/") ||
+		!strings.HasSuffix(strings.ToLower(dest), ".md")
+	if info, err := os.Stat(destPath); err == nil && info.IsDir() {
+		destIsDeck = true
+	}
+	if len(sources) > 1 && !destIsDeck {
+		return fmt.Errorf("destination %s must be a subdeck when moving several cards", dest)
+	}
+
+	for _, source := range sources {
+		sourcePath, err := resolveDeckPath(source, config)
+		if err != nil {
+			return fmt.Errorf("invalid card path %s: %v", source, err)
+		}
+
+		info, err := os.Stat(sourcePath)
+		if err != nil {
+			return fmt.Errorf("card %s does not exist", source)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a subdeck; card IDs live in the card files, so whole directories can be moved with plain mv", source)
+		}
+
+		card, err := parseCard(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to load card %s: %v", source, err)
+		}
+
+		target := destPath
+		if destIsDeck {
+			target = filepath.Join(destPath, filepath.Base(sourcePath))
+		}
+
+		if err := core.MoveCard(card, target); err != nil {
+			return fmt.Errorf("failed to move %s: %v", source, err)
+		}
+
+		fmt.Printf("Moved %s → %s (id %s)\n", relativeToBase(sourcePath, config), relativeToBase(target, config), card.ID)
+	}
+
+	return nil
+}
+
+// relativeToBase shortens a path to be relative to the base deck for display
+func relativeToBase(path string, config *Config) string {
+	if rel, err := filepath.Rel(config.BaseDeckPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
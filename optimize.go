@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"srs/core"
+)
+
+func optimizeCommand(deckPath string, config *Config) error {
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+
+	root := core.FindDeckRoot(deckPath)
+	if root == "" {
+		return fmt.Errorf("no review history found for %s", deckPath)
+	}
+
+	history, err := core.LoadHistory(root)
+	if err != nil {
+		return fmt.Errorf("failed to load review history: %v", err)
+	}
+
+	sequences := core.TrainingSequences(cardHistories(cards, history))
+	fmt.Printf("Optimizing FSRS weights on the history of %d cards...\n", len(sequences))
+
+	result, err := core.Optimize(sequences, core.DefaultParameters().W)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nReviews evaluated: %d\n\n", result.Reviews)
+	fmt.Printf("          %-10s %-10s\n", "Log-loss", "RMSE")
+	fmt.Printf("Before    %-10.4f %-10.4f\n", result.InitialLogLoss, result.InitialRMSE)
+	fmt.Printf("After     %-10.4f %-10.4f\n\n", result.LogLoss, result.RMSE)
+
+	values := make([]string, len(result.Weights))
+	for i, w := range result.Weights {
+		values[i] = fmt.Sprintf("%.4f", w)
+	}
+	fmt.Printf("Weights: %s\n", strings.Join(values, ", "))
+
+	config.Weights = result.Weights[:]
+	if err := saveConfig(config); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+
+	configPath, _ := getConfigPath()
+	fmt.Printf("✅ Saved tuned weights to %s\n", configPath)
+	return nil
+}
+
+// cardHistories picks out the journal records of the given cards, so only the
+// requested deck is trained on, merging any kept under a card's old path key
+func cardHistories(cards []*Card, history map[string][]core.ReviewRecord) map[string][]core.ReviewRecord {
+	histories := make(map[string][]core.ReviewRecord)
+	for _, card := range cards {
+		if records := card.History(history); len(records) > 0 {
+			histories[card.Key()] = records
+		}
+	}
+	return histories
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"srs/core"
+)
+
+func TestCardHistoriesMergesPathKeys(t *testing.T) {
+	root := createTempDir(t)
+	card := &Card{ID: "01ABC", FilePath: filepath.Join(root, "vocab.md"), Root: root}
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	// The first review was journaled under the card's path, before it had an ID
+	history := map[string][]core.ReviewRecord{
+		"vocab.md": {{CardID: "vocab.md", Time: start, Rating: 3, State: "New"}},
+		"01ABC": {
+			{CardID: "01ABC", Time: start.AddDate(0, 0, 3), Rating: 3, State: "Review", ElapsedDays: 3},
+			{CardID: "01ABC", Time: start.AddDate(0, 0, 10), Rating: 1, State: "Review", ElapsedDays: 7},
+		},
+		"other.md": {{CardID: "other.md", Time: start, Rating: 3, State: "New"}},
+	}
+
+	sequences := core.TrainingSequences(cardHistories([]*Card{card}, history))
+	if len(sequences) != 1 || len(sequences[0]) != 3 {
+		t.Fatalf("Expected one sequence of 3 reviews, got %v", sequences)
+	}
+	if sequences[0][0].CardID != "vocab.md" || sequences[0][2].Rating != 1 {
+		t.Errorf("Expected the reviews in time order, got %v", sequences[0])
+	}
+}
@@ -10,22 +10,91 @@ import (
 	"strings"
 	"time"
 
+	"srs/core"
+
 	"github.com/open-spaced-repetition/go-fsrs/v3"
 )
 
 type ReviewSession struct {
-	scheduler *fsrs.FSRS
-	cards     []*Card
-	current   int
+	cards      []*Card
+	current    int
+	learnAhead time.Duration // how early learning cards are shown once the queue runs out
+	undo       []undoStep    // ratings made this session, most recent last
+	grader     core.Grader   // suggests ratings for typed answers, if set
+
+	deckPath string               // reviewed deck, for the session log
+	deck     []*Card              // every card in the deck, for the forecast
+	started  time.Time            // when the session started
+	shownAt  time.Time            // when the current card was shown
+	reviews  []core.SessionReview // ratings made this session, with timings
+}
+
+// undoStep records where the session was before a rating
+type undoStep struct {
+	current int // index of the rated card
+	queued  int // queue length before cards that became due were requeued
 }
 
 func NewReviewSession(cards []*Card) *ReviewSession {
-	params := fsrs.DefaultParam()
 	return &ReviewSession{
-		scheduler: fsrs.NewFSRS(params),
-		cards:     cards,
-		current:   0,
+		cards:      cards,
+		current:    0,
+		learnAhead: core.DefaultLearnAhead,
+	}
+}
+
+// advance moves past the rated card, requeueing cards that have become due
+// and, once the queue runs out, learning cards within the learn-ahead window
+func (rs *ReviewSession) advance(now time.Time) {
+	rs.cards = core.Requeue(rs.cards, rs.current+1, now)
+	rs.current++
+	rs.learnAheadCard(now, false)
+}
+
+// learnAheadCard queues the next pending learning card once the session has
+// run out of cards, if it's due within the learn-ahead window or early is
+// set. Reports whether there is a card to review.
+func (rs *ReviewSession) learnAheadCard(now time.Time, early bool) bool {
+	if rs.current < len(rs.cards) {
+		return true
+	}
+
+	pending := rs.pending()
+	if len(pending) == 0 || !early && pending[0].FSRSCard.Due.After(now.Add(rs.learnAhead)) {
+		return false
+	}
+	rs.cards = append(rs.cards, pending[0])
+	return true
+}
+
+// pending returns the learning cards rated in this session that aren't due
+// yet, soonest first
+func (rs *ReviewSession) pending() []*Card {
+	return core.PendingLearning(rs.cards, rs.current)
+}
+
+// done reports whether every card, learning steps included, has been reviewed
+func (rs *ReviewSession) done() bool {
+	return rs.current >= len(rs.cards) && len(rs.pending()) == 0
+}
+
+// pendingSummary describes the learning cards a session leaves behind
+func pendingSummary(pending []*Card, now time.Time) string {
+	if len(pending) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	if len(pending) == 1 {
+		b.WriteString("1 learning card is still pending:\n")
+	} else {
+		fmt.Fprintf(&b, "%d learning cards are still pending:\n", len(pending))
 	}
+	for _, card := range pending {
+		fmt.Fprintf(&b, "  %s, due in %s\n", card.Name(), core.FormatWait(card.FSRSCard.Due.Sub(now)))
+	}
+	b.WriteString("Run 'srs review' again when they're due.\n")
+	return b.String()
 }
 
 func (rs *ReviewSession) reviewCard(card *Card) error {
@@ -62,6 +131,9 @@ func (rs *ReviewSession) reviewCard(card *Card) error {
 	// If user typed an answer, show it for comparison
 	if userAnswer != "" {
 		fmt.Printf("--- Your answer ---\n%s\n\n", userAnswer)
+		if suggestion := rs.suggestRating(card, userAnswer); suggestion != "" {
+			fmt.Printf("%s\n\n", suggestion)
+		}
 	}
 	
 	for {
@@ -88,7 +160,7 @@ func (rs *ReviewSession) reviewCard(card *Card) error {
 				fmt.Printf("Error editing card: %v\n", err)
 			} else {
 				// Reload the card after editing
-				updatedCard, err := parseCard(card.FilePath)
+				updatedCard, err := reloadCard(card)
 				if err != nil {
 					fmt.Printf("Error reloading card: %v\n", err)
 				} else {
@@ -107,17 +179,92 @@ func (rs *ReviewSession) reviewCard(card *Card) error {
 	}
 }
 
+// suggestRating grades a typed answer with the session's grader, describing
+// the suggested rating, or why grading failed. It's empty without a grader.
+func (rs *ReviewSession) suggestRating(card *Card, userAnswer string) string {
+	if rs.grader == nil || strings.TrimSpace(userAnswer) == "" {
+		return ""
+	}
+	grade, err := rs.grader.Grade(card.Question, card.Expected(), userAnswer)
+	if err != nil {
+		return fmt.Sprintf("Couldn't grade the answer: %v", err)
+	}
+	return describeGrade(grade)
+}
+
+// describeGrade formats a suggested rating, e.g. "Suggested rating: 3 (Good) -
+// 92% similar to the answer"
+func describeGrade(grade core.Grade) string {
+	suggestion := fmt.Sprintf("Suggested rating: %d (%s)", int(grade.Rating), core.RatingToString(grade.Rating))
+	if grade.Explanation != "" {
+		suggestion += " - " + grade.Explanation
+	}
+	return suggestion
+}
+
 func (rs *ReviewSession) updateCard(card *Card, rating fsrs.Rating) error {
 	now := time.Now()
 	
-	schedulingCards := rs.scheduler.Repeat(card.FSRSCard, now)
+	schedulingCards := card.Scheduler().Repeat(card.FSRSCard, now)
 	
 	selectedInfo := schedulingCards[rating]
-	card.FSRSCard = selectedInfo.Card
 	
-	card.ReviewLog = append(card.ReviewLog, selectedInfo.ReviewLog)
-	
-	return card.updateFSRSMetadata()
+	if err := card.ApplyReview(selectedInfo); err != nil {
+		return err
+	}
+
+	record := core.NewReviewRecord(card.Key(), selectedInfo.ReviewLog)
+	rs.reviews = append(rs.reviews, core.NewSessionReview(record, now.Sub(rs.shownAt)))
+	return nil
+}
+
+// undoLast reverts the session's most recent rating, putting the card back
+// at the front of the queue
+func (rs *ReviewSession) undoLast() (*Card, error) {
+	if len(rs.undo) == 0 {
+		return nil, fmt.Errorf("nothing to undo in this session")
+	}
+	step := rs.undo[len(rs.undo)-1]
+
+	card := rs.cards[step.current]
+	if card.Root == "" {
+		return nil, fmt.Errorf("no review history for %s", card.FilePath)
+	}
+	restored, err := core.UndoLastReview(card.Root, card.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	rs.undo = rs.undo[:len(rs.undo)-1]
+	rs.cards = rs.cards[:step.queued]
+	rs.cards[step.current] = restored
+	rs.current = step.current
+	if len(rs.reviews) > 0 {
+		rs.reviews = rs.reviews[:len(rs.reviews)-1]
+	}
+	for i, card := range rs.deck {
+		if card.Key() == restored.Key() {
+			rs.deck[i] = restored
+		}
+	}
+	return restored, nil
+}
+
+// undoCommand reverts the most recent rating in the deck holding deckPath,
+// for turn-based reviews
+func undoCommand(deckPath string) error {
+	root := core.FindDeckRoot(deckPath)
+	if root == "" {
+		return fmt.Errorf("no review history found for %s", deckPath)
+	}
+
+	card, err := core.UndoLastReview(root, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Undid the last rating of %s (back to %s).\n", card.Name(), StateToString(card.FSRSCard.State))
+	return nil
 }
 
 func (rs *ReviewSession) Start() error {
@@ -162,37 +309,14 @@ func (rs *ReviewSession) StartTurnBased(rating string) error {
 		fmt.Printf("Card rated as %s.\n", 
 			map[int]string{1: "Again", 2: "Hard", 3: "Good", 4: "Easy"}[ratingInt])
 		
-		// Check all cards in the session to see if any have become due
-		// and add them to the end of the queue if they're not already in the remaining cards
-		now := time.Now()
-		remainingCards := rs.cards[rs.current+1:] // Cards we haven't reviewed yet
-		
-		for i := 0; i <= rs.current; i++ { // Check all cards we've seen so far
-			card := rs.cards[i]
-			if card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now) {
-				// Check if this card is already in the remaining queue
-				alreadyQueued := false
-				for _, remainingCard := range remainingCards {
-					if remainingCard.FilePath == card.FilePath {
-						alreadyQueued = true
-						break
-					}
-				}
-				
-				// If not already queued, add it to the end
-				if !alreadyQueued {
-					rs.cards = append(rs.cards, card)
-				}
-			}
-		}
-		
-		// Move to next card
-		rs.current++
+		// Move to next card, requeueing any that have become due
+		rs.advance(time.Now())
 	}
 	
 	// Show the next due card
 	if rs.current >= len(rs.cards) {
 		fmt.Println("No more cards due for review!")
+		fmt.Print(pendingSummary(rs.pending(), time.Now()))
 		return nil
 	}
 	
@@ -275,7 +399,7 @@ func getDueCards(cards []*Card) []*Card {
 	var dueCards []*Card
 	
 	for _, card := range cards {
-		if card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now) {
+		if (card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now)) && !card.Suspended() {
 			dueCards = append(dueCards, card)
 		}
 	}
@@ -309,6 +433,14 @@ func editCard(card *Card) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	// Cards piped in on stdin leave it at EOF, so give the editor the terminal
+	if !stdinIsTerminal() {
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			cmd.Stdin = tty
+		}
+	}
+
 	// Run the editor
 	return cmd.Run()
 }
\ No newline at end of file
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"srs/core"
+)
+
+// searchCommand lists the cards in a deck matching a query, as a table or
+// JSON. The first column of the table is the card ID, so the output can be
+// piped into 'srs review --stdin'.
+func searchCommand(deckPath, query string, filter core.TagFilter, asJSON bool) error {
+	q, err := core.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid query: %v", err)
+	}
+
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+
+	now := time.Now()
+	matched := core.Search(filter.Apply(cards), q, now)
+
+	if asJSON {
+		results := make([]map[string]interface{}, len(matched))
+		for i, card := range matched {
+			results[i] = map[string]interface{}{
+				"id":         card.Key(),
+				"name":       searchName(deckPath, card),
+				"file_path":  card.FilePath,
+				"tags":       card.Tags,
+				"question":   card.Question,
+				"answer":     card.Answer,
+				"state":      StateToString(card.FSRSCard.State),
+				"due":        card.FSRSCard.Due.Format(time.RFC3339),
+				"reps":       card.FSRSCard.Reps,
+				"lapses":     card.FSRSCard.Lapses,
+				"stability":  card.FSRSCard.Stability,
+				"difficulty": card.FSRSCard.Difficulty,
+			}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	if len(matched) == 0 {
+		fmt.Fprintf(os.Stderr, "No cards match %q in %s\n", query, deckPath)
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tCARD\tSTATE\tDUE\tREPS\tLAPSES\tSTABILITY\tDIFFICULTY")
+	for _, card := range matched {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%d\t%.2f\t%.2f\n",
+			card.Key(),
+			searchName(deckPath, card),
+			StateToString(card.FSRSCard.State),
+			card.FSRSCard.Due.Local().Format("2006-01-02 15:04"),
+			card.FSRSCard.Reps,
+			card.FSRSCard.Lapses,
+			card.FSRSCard.Stability,
+			card.FSRSCard.Difficulty)
+	}
+	return writer.Flush()
+}
+
+// searchName is the card's name qualified by its subdeck
+func searchName(deckPath string, card *Card) string {
+	rel, err := filepath.Rel(deckPath, filepath.Dir(card.FilePath))
+	if err != nil || rel == "." {
+		return card.Name()
+	}
+	return filepath.ToSlash(filepath.Join(rel, card.Name()))
+}
+
+// readCardList reads card IDs or file paths, one per line, taking the first
+// field of each line so 'srs search' tables can be piped in as they are
+func readCardList(r io.Reader) ([]string, error) {
+	ids := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			ids = append(ids, fields[0])
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// selectCards returns the cards named in ids by ID or file path, in the order
+// they were listed
+func selectCards(cards []*Card, ids []string) []*Card {
+	byKey := make(map[string][]*Card)
+	for _, card := range cards {
+		byKey[card.Key()] = append(byKey[card.Key()], card)
+		if abs, err := filepath.Abs(card.FilePath); err == nil {
+			byKey[abs] = append(byKey[abs], card)
+		}
+	}
+
+	var selected []*Card
+	seen := make(map[*Card]bool)
+	for _, id := range ids {
+		key := id
+		if _, ok := byKey[id]; !ok {
+			if _, err := os.Stat(id); err == nil {
+				if abs, err := filepath.Abs(id); err == nil {
+					key = abs
+				}
+			}
+		}
+		for _, card := range byKey[key] {
+			if !seen[card] {
+				seen[card] = true
+				selected = append(selected, card)
+			}
+		}
+	}
+	return selected
+}
+
+// stdinIsTerminal reports whether standard input is an interactive terminal
+// rather than a pipe or file
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
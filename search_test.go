@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srs/core"
+)
+
+func TestSelectCardsByPathKey(t *testing.T) {
+	deck := createTempDir(t)
+	if err := os.MkdirAll(filepath.Join(deck, core.DataDirName), 0755); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	createTempFile(t, deck, "spanish/ser.md", "# ser\n\n---\n\nto be\n")
+	createTempFile(t, deck, "spanish/estar.md", "# estar\n\n---\n\nto be (state)\n")
+
+	cards, err := core.FindCards(deck)
+	if err != nil {
+		t.Fatalf("Failed to find cards: %v", err)
+	}
+
+	// Run from outside the deck so the key can't resolve as a file path
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(createTempDir(t)); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	ids, err := readCardList(strings.NewReader("spanish/ser.md  spanish/ser  New  0\n"))
+	if err != nil {
+		t.Fatalf("Failed to read card list: %v", err)
+	}
+	selected := selectCards(cards, ids)
+	if len(selected) != 1 || selected[0].Name() != "ser" {
+		t.Fatalf("Expected the ser card, got %d cards", len(selected))
+	}
+}
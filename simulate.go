@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"srs/core"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// simulateOptions is a what-if scenario given on the command line. Unset
+// fields keep the deck's current settings.
+type simulateOptions struct {
+	retention        float64 // 0 when not given
+	newPerDay        int
+	newPerDaySet     bool
+	reviewsPerDay    int
+	reviewsPerDaySet bool
+	days             int
+	seed             int64
+}
+
+// parseSimulateArgs parses 'srs simulate [DECK] [--retention R]
+// [--new-per-day N] [--reviews-per-day N] [--days D] [--seed S]', with flags
+// before or after the deck
+func parseSimulateArgs(args []string) (string, simulateOptions, error) {
+	options := simulateOptions{days: 365, seed: 1}
+
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Float64Var(&options.retention, "retention", 0, "Desired retention to simulate")
+	flags.IntVar(&options.newPerDay, "new-per-day", 0, "New cards per day to simulate")
+	flags.IntVar(&options.reviewsPerDay, "reviews-per-day", 0, "Review limit per day to simulate")
+	flags.IntVar(&options.days, "days", options.days, "Days to simulate")
+	flags.Int64Var(&options.seed, "seed", options.seed, "Seed for simulated recall")
+
+	deck := "."
+	for {
+		if err := flags.Parse(args); err != nil {
+			return "", options, fmt.Errorf("usage: srs simulate [DECK] [--retention R] [--new-per-day N] [--reviews-per-day N] [--days D]: %v", err)
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		if deck != "." {
+			return "", options, fmt.Errorf("unexpected argument %q", args[0])
+		}
+		deck, args = args[0], args[1:]
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "new-per-day":
+			options.newPerDaySet = true
+		case "reviews-per-day":
+			options.reviewsPerDaySet = true
+		}
+	})
+
+	if options.retention != 0 && (options.retention <= 0 || options.retention >= 1) {
+		return "", options, fmt.Errorf("--retention must be between 0 and 1, got %v", options.retention)
+	}
+	if options.newPerDaySet && options.newPerDay < 0 {
+		return "", options, fmt.Errorf("--new-per-day can't be negative, got %d", options.newPerDay)
+	}
+	if options.reviewsPerDaySet && options.reviewsPerDay < 0 {
+		return "", options, fmt.Errorf("--reviews-per-day can't be negative, got %d", options.reviewsPerDay)
+	}
+	if options.days < 1 {
+		return "", options, fmt.Errorf("--days must be at least 1, got %d", options.days)
+	}
+	return deck, options, nil
+}
+
+// simulateCommand projects the workload of a deck under its current settings
+// and under the given scenario, side by side
+func simulateCommand(deckPath string, options simulateOptions) error {
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+	if len(cards) == 0 {
+		fmt.Println("No cards found in this deck.")
+		return nil
+	}
+
+	root := core.FindDeckRoot(deckPath)
+	settings, err := core.EffectiveSettings(root, deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load deck settings: %v", err)
+	}
+	// The limits of parent decks cap this one too
+	newPerDay, reviewsPerDay, err := core.DailyLimits(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load deck settings: %v", err)
+	}
+
+	var sessions []core.SessionRecord
+	if root != "" {
+		if sessions, err = core.LoadSessions(root); err != nil {
+			return fmt.Errorf("failed to load sessions: %v", err)
+		}
+	}
+	reviewSeconds, newSeconds := core.StudyTimes(sessions)
+
+	current := core.SimulationConfig{
+		Days:          options.days,
+		NewPerDay:     newPerDay,
+		ReviewsPerDay: reviewsPerDay,
+		ReviewSeconds: reviewSeconds,
+		NewSeconds:    newSeconds,
+		Seed:          options.seed,
+	}
+	scenario := current
+	scenario.Retention = options.retention
+	if options.newPerDaySet {
+		scenario.NewPerDay = options.newPerDay
+	}
+	if options.reviewsPerDaySet {
+		scenario.ReviewsPerDay = options.reviewsPerDay
+	}
+
+	now := time.Now()
+	before := core.Simulate(cards, current, now)
+	after := core.Simulate(cards, scenario, now)
+
+	unseen := 0
+	for _, card := range cards {
+		if card.FSRSCard.State == fsrs.New {
+			unseen++
+		}
+	}
+	fmt.Printf("Simulating %d days of %s: %d cards, %d not studied yet\n", options.days, filepath.Base(deckPath), len(cards), unseen)
+	source := "defaults"
+	if len(sessions) > 0 {
+		source = "your sessions"
+	}
+	fmt.Printf("Time per card (from %s): %.0fs per review, %.0fs per new card\n\n", source, reviewSeconds, newSeconds)
+
+	retention := settings.Parameters().RequestRetention
+	scenarioRetention := retention
+	if options.retention != 0 {
+		scenarioRetention = options.retention
+	}
+
+	days := float64(options.days)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "\tCURRENT\tSIMULATED")
+	fmt.Fprintf(writer, "Desired retention\t%.2f\t%.2f\n", retention, scenarioRetention)
+	fmt.Fprintf(writer, "New cards per day\t%s\t%s\n", limitString(current.NewPerDay), limitString(scenario.NewPerDay))
+	fmt.Fprintf(writer, "Review limit per day\t%s\t%s\n", limitString(current.ReviewsPerDay), limitString(scenario.ReviewsPerDay))
+	fmt.Fprintf(writer, "Reviews per day\t%.1f avg\t%.1f avg\n", float64(before.TotalReviews())/days, float64(after.TotalReviews())/days)
+	fmt.Fprintf(writer, "Busiest day\t%d\t%d\n", before.PeakReviews(), after.PeakReviews())
+	fmt.Fprintf(writer, "Total reviews\t%d\t%d\n", before.TotalReviews(), after.TotalReviews())
+	fmt.Fprintf(writer, "Study time per day\t%s\t%s\n",
+		formatDuration(secondsDuration(before.TotalSeconds()/days)), formatDuration(secondsDuration(after.TotalSeconds()/days)))
+	fmt.Fprintf(writer, "Total study time\t%s\t%s\n",
+		formatDuration(secondsDuration(before.TotalSeconds())), formatDuration(secondsDuration(after.TotalSeconds())))
+	fmt.Fprintf(writer, "Cards recalled at the end\t%.0f\t%.0f\n", before.Memorized, after.Memorized)
+	fmt.Fprintf(writer, "New cards left at the end\t%d\t%d\n", before.NewLeft, after.NewLeft)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	// Break the projection down by period, so the shape of the workload shows
+	period := 30
+	if options.days <= 60 {
+		period = 7
+	}
+	fmt.Printf("\nAverage reviews per day\n")
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "DAYS\tCURRENT\tSIMULATED\t")
+	for start := 0; start < options.days; start += period {
+		end := min(start+period, options.days)
+		fmt.Fprintf(writer, "%d-%d\t%.1f\t%.1f\t\n", start+1, end,
+			periodAverage(before.Reviews[start:end]), periodAverage(after.Reviews[start:end]))
+	}
+	return writer.Flush()
+}
+
+// limitString formats a daily limit, negative for none
+func limitString(limit int) string {
+	if limit < 0 {
+		return "no limit"
+	}
+	return fmt.Sprint(limit)
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func periodAverage(counts []int) float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return float64(total) / float64(len(counts))
+}
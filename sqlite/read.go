@@ -0,0 +1,531 @@
+// Package sqlite reads and writes SQLite database files, as far as srs needs
+// for Anki collections: whole tables of rows, without SQL.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// header is the magic string every SQLite database file starts with
+const header = "SQLite format 3\x00"
+
+// Page types of table b-trees
+const (
+	tableInterior = 0x05
+	tableLeaf     = 0x0d
+)
+
+// DB is a database file read into memory
+type DB struct {
+	data     []byte
+	pageSize int
+	usable   int // page size less the bytes reserved at the end of each page
+	tables   map[string]*table
+}
+
+// table is a table listed in the schema
+type table struct {
+	root    int
+	columns []string
+	rowid   int // index of the INTEGER PRIMARY KEY column aliasing the rowid, or -1
+}
+
+// Row is a table row by column name. Values are int64, float64, string,
+// []byte or nil.
+type Row map[string]interface{}
+
+// Open reads the database file at path
+func Open(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Read(data)
+}
+
+// Read parses a database file held in memory
+func Read(data []byte) (*DB, error) {
+	if len(data) < 100 || string(data[:16]) != header {
+		return nil, fmt.Errorf("not an SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding > 1 {
+		return nil, fmt.Errorf("unsupported text encoding %d: only UTF-8 databases can be read", encoding)
+	}
+
+	db := &DB{
+		data:     data,
+		pageSize: pageSize,
+		usable:   pageSize - int(data[20]),
+		tables:   make(map[string]*table),
+	}
+
+	// The schema is a table on page 1: type, name, tbl_name, rootpage, sql
+	schema, err := db.scan(1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	for _, values := range schema {
+		if len(values) < 5 || text(values[0]) != "table" {
+			continue
+		}
+		root, ok := values[3].(int64)
+		if !ok {
+			continue
+		}
+		columns, rowid := parseColumns(text(values[4]))
+		db.tables[strings.ToLower(text(values[1]))] = &table{root: int(root), columns: columns, rowid: rowid}
+	}
+	return db, nil
+}
+
+// HasTable reports whether the database has a table
+func (db *DB) HasTable(name string) bool {
+	return db.tables[strings.ToLower(name)] != nil
+}
+
+// Rows returns every row of a table in rowid order
+func (db *DB) Rows(name string) ([]Row, error) {
+	t := db.tables[strings.ToLower(name)]
+	if t == nil {
+		return nil, fmt.Errorf("no table %s", name)
+	}
+
+	records, err := db.scan(t.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table %s: %v", name, err)
+	}
+
+	rows := make([]Row, len(records))
+	for i, values := range records {
+		rowid := values[len(values)-1]
+		values = values[:len(values)-1]
+		row := make(Row, len(t.columns))
+		for j, column := range t.columns {
+			if j < len(values) {
+				row[column] = values[j]
+			} else {
+				row[column] = nil // added by ALTER TABLE after the row was written
+			}
+		}
+		if t.rowid >= 0 {
+			row[t.columns[t.rowid]] = rowid
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// scan reads every record in the table b-tree rooted at a page. Each record's
+// values are followed by its rowid.
+func (db *DB) scan(root int) ([][]interface{}, error) {
+	var records [][]interface{}
+	visited := make(map[int]bool)
+
+	var walk func(page int) error
+	walk = func(page int) error {
+		if visited[page] {
+			return fmt.Errorf("page %d is linked twice", page)
+		}
+		visited[page] = true
+
+		data, offset, err := db.page(page)
+		if err != nil {
+			return err
+		}
+		kind := data[offset]
+		cells := int(binary.BigEndian.Uint16(data[offset+3:]))
+
+		switch kind {
+		case tableInterior:
+			pointers := offset + 12
+			for i := 0; i < cells; i++ {
+				cell := int(binary.BigEndian.Uint16(data[pointers+2*i:]))
+				if cell+4 > len(data) {
+					return fmt.Errorf("page %d: cell out of range", page)
+				}
+				if err := walk(int(binary.BigEndian.Uint32(data[cell:]))); err != nil {
+					return err
+				}
+			}
+			return walk(int(binary.BigEndian.Uint32(data[offset+8:])))
+
+		case tableLeaf:
+			pointers := offset + 8
+			for i := 0; i < cells; i++ {
+				cell := int(binary.BigEndian.Uint16(data[pointers+2*i:]))
+				values, err := db.leafCell(data, cell)
+				if err != nil {
+					return fmt.Errorf("page %d: %v", page, err)
+				}
+				records = append(records, values)
+			}
+			return nil
+		}
+		return fmt.Errorf("page %d is not a table page (type %d)", page, kind)
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// page returns a page and where its b-tree header starts: after the file
+// header on page 1
+func (db *DB) page(number int) ([]byte, int, error) {
+	start := (number - 1) * db.pageSize
+	if number < 1 || start+db.pageSize > len(db.data) {
+		return nil, 0, fmt.Errorf("page %d out of range", number)
+	}
+	offset := 0
+	if number == 1 {
+		offset = 100
+	}
+	return db.data[start : start+db.pageSize], offset, nil
+}
+
+// leafCell decodes a table leaf cell: payload size, rowid and the record,
+// which may continue on overflow pages
+func (db *DB) leafCell(page []byte, cell int) ([]interface{}, error) {
+	if cell >= len(page) {
+		return nil, fmt.Errorf("cell out of range")
+	}
+	size, n := varint(page[cell:])
+	cell += n
+	rowid, n := varint(page[cell:])
+	cell += n
+
+	payload, err := db.payload(page, cell, int(size))
+	if err != nil {
+		return nil, err
+	}
+	values, err := record(payload)
+	if err != nil {
+		return nil, err
+	}
+	return append(values, int64(rowid)), nil
+}
+
+// payload gathers a cell's payload of the given size starting at offset,
+// following overflow pages for what doesn't fit on the page
+func (db *DB) payload(page []byte, offset, size int) ([]byte, error) {
+	local := localSize(size, db.usable)
+	if offset+local > len(page) {
+		return nil, fmt.Errorf("payload out of range")
+	}
+	if local == size {
+		return page[offset : offset+size], nil
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, page[offset:offset+local]...)
+	if offset+local+4 > len(page) {
+		return nil, fmt.Errorf("overflow pointer out of range")
+	}
+	next := int(binary.BigEndian.Uint32(page[offset+local:]))
+	for len(payload) < size {
+		if next == 0 {
+			return nil, fmt.Errorf("overflow chain ends early")
+		}
+		data, _, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := min(size-len(payload), db.usable-4)
+		payload = append(payload, data[4:4+chunk]...)
+		next = int(binary.BigEndian.Uint32(data))
+	}
+	return payload, nil
+}
+
+// localSize is how much of a table leaf payload of the given size is stored
+// on a page with usable bytes
+func localSize(size, usable int) int {
+	maxLocal := usable - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	return local
+}
+
+// record decodes a record: a header of serial types followed by the values
+func record(data []byte) ([]interface{}, error) {
+	headerSize, n := varint(data)
+	if int(headerSize) > len(data) || n == 0 {
+		return nil, fmt.Errorf("corrupt record header")
+	}
+
+	var values []interface{}
+	body := int(headerSize)
+	for pos := n; pos < int(headerSize); {
+		serial, n := varint(data[pos:int(headerSize)])
+		if n == 0 {
+			return nil, fmt.Errorf("corrupt record header")
+		}
+		pos += n
+
+		size := serialSize(serial)
+		if body+size > len(data) {
+			return nil, fmt.Errorf("record value out of range")
+		}
+		values = append(values, decodeValue(serial, data[body:body+size]))
+		body += size
+	}
+	return values, nil
+}
+
+// serialSize is the length of a value of the given serial type
+func serialSize(serial uint64) int {
+	switch {
+	case serial >= 12:
+		return int(serial-12) / 2
+	case serial == 7:
+		return 8
+	case serial == 5:
+		return 6
+	case serial == 6:
+		return 8
+	case serial >= 1 && serial <= 4:
+		return int(serial)
+	}
+	return 0
+}
+
+func decodeValue(serial uint64, data []byte) interface{} {
+	switch {
+	case serial == 0:
+		return nil
+	case serial <= 6:
+		// Big-endian two's complement integers of 1-8 bytes
+		var v int64
+		if data[0]&0x80 != 0 {
+			v = -1
+		}
+		for _, b := range data {
+			v = v<<8 | int64(b)
+		}
+		return v
+	case serial == 7:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	case serial == 8:
+		return int64(0)
+	case serial == 9:
+		return int64(1)
+	case serial >= 12 && serial%2 == 0:
+		return bytes.Clone(data)
+	case serial >= 13:
+		return string(data)
+	}
+	return nil
+}
+
+// varint decodes a SQLite varint, returning it and its length in bytes, or
+// a length of 0 if data ends too soon
+func varint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(data) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(data[i]), 9
+		}
+		v = v<<7 | uint64(data[i]&0x7f)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+// parseColumns reads the column names from a CREATE TABLE statement, and
+// which one, if any, is an INTEGER PRIMARY KEY standing in for the rowid
+func parseColumns(sql string) ([]string, int) {
+	sql = stripComments(sql)
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, -1
+	}
+
+	var columns []string
+	rowid := -1
+	for _, definition := range splitTopLevel(sql[start+1 : end]) {
+		name, rest := columnName(definition)
+		if name == "" {
+			continue
+		}
+		switch strings.ToUpper(name) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+
+		fields := strings.Fields(strings.ToLower(rest))
+		lower := strings.Join(fields, " ")
+		if len(fields) > 0 && fields[0] == "integer" && strings.Contains(lower, "primary key") &&
+			!strings.Contains(lower, "primary key desc") {
+			rowid = len(columns)
+		}
+		columns = append(columns, unquote(name))
+	}
+	return columns, rowid
+}
+
+// stripComments removes -- and /* */ comments outside quoted strings
+func stripComments(sql string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			b.WriteByte(' ')
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// splitTopLevel splits a column list on the commas outside parentheses and
+// quotes
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, list[start:])
+}
+
+// columnName splits a column definition into its name, quotes included, and
+// the rest
+func columnName(definition string) (string, string) {
+	definition = strings.TrimSpace(definition)
+	if definition == "" {
+		return "", ""
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']'}[definition[0]]
+	if closing != 0 {
+		if end := strings.IndexByte(definition[1:], closing); end >= 0 {
+			return definition[:end+2], definition[end+2:]
+		}
+	}
+	if end := strings.IndexFunc(definition, unicode.IsSpace); end >= 0 {
+		return definition[:end], definition[end:]
+	}
+	return definition, ""
+}
+
+func unquote(name string) string {
+	if len(name) >= 2 {
+		switch {
+		case name[0] == '"' && name[len(name)-1] == '"',
+			name[0] == '`' && name[len(name)-1] == '`',
+			name[0] == '[' && name[len(name)-1] == ']':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// Int returns a column as an integer, converting reals and numeric text
+func (r Row) Int(column string) int64 {
+	switch v := r[column].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n
+	}
+	return 0
+}
+
+// Text returns a column as text, formatting numbers
+func (r Row) Text(column string) string {
+	switch v := r[column].(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return text(r[column])
+}
+
+// Tables lists the database's tables, sorted
+func (db *DB) Tables() []string {
+	names := make([]string, 0, len(db.tables))
+	for name := range db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRows(t *testing.T) {
+	db, err := Open("testdata/sample.db")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if got, want := db.Tables(), []string{"items", "odd names"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tables() = %v, want %v", got, want)
+	}
+	if db.HasTable("missing") {
+		t.Error("Expected no table named missing")
+	}
+	if _, err := db.Rows("missing"); err == nil {
+		t.Error("Expected reading a missing table to fail")
+	}
+
+	rows, err := db.Rows("items")
+	if err != nil {
+		t.Fatalf("Rows failed: %v", err)
+	}
+	if len(rows) != 301 {
+		t.Fatalf("Expected 301 rows across the table's pages, got %d", len(rows))
+	}
+
+	var sum int64
+	for i, row := range rows[:300] {
+		if row.Int("id") != int64(i+1) {
+			t.Fatalf("Row %d has id %d, want rowid order", i, row.Int("id"))
+		}
+		sum += row.Int("amount")
+		if row["note"] != nil {
+			t.Errorf("Row %d has note %v from before the column was added", i, row["note"])
+		}
+	}
+	if sum != 150000 {
+		t.Errorf("Amounts sum to %d, want 150000 with negative values", sum)
+	}
+
+	first := rows[0]
+	if first.Text("name") != "item 1" || first["price"] != 0.25 || !bytes.Equal(first["data"].([]byte), []byte{0x00, 0xff}) {
+		t.Errorf("Unexpected first row: %v", first)
+	}
+	if name := rows[6].Text("name"); name != strings.Repeat("x", 2000) {
+		t.Errorf("Expected the 2000 byte name of an overflowing row, got %d bytes", len(name))
+	}
+	if last := rows[300]; last.Int("id") != 1000 || last.Text("note") != "added" || last["amount"] != nil {
+		t.Errorf("Unexpected last row: %v", last)
+	}
+
+	odd, err := db.Rows("odd names")
+	if err != nil {
+		t.Fatalf("Rows failed: %v", err)
+	}
+	if len(odd) != 1 || odd[0].Text("first col") != "a" || odd[0].Int("second") != 1 {
+		t.Errorf("Unexpected rows with quoted names: %v", odd)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read([]byte("not a database")); err == nil {
+		t.Error("Expected reading a non-database to fail")
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		sql     string
+		columns []string
+		rowid   int
+	}{
+		{"CREATE TABLE t (id integer primary key, name text)", []string{"id", "name"}, 0},
+		{"CREATE TABLE t (a text, id INTEGER NOT NULL PRIMARY KEY)", []string{"a", "id"}, 1},
+		{"CREATE TABLE t (id int primary key, b)", []string{"id", "b"}, -1},
+		{"CREATE TABLE t (a, b, PRIMARY KEY (a, b))", []string{"a", "b"}, -1},
+		{"CREATE TABLE t (a /* x, y */, `b c` decimal(10, 2))", []string{"a", "b c"}, -1},
+	}
+
+	for _, tt := range tests {
+		columns, rowid := parseColumns(tt.sql)
+		if !reflect.DeepEqual(columns, tt.columns) || rowid != tt.rowid {
+			t.Errorf("parseColumns(%q) = %v, %d, want %v, %d", tt.sql, columns, rowid, tt.columns, tt.rowid)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+-- The database the reader tests read. Build sample.db with:
+--
+--   sqlite3 sample.db < sample.sql
+--
+-- Small pages make the tables span interior pages and overflow pages.
+
+PRAGMA page_size = 512;
+
+CREATE TABLE items (
+    id      integer primary key,   /* rowid alias */
+    name    text not null,         -- a "quoted" comment, with commas
+    amount  integer,
+    price   real,
+    data    blob
+);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 300)
+INSERT INTO items (id, name, amount, price, data)
+SELECT i, 'item ' || i, i * 1000 - 150000, i / 4.0, x'00ff' FROM n;
+UPDATE items SET name = printf('%.2000c', 'x') WHERE id = 7;
+ALTER TABLE items ADD COLUMN note text;
+INSERT INTO items (id, name, amount, note) VALUES (1000, 'last', NULL, 'added');
+
+CREATE TABLE "odd names" ("first col" text, [second] integer);
+INSERT INTO "odd names" VALUES ('a', 1);
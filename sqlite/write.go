@@ -0,0 +1,368 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// writePageSize is the page size of written databases
+const writePageSize = 4096
+
+// Table is a table to write: its CREATE TABLE statement and its rows, with
+// values in column order. Values are int, int64, float64, string, []byte or
+// nil. An INTEGER PRIMARY KEY column gives each row's rowid; otherwise rows
+// are numbered in order.
+type Table struct {
+	Name string
+	SQL  string
+	Rows [][]interface{}
+}
+
+// cell is a b-tree cell with the rowid it is keyed on
+type cell struct {
+	rowid int64
+	data  []byte
+}
+
+// writer lays out the pages of a new database
+type writer struct {
+	pages [][]byte
+}
+
+// Write builds a database file holding tables. Indexes aren't written; SQLite
+// and the programs reading the file work without them.
+func Write(tables []Table) ([]byte, error) {
+	w := &writer{pages: [][]byte{make([]byte, writePageSize)}} // page 1 is the schema
+
+	var schema []cell
+	for i, t := range tables {
+		columns, rowidColumn := parseColumns(t.SQL)
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("table %s: can't read columns from %q", t.Name, t.SQL)
+		}
+
+		cells := make([]cell, len(t.Rows))
+		for j, row := range t.Rows {
+			if len(row) != len(columns) {
+				return nil, fmt.Errorf("table %s: row %d has %d values for %d columns", t.Name, j+1, len(row), len(columns))
+			}
+			rowid := int64(j + 1)
+			values := row
+			if rowidColumn >= 0 {
+				id, ok := integer(row[rowidColumn])
+				if !ok {
+					return nil, fmt.Errorf("table %s: row %d has a non-integer %s", t.Name, j+1, columns[rowidColumn])
+				}
+				// The rowid alias is stored as NULL in the record
+				rowid = id
+				values = append([]interface{}{}, row...)
+				values[rowidColumn] = nil
+			}
+			data, err := encodeRecord(values)
+			if err != nil {
+				return nil, fmt.Errorf("table %s: row %d: %v", t.Name, j+1, err)
+			}
+			cells[j] = cell{rowid: rowid, data: data}
+		}
+		sort.SliceStable(cells, func(a, b int) bool { return cells[a].rowid < cells[b].rowid })
+		for j := 1; j < len(cells); j++ {
+			if cells[j].rowid == cells[j-1].rowid {
+				return nil, fmt.Errorf("table %s: duplicate rowid %d", t.Name, cells[j].rowid)
+			}
+		}
+
+		root := w.tree(cells, false)
+		data, err := encodeRecord([]interface{}{"table", t.Name, t.Name, int64(root), t.SQL})
+		if err != nil {
+			return nil, err
+		}
+		schema = append(schema, cell{rowid: int64(i + 1), data: data})
+	}
+	w.tree(schema, true)
+
+	// The file header, in the first 100 bytes of page 1
+	first := w.pages[0]
+	copy(first, header)
+	binary.BigEndian.PutUint16(first[16:], writePageSize)
+	first[18], first[19] = 1, 1 // legacy journal mode
+	first[21], first[22], first[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(first[24:], 1) // change counter
+	binary.BigEndian.PutUint32(first[28:], uint32(len(w.pages)))
+	binary.BigEndian.PutUint32(first[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(first[44:], 4) // schema format
+	binary.BigEndian.PutUint32(first[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(first[92:], 1) // version-valid-for, matching the change counter
+	binary.BigEndian.PutUint32(first[96:], 3040001)
+
+	data := make([]byte, 0, len(w.pages)*writePageSize)
+	for _, page := range w.pages {
+		data = append(data, page...)
+	}
+	return data, nil
+}
+
+// allocate adds an empty page, returning its number
+func (w *writer) allocate() int {
+	w.pages = append(w.pages, make([]byte, writePageSize))
+	return len(w.pages)
+}
+
+// tree writes a table b-tree of cells sorted by rowid, returning its root
+// page. The schema's tree has its root on page 1, after the file header.
+func (w *writer) tree(cells []cell, schema bool) int {
+	capacity := writePageSize
+	if schema {
+		capacity -= 100 // every level, so the root is sure to fit on page 1
+	}
+
+	leaves := make([][]byte, len(cells))
+	for i, c := range cells {
+		leaves[i] = w.leafCell(c)
+	}
+	nodes := pack(leaves, capacity, 8)
+	level := make([]child, len(nodes))
+	for i, n := range nodes {
+		level[i] = child{cells: leaves[n.first : n.last+1]}
+		if n.last >= 0 {
+			level[i].rowid = cells[n.last].rowid
+		}
+	}
+	kind := byte(tableLeaf)
+
+	for {
+		if len(level) == 1 {
+			page := 1
+			if !schema {
+				page = w.allocate()
+			}
+			w.writeNode(page, kind, level[0].cells, level[0].right)
+			return page
+		}
+
+		// Write this level's pages and group them under parents
+		pages := make([]int, len(level))
+		for i, node := range level {
+			pages[i] = w.allocate()
+			w.writeNode(pages[i], kind, node.cells, node.right)
+		}
+		interior := make([][]byte, len(level))
+		for i, node := range level {
+			interior[i] = binary.BigEndian.AppendUint32(nil, uint32(pages[i]))
+			interior[i] = appendVarint(interior[i], uint64(node.rowid))
+		}
+
+		var parents []child
+		for first := 0; first < len(level); {
+			// Each child but the right-most gets a cell keyed on its largest
+			// rowid
+			used, right := 12, first
+			for right+1 < len(level) && used+len(interior[right])+2 <= capacity {
+				used += len(interior[right]) + 2
+				right++
+			}
+			// Don't leave a lone child to a parent of its own
+			if len(level)-(right+1) == 1 && right > first+1 {
+				right--
+			}
+			parents = append(parents, child{rowid: level[right].rowid, cells: interior[first:right], right: pages[right]})
+			first = right + 1
+		}
+		level = parents
+		kind = tableInterior
+	}
+}
+
+// child is a b-tree page being written: its cells, the rowid it covers up
+// to and, for interior pages, its right-most child page
+type child struct {
+	rowid int64
+	cells [][]byte
+	right int
+}
+
+// span is a run of cells, first to last inclusive, that fits on a page
+type span struct {
+	first, last int
+}
+
+// pack groups cells into runs that fit on pages of the given capacity with a
+// page header of headerSize bytes
+func pack(cells [][]byte, capacity, headerSize int) []span {
+	var spans []span
+	used := headerSize
+	start := 0
+	for i, c := range cells {
+		size := len(c) + 2 // with its cell pointer
+		if used+size > capacity && i > start {
+			spans = append(spans, span{start, i - 1})
+			start, used = i, headerSize
+		}
+		used += size
+	}
+	if start < len(cells) || len(spans) == 0 {
+		spans = append(spans, span{start, len(cells) - 1})
+	}
+	return spans
+}
+
+// writeNode writes cells to a page, packing their content at its end
+func (w *writer) writeNode(page int, kind byte, cells [][]byte, right int) {
+	data := w.pages[page-1]
+	offset := 0
+	if page == 1 {
+		offset = 100
+	}
+	headerSize := 8
+	if kind == tableInterior {
+		headerSize = 12
+		binary.BigEndian.PutUint32(data[offset+8:], uint32(right))
+	}
+
+	content := len(data)
+	for i, c := range cells {
+		content -= len(c)
+		copy(data[content:], c)
+		binary.BigEndian.PutUint16(data[offset+headerSize+2*i:], uint16(content))
+	}
+	data[offset] = kind
+	binary.BigEndian.PutUint16(data[offset+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(data[offset+5:], uint16(content))
+}
+
+// leafCell encodes a table leaf cell, moving what doesn't fit on the page to
+// overflow pages
+func (w *writer) leafCell(c cell) []byte {
+	out := appendVarint(nil, uint64(len(c.data)))
+	out = appendVarint(out, uint64(c.rowid))
+
+	local := localSize(len(c.data), writePageSize)
+	if local == len(c.data) {
+		return append(out, c.data...)
+	}
+	out = append(out, c.data[:local]...)
+
+	// Chain the rest through overflow pages, each starting with the next's
+	// number
+	rest := c.data[local:]
+	next := w.allocate()
+	out = binary.BigEndian.AppendUint32(out, uint32(next))
+	for len(rest) > 0 {
+		page := w.pages[next-1]
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			next = w.allocate()
+			binary.BigEndian.PutUint32(page, uint32(next))
+		}
+	}
+	return out
+}
+
+// integer returns an integer value as int64
+func integer(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// encodeRecord encodes values as a record: a header of serial types followed
+// by the values
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var types, body []byte
+	for _, v := range values {
+		if n, ok := integer(v); ok {
+			v = n
+		}
+		switch v := v.(type) {
+		case nil:
+			types = appendVarint(types, 0)
+		case int64:
+			switch {
+			case v == 0:
+				types = appendVarint(types, 8)
+			case v == 1:
+				types = appendVarint(types, 9)
+			default:
+				serial, size := integerSerial(v)
+				types = appendVarint(types, serial)
+				for i := size - 1; i >= 0; i-- {
+					body = append(body, byte(v>>(8*i)))
+				}
+			}
+		case float64:
+			types = appendVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = appendVarint(types, uint64(13+2*len(v)))
+			body = append(body, v...)
+		case []byte:
+			types = appendVarint(types, uint64(12+2*len(v)))
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("unsupported value %T", v)
+		}
+	}
+
+	// The header size counts itself
+	size := len(types) + 1
+	for len(appendVarint(nil, uint64(size)))+len(types) != size {
+		size++
+	}
+	record := appendVarint(nil, uint64(size))
+	record = append(record, types...)
+	return append(record, body...), nil
+}
+
+// integerSerial picks the smallest integer serial type holding v, returning
+// it with its size in bytes
+func integerSerial(v int64) (uint64, int) {
+	switch {
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// appendVarint appends v as a SQLite varint: big-endian groups of 7 bits,
+// with all 8 bits of a ninth byte
+func appendVarint(out []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var b [9]byte
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(out, b[:]...)
+	}
+
+	var b [8]byte
+	n := 0
+	for {
+		b[7-n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := 8 - n; i < 7; i++ {
+		b[i] |= 0x80
+	}
+	return append(out, b[8-n:]...)
+}
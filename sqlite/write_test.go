@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	// Enough rows for interior pages, some of them overflowing
+	var rows [][]interface{}
+	for i := 0; i < 5000; i++ {
+		name := "item"
+		if i%1000 == 3 {
+			name = strings.Repeat("x", 6000+i)
+		}
+		rows = append(rows, []interface{}{int64(5000 - i), name, float64(i) / 2, []byte{byte(i)}, nil, int64(-i) << 40})
+	}
+
+	data, err := Write([]Table{
+		{Name: "items", SQL: "CREATE TABLE items (id integer primary key, name text, price real, data blob, note, big integer)", Rows: rows},
+		{Name: "empty", SQL: "CREATE TABLE empty (a, b)"},
+		{Name: "plain", SQL: "CREATE TABLE plain (a text, b integer)", Rows: [][]interface{}{{"x", 1}, {"y", 0}}},
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	db, err := Read(data)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	read, err := db.Rows("items")
+	if err != nil || len(read) != len(rows) {
+		t.Fatalf("Expected %d rows back, got %d: %v", len(rows), len(read), err)
+	}
+	for i, row := range read {
+		// Rows come back in rowid order, the reverse of how they were given
+		want := rows[len(rows)-1-i]
+		if row.Int("id") != want[0] || row.Text("name") != want[1] || row["price"] != want[2] ||
+			row.Text("data") != string(want[3].([]byte)) || row["note"] != nil || row.Int("big") != want[5] {
+			t.Fatalf("Row %d = %v, want %v", i, row, want)
+		}
+	}
+
+	if empty, err := db.Rows("empty"); err != nil || len(empty) != 0 {
+		t.Errorf("Expected an empty table, got %v: %v", empty, err)
+	}
+	plain, err := db.Rows("plain")
+	if err != nil || len(plain) != 2 || plain[0].Text("a") != "x" || plain[1].Int("b") != 0 {
+		t.Errorf("Unexpected rows without a rowid column: %v: %v", plain, err)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	tests := []Table{
+		{Name: "t", SQL: "CREATE TABLE t (id integer primary key, a)", Rows: [][]interface{}{{1, "x"}, {1, "y"}}},
+		{Name: "t", SQL: "CREATE TABLE t (id integer primary key, a)", Rows: [][]interface{}{{"one", "x"}}},
+		{Name: "t", SQL: "CREATE TABLE t (a, b)", Rows: [][]interface{}{{1}}},
+		{Name: "t", SQL: "CREATE TABLE t (a)", Rows: [][]interface{}{{true}}},
+	}
+	for _, table := range tests {
+		if _, err := Write([]Table{table}); err == nil {
+			t.Errorf("Expected writing %v to fail", table.Rows)
+		}
+	}
+}
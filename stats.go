@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"srs/core"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	statsTitleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("62"))
+
+	statsDimStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+
+	statsBarStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("42"))
+
+	// heatmapColors shade days from no reviews to the busiest
+	heatmapColors = []lipgloss.Color{"237", "22", "28", "34", "46"}
+)
+
+const (
+	forecastHeight = 6  // rows in the forecast chart
+	barWidth       = 30 // widest bar in a distribution
+)
+
+// statsCommand prints analytics for a deck, for the terminal or as JSON
+func statsCommand(deckPath string, filter core.TagFilter, asJSON bool) error {
+	cards, err := findCards(deckPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cards: %v", err)
+	}
+
+	var sessions []core.SessionRecord
+	if root := core.FindDeckRoot(deckPath); root != "" {
+		sessions, err = core.LoadSessions(root)
+		if err != nil {
+			return fmt.Errorf("failed to load sessions: %v", err)
+		}
+	}
+
+	now := time.Now()
+	stats := core.ComputeStats(filter.Apply(cards), sessions, now)
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	if stats.Cards == 0 {
+		fmt.Println("No cards found in this deck.")
+		return nil
+	}
+
+	title := fmt.Sprintf("Stats for %s", filepath.Base(deckPath))
+	if !filter.IsEmpty() {
+		title += fmt.Sprintf(" (tags: %s)", filter)
+	}
+	sections := []string{
+		lipgloss.NewStyle().Bold(true).Render(title),
+		renderOverview(stats),
+		renderRetention(stats),
+		renderForecast(stats),
+		renderHeatmap(stats),
+		renderDistribution("Stability", stats.Stability),
+		renderDistribution("Difficulty", stats.Difficulty),
+	}
+	if len(stats.MostLapsed) > 0 {
+		sections = append(sections, renderMostLapsed(stats))
+	}
+	fmt.Println(strings.Join(sections, "\n\n"))
+	return nil
+}
+
+func renderOverview(stats core.Stats) string {
+	var states []string
+	for _, state := range []string{"New", "Learning", "Review", "Relearning"} {
+		states = append(states, fmt.Sprintf("%s %d", state, stats.States[state]))
+	}
+	return statsTitleStyle.Render("Cards") + "\n" +
+		fmt.Sprintf("  %d cards: %s", stats.Cards, strings.Join(states, " · "))
+}
+
+func renderRetention(stats core.Stats) string {
+	lines := []string{statsTitleStyle.Render("True retention")}
+	for _, window := range stats.Retention {
+		label := fmt.Sprintf("  %-10s", fmt.Sprintf("%d days", window.Days))
+		if window.Reviews == 0 {
+			lines = append(lines, label+statsDimStyle.Render("no reviews of cards in review"))
+			continue
+		}
+		line := fmt.Sprintf("%s%5.1f%%  of %d reviews", label, window.Retention*100, window.Reviews)
+		if window.StudySeconds > 0 {
+			line += statsDimStyle.Render(fmt.Sprintf("  (%s studied)", formatDuration(time.Duration(window.StudySeconds*float64(time.Second)))))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderForecast draws the due forecast as a vertical bar chart, one column
+// per day
+func renderForecast(stats core.Stats) string {
+	peak := 0
+	week, total := 0, 0
+	for day, count := range stats.Forecast {
+		peak = max(peak, count)
+		total += count
+		if day < 7 {
+			week += count
+		}
+	}
+
+	lines := []string{statsTitleStyle.Render(fmt.Sprintf("Due in the next %d days", len(stats.Forecast)))}
+	if peak == 0 {
+		return lines[0] + "\n" + statsDimStyle.Render("  nothing due")
+	}
+
+	// Each row covers forecastHeight-th of the peak, drawn in eighths
+	blocks := []rune(" ▁▂▃▄▅▆▇█")
+	axis := len(fmt.Sprint(peak))
+	for row := forecastHeight; row >= 1; row-- {
+		var bar strings.Builder
+		for _, count := range stats.Forecast {
+			eighths := int(math.Round(float64(count*forecastHeight*8)/float64(peak))) - (row-1)*8
+			bar.WriteRune(blocks[min(max(eighths, 0), 8)])
+		}
+		label := strings.Repeat(" ", axis)
+		if row == forecastHeight {
+			label = fmt.Sprintf("%*d", axis, peak)
+		}
+		lines = append(lines, "  "+statsDimStyle.Render(label)+" "+statsBarStyle.Render(bar.String()))
+	}
+
+	days := len(stats.Forecast)
+	end := fmt.Sprintf("+%dd", days-1)
+	axisLabels := "today" + strings.Repeat(" ", max(days-len("today")-len(end), 1)) + end
+	lines = append(lines, "  "+strings.Repeat(" ", axis)+" "+statsDimStyle.Render(axisLabels))
+	lines = append(lines, fmt.Sprintf("  %d due today (with overdue), %d this week, %d in %d days", stats.Forecast[0], week, total, days))
+	return strings.Join(lines, "\n")
+}
+
+// renderHeatmap draws the review history as a calendar, one column per week
+// and one row per weekday
+func renderHeatmap(stats core.Stats) string {
+	if len(stats.Heatmap) == 0 {
+		return ""
+	}
+
+	first, err := time.ParseInLocation("2006-01-02", stats.Heatmap[0].Date, time.Local)
+	if err != nil {
+		return ""
+	}
+	offset := int(first.Weekday())
+	weeks := (offset + len(stats.Heatmap) + 6) / 7
+
+	peak, total, active := 0, 0, 0
+	for _, day := range stats.Heatmap {
+		peak = max(peak, day.Reviews)
+		total += day.Reviews
+		if day.Reviews > 0 {
+			active++
+		}
+	}
+
+	// Month names go above the week their first day falls in
+	months := []rune(strings.Repeat(" ", weeks+3))
+	for i := range stats.Heatmap {
+		date := first.AddDate(0, 0, i)
+		if date.Day() != 1 {
+			continue
+		}
+		week := (offset + i) / 7
+		if week == 0 || months[week-1] == ' ' {
+			copy(months[week:], []rune(date.Format("Jan")))
+		}
+	}
+
+	grid := make([][]string, 7)
+	for row := range grid {
+		grid[row] = make([]string, weeks)
+		for week := range grid[row] {
+			grid[row][week] = " "
+		}
+	}
+	for i, day := range stats.Heatmap {
+		level := 0
+		if day.Reviews > 0 {
+			level = 1 + (day.Reviews-1)*(len(heatmapColors)-1)/peak
+		}
+		cell := lipgloss.NewStyle().Foreground(heatmapColors[min(level, len(heatmapColors)-1)]).Render("■")
+		grid[(offset+i)%7][(offset+i)/7] = cell
+	}
+
+	lines := []string{
+		statsTitleStyle.Render("Reviews in the past year"),
+		"      " + statsDimStyle.Render(strings.TrimRight(string(months), " ")),
+	}
+	weekdays := []string{"", "Mon", "", "Wed", "", "Fri", ""}
+	for row, cells := range grid {
+		lines = append(lines, "  "+statsDimStyle.Render(fmt.Sprintf("%-3s", weekdays[row]))+" "+strings.Join(cells, ""))
+	}
+	lines = append(lines, fmt.Sprintf("  %d reviews on %d days, %d-day streak", total, active, streak(stats.Heatmap)))
+	return strings.Join(lines, "\n")
+}
+
+// streak counts the consecutive days with reviews up to today, or up to
+// yesterday if there are none yet today
+func streak(days []core.DayCount) int {
+	i := len(days) - 1
+	if i >= 0 && days[i].Reviews == 0 {
+		i--
+	}
+	count := 0
+	for ; i >= 0 && days[i].Reviews > 0; i-- {
+		count++
+	}
+	return count
+}
+
+// renderDistribution draws a distribution as horizontal bars
+func renderDistribution(title string, buckets []core.Bucket) string {
+	peak, labelWidth := 0, 0
+	for _, bucket := range buckets {
+		peak = max(peak, bucket.Count)
+		labelWidth = max(labelWidth, len(bucket.Label))
+	}
+
+	lines := []string{statsTitleStyle.Render(title)}
+	if peak == 0 {
+		return lines[0] + "\n" + statsDimStyle.Render("  no reviewed cards")
+	}
+	for _, bucket := range buckets {
+		width := (bucket.Count*barWidth + peak - 1) / peak
+		lines = append(lines, fmt.Sprintf("  %-*s %s %d", labelWidth, bucket.Label,
+			statsBarStyle.Render(strings.Repeat("█", width)), bucket.Count))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderMostLapsed(stats core.Stats) string {
+	lines := []string{statsTitleStyle.Render("Most lapsed")}
+	for _, card := range stats.MostLapsed {
+		lines = append(lines, fmt.Sprintf("  %3d  %s %s", card.Lapses, card.Name, statsDimStyle.Render(card.ID)))
+	}
+	return strings.Join(lines, "\n")
+}
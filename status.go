@@ -6,6 +6,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"srs/core"
 )
 
 type DeckNode struct {
@@ -17,13 +19,7 @@ type DeckNode struct {
 	Parent   *DeckNode
 }
 
-func buildDeckTree(deckPath string) (*DeckNode, error) {
-	// Get all cards in the deck
-	cards, err := findCards(deckPath)
-	if err != nil {
-		return nil, err
-	}
-
+func buildDeckTree(deckPath string, cards []*Card) (*DeckNode, error) {
 	// Create root node
 	root := &DeckNode{
 		Name:     filepath.Base(deckPath),
@@ -107,9 +103,13 @@ func sortNode(node *DeckNode) {
 		return node.Children[i].Name < node.Children[j].Name
 	})
 	
-	// Sort cards alphabetically
-	sort.Slice(node.Cards, func(i, j int) bool {
-		return filepath.Base(node.Cards[i].FilePath) < filepath.Base(node.Cards[j].FilePath)
+	// Sort cards alphabetically, keeping cards from the same file in order
+	sort.SliceStable(node.Cards, func(i, j int) bool {
+		a, b := filepath.Base(node.Cards[i].FilePath), filepath.Base(node.Cards[j].FilePath)
+		if a != b {
+			return a < b
+		}
+		return node.Cards[i].Block < node.Cards[j].Block
 	})
 	
 	// Recursively sort children
@@ -147,7 +147,7 @@ func printDeckTree(node *DeckNode, prefix string, isLast bool) {
 			connector = "└── "
 		}
 		
-		cardName := strings.TrimSuffix(filepath.Base(card.FilePath), ".md")
+		cardName := card.Name()
 		statusInfo := getCardStatusInfo(card)
 		
 		fmt.Printf("%s%s%s %s\n", prefix, connector, cardName, statusInfo)
@@ -201,18 +201,19 @@ func getCardStatusInfo(card *Card) string {
 	}
 }
 
-func statusCommand(deckPath string) error {
-	// Build the tree structure
-	tree, err := buildDeckTree(deckPath)
-	if err != nil {
-		return fmt.Errorf("failed to build deck tree: %v", err)
-	}
-	
+func statusCommand(deckPath string, filter core.TagFilter) error {
 	// Get all cards for detailed stats
 	cards, err := findCards(deckPath)
 	if err != nil {
 		return fmt.Errorf("failed to load cards: %v", err)
 	}
+	cards = filter.Apply(cards)
+	
+	// Build the tree structure
+	tree, err := buildDeckTree(deckPath, cards)
+	if err != nil {
+		return fmt.Errorf("failed to build deck tree: %v", err)
+	}
 	
 	// Count totals and states
 	totalCards, dueCards := countCards(tree)
@@ -220,6 +221,9 @@ func statusCommand(deckPath string) error {
 	
 	// Print header with comprehensive stats
 	fmt.Printf("Deck: %s\n", deckPath)
+	if !filter.IsEmpty() {
+		fmt.Printf("Tags: %s\n", filter)
+	}
 	fmt.Printf("Cards: %d total, %d due | %d new, %d learning, %d review, %d relearning\n\n", 
 		totalCards, dueCards, new, learning, review, relearning)
 	
@@ -256,7 +260,7 @@ func countCards(node *DeckNode) (total, due int) {
 	// Count cards in this node
 	total += len(node.Cards)
 	for _, card := range node.Cards {
-		if card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now) {
+		if (card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now)) && !card.Suspended() {
 			due++
 		}
 	}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"srs/core"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+var (
+	summaryTitleStyle = lipgloss.NewStyle().Bold(true)
+
+	summaryLabelStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("241")).
+				Width(11)
+
+	summaryBoxStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("62")).
+			Padding(0, 1)
+
+	ratingColors = map[fsrs.Rating]lipgloss.Color{
+		fsrs.Again: lipgloss.Color("196"),
+		fsrs.Hard:  lipgloss.Color("208"),
+		fsrs.Good:  lipgloss.Color("42"),
+		fsrs.Easy:  lipgloss.Color("39"),
+	}
+)
+
+// record returns the session's ratings so far as a session record
+func (rs *ReviewSession) record(now time.Time) core.SessionRecord {
+	record := core.SessionRecord{
+		Start:   rs.started,
+		End:     now,
+		Reviews: rs.reviews,
+	}
+	if len(rs.cards) > 0 && rs.cards[0].Root != "" && rs.deckPath != "" {
+		if rel, err := filepath.Rel(rs.cards[0].Root, rs.deckPath); err == nil {
+			record.Deck = filepath.ToSlash(rel)
+		}
+	}
+	return record
+}
+
+// saveRecord appends the session to its deck's session log, if it rated
+// anything
+func (rs *ReviewSession) saveRecord(record core.SessionRecord) error {
+	if len(record.Reviews) == 0 || len(rs.cards) == 0 || rs.cards[0].Root == "" {
+		return nil
+	}
+	return core.AppendSession(rs.cards[0].Root, record)
+}
+
+// summary renders the end-of-session screen
+func (rs *ReviewSession) summary(record core.SessionRecord, now time.Time) string {
+	title := fmt.Sprintf("Session ended. Reviewed %d cards", len(record.Reviews))
+	if rs.done() {
+		title = fmt.Sprintf("Session complete! Reviewed %d cards", len(record.Reviews))
+	}
+	if len(record.Reviews) == 0 {
+		return title + ".\n"
+	}
+	title += " in " + formatDuration(record.End.Sub(record.Start))
+
+	var rows []string
+	row := func(label, value string) {
+		rows = append(rows, summaryLabelStyle.Render(label)+value)
+	}
+
+	counts := record.Counts()
+	var ratings []string
+	for _, rating := range []fsrs.Rating{fsrs.Again, fsrs.Hard, fsrs.Good, fsrs.Easy} {
+		style := lipgloss.NewStyle().Foreground(ratingColors[rating])
+		ratings = append(ratings, style.Render(fmt.Sprintf("%s %d", core.RatingToString(rating), counts[rating])))
+	}
+	row("Ratings", strings.Join(ratings, "  "))
+
+	if retention, total := record.Retention(); total > 0 {
+		row("Retention", fmt.Sprintf("%.0f%% of %d reviews", retention*100, total))
+	} else {
+		row("Retention", "no cards in review yet")
+	}
+
+	average, slowest := record.Timing()
+	row("Time", fmt.Sprintf("%s per card, slowest %s (%s)",
+		formatDuration(average), formatDuration(slowest.Duration()), rs.cardName(slowest.CardID)))
+
+	if lapses := record.Lapses(); len(lapses) > 0 {
+		names := make([]string, len(lapses))
+		for i, id := range lapses {
+			names[i] = rs.cardName(id)
+		}
+		row("Lapsed", strings.Join(names, ", "))
+	}
+
+	if rs.deck != nil {
+		row("Tomorrow", fmt.Sprintf("%d cards due", core.DueOn(rs.deck, now.AddDate(0, 0, 1))))
+	}
+
+	return summaryTitleStyle.Render(title) + "\n" + summaryBoxStyle.Render(strings.Join(rows, "\n")) + "\n"
+}
+
+// cardName returns the display name of a card rated in the session
+func (rs *ReviewSession) cardName(key string) string {
+	for _, card := range rs.cards {
+		if card.Key() == key {
+			return card.Name()
+		}
+	}
+	return key
+}
+
+// formatDuration formats a time spent reviewing, e.g. "8s" or "4m 30s"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
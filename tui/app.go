@@ -57,7 +57,7 @@ func StartTUI(cards []*core.Card) error {
 			}
 			
 			// Reload the card
-			updatedCard, err := core.ParseCard(final.currentCard.FilePath)
+			updatedCard, err := core.ReloadCard(final.currentCard)
 			if err != nil {
 				fmt.Printf("Error reloading card: %v\n", err)
 				return nil
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"srs/core"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/open-spaced-repetition/go-fsrs/v3"
@@ -15,8 +17,25 @@ type reviewState int
 const (
 	showingQuestion reviewState = iota
 	showingAnswer
+	waitingForLearning // nothing is due, but learning cards will be soon
 )
 
+// tickMsg refreshes the countdown while waiting for a learning card
+type tickMsg time.Time
+
+// gradeMsg carries the grader's verdict on the answer typed for a card
+type gradeMsg struct {
+	card  *Card
+	grade core.Grade
+	err   error
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
 type reviewModel struct {
 	session     *ReviewSession
 	currentCard *Card
@@ -27,6 +46,8 @@ type reviewModel struct {
 	quitting    bool
 	message     string
 	scroll      int
+	grading     bool        // the typed answer is being graded
+	grade       *core.Grade // the grader's suggested rating, if any
 }
 
 var (
@@ -72,6 +93,28 @@ func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 
+	case tickMsg:
+		if m.state != waitingForLearning {
+			return m, nil
+		}
+		if m.session.learnAheadCard(time.Time(msg), false) {
+			return m.showNext(), nil
+		}
+		return m, tick()
+
+	case gradeMsg:
+		// Verdicts arriving after the card was rated are stale
+		if !m.grading || msg.card != m.currentCard || m.state != showingAnswer {
+			return m, nil
+		}
+		m.grading = false
+		if msg.err != nil {
+			m.message = fmt.Sprintf("Couldn't grade the answer: %v", msg.err)
+			return m, nil
+		}
+		m.grade = &msg.grade
+		return m, nil
+
 	case tea.KeyMsg:
 		switch m.state {
 		case showingQuestion:
@@ -79,10 +122,16 @@ func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c":
 				m.quitting = true
 				return m, tea.Quit
+			case "ctrl+z":
+				return m.undo()
 			case "enter":
 				// If no user answer, just show the answer
-				// If user typed something, also show the answer
+				// If user typed something, also show the answer and grade it
 				m.state = showingAnswer
+				if m.session.grader != nil && strings.TrimSpace(m.userAnswer) != "" {
+					m.grading = true
+					return m, m.gradeAnswer()
+				}
 			case "backspace":
 				if len(m.userAnswer) > 0 {
 					m.userAnswer = m.userAnswer[:len(m.userAnswer)-1]
@@ -113,6 +162,12 @@ func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m.rateCard(fsrs.Good)
 			case "4":
 				return m.rateCard(fsrs.Easy)
+			case "enter":
+				if m.grade != nil {
+					return m.rateCard(m.grade.Rating)
+				}
+			case "u", "ctrl+z":
+				return m.undo()
 			case "e", "E":
 				// Exit TUI to edit, then restart
 				m.quitting = true
@@ -125,6 +180,19 @@ func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "down":
 				m.scroll++
 			}
+
+		case waitingForLearning:
+			switch msg.String() {
+			case "q", "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			case "enter":
+				if m.session.learnAheadCard(time.Now(), true) {
+					return m.showNext(), nil
+				}
+			case "u", "ctrl+z":
+				return m.undo()
+			}
 		}
 	}
 
@@ -139,55 +207,75 @@ func (m reviewModel) rateCard(rating fsrs.Rating) (tea.Model, tea.Cmd) {
 		m.message = fmt.Sprintf("Error updating card: %v", err)
 		return m, nil
 	}
+	m.session.undo = append(m.session.undo, undoStep{current: m.session.current, queued: len(m.session.cards)})
 
-	// Check all cards in the session to see if any have become due
-	// and add them to the end of the queue if they're not already in the remaining cards
-	now := time.Now()
-	remainingCards := m.session.cards[m.session.current+1:] // Cards we haven't reviewed yet
-	
-	for i := 0; i <= m.session.current; i++ { // Check all cards we've seen so far
-		card := m.session.cards[i]
-		if card.FSRSCard.Due.Before(now) || card.FSRSCard.Due.Equal(now) {
-			// Check if this card is already in the remaining queue
-			alreadyQueued := false
-			for _, remainingCard := range remainingCards {
-				if remainingCard.FilePath == card.FilePath {
-					alreadyQueued = true
-					break
-				}
-			}
-			
-			// If not already queued, add it to the end
-			if !alreadyQueued {
-				m.session.cards = append(m.session.cards, card)
-			}
+	// Move to the next card, requeueing any that have become due
+	m.session.advance(time.Now())
+	if m.session.current >= len(m.session.cards) {
+		if len(m.session.pending()) > 0 {
+			// Learning cards are coming up, so wait for them
+			m.state = waitingForLearning
+			m.message = ""
+			return m, tick()
 		}
-	}
 
-	// Move to next card
-	m.session.current++
-	if m.session.current >= len(m.session.cards) {
 		// Session complete
 		m.quitting = true
 		return m, tea.Quit
 	}
 
-	// Reset for next card
+	return m.showNext(), nil
+}
+
+// showNext shows the question of the session's current card
+func (m reviewModel) showNext() reviewModel {
+	m.session.shownAt = time.Now()
 	m.currentCard = m.session.cards[m.session.current]
 	m.state = showingQuestion
 	m.userAnswer = ""
 	m.message = ""
 	m.scroll = 0
+	m.grading = false
+	m.grade = nil
+	return m
+}
+
+// gradeAnswer grades the typed answer in the background, since a command
+// grader may take a while
+func (m reviewModel) gradeAnswer() tea.Cmd {
+	card, answer, grader := m.currentCard, m.userAnswer, m.session.grader
+	return func() tea.Msg {
+		grade, err := grader.Grade(card.Question, card.Expected(), answer)
+		return gradeMsg{card: card, grade: grade, err: err}
+	}
+}
+
+// undo reverts the last rating and shows that card's answer again
+func (m reviewModel) undo() (tea.Model, tea.Cmd) {
+	card, err := m.session.undoLast()
+	if err != nil {
+		m.message = fmt.Sprintf("Can't undo: %v", err)
+		return m, nil
+	}
 
+	m.session.shownAt = time.Now()
+	m.currentCard = card
+	m.state = showingAnswer
+	m.userAnswer = ""
+	m.message = "Rating undone"
+	m.scroll = 0
+	m.grading = false
+	m.grade = nil
 	return m, nil
 }
 
 func (m reviewModel) View() string {
 	if m.quitting {
-		if m.session.current >= len(m.session.cards) {
-			return fmt.Sprintf("Session complete! Reviewed %d cards.\n", len(m.session.cards))
-		}
-		return fmt.Sprintf("Session ended. Reviewed %d cards.\n", m.session.current)
+		return ""
+	}
+
+	if m.state == waitingForLearning {
+		return m.waitingView()
 	}
 
 	// Calculate available height for content (leave room for header and help)
@@ -221,6 +309,14 @@ func (m reviewModel) View() string {
 		answerText := RenderMarkdown(m.currentCard.Answer)
 		answer := answerStyle.Width(m.width - 4).Render(answerText)
 		content = append(content, answer)
+
+		// The grader's suggestion for the typed answer
+		if m.grading {
+			content = append(content, promptStyle.Render("Grading your answer..."))
+		} else if m.grade != nil {
+			suggestion := lipgloss.NewStyle().Foreground(ratingColors[m.grade.Rating]).Width(m.width - 4)
+			content = append(content, suggestion.Render(describeGrade(*m.grade)))
+		}
 	}
 
 	// Join content and handle scrolling
@@ -262,12 +358,15 @@ func (m reviewModel) View() string {
 	switch m.state {
 	case showingQuestion:
 		if m.userAnswer != "" {
-			help = "Enter = show answer • ↑/↓ = scroll • Backspace = delete • Ctrl+C = quit"
+			help = "Enter = show answer • ↑/↓ = scroll • Backspace = delete • Ctrl+Z = undo • Ctrl+C = quit"
 		} else {
-			help = "Type answer or Enter to skip • ↑/↓ = scroll • Ctrl+C = quit"
+			help = "Type answer or Enter to skip • ↑/↓ = scroll • Ctrl+Z = undo • Ctrl+C = quit"
 		}
 	case showingAnswer:
-		help = "1 = Again • 2 = Hard • 3 = Good • 4 = Easy • ↑/↓ = scroll\ne = edit • q = quit"
+		help = "1 = Again • 2 = Hard • 3 = Good • 4 = Easy • ↑/↓ = scroll\ne = edit • u = undo • q = quit"
+		if m.grade != nil {
+			help = "Enter = accept suggestion • " + help
+		}
 	}
 
 	helpText := helpStyle.Render(help)
@@ -289,15 +388,51 @@ func (m reviewModel) View() string {
 	return result
 }
 
+// waitingView counts down to the next learning card
+func (m reviewModel) waitingView() string {
+	pending := m.session.pending()
+	if len(pending) == 0 {
+		return ""
+	}
+	next := pending[0]
+
+	header := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("Reviewed %d cards", m.session.current))
+	wait := core.FormatWait(time.Until(next.FSRSCard.Due))
+	text := fmt.Sprintf("No more cards are due. Next card in %s: %s", wait, next.Name())
+	if len(pending) > 1 {
+		text += fmt.Sprintf("\n%d learning cards pending in this session", len(pending))
+	}
+
+	result := header + "\n\n" + text + "\n\n" + helpStyle.Render("Enter = review early • u = undo • q = quit")
+	if m.message != "" {
+		messageStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		result += "\n" + messageStyle.Render(m.message)
+	}
+	return result
+}
+
+// programOptions returns the TUI's options, reading keys from the terminal
+// when stdin is a pipe of card IDs
+func programOptions() []tea.ProgramOption {
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !stdinIsTerminal() {
+		options = append(options, tea.WithInputTTY())
+	}
+	return options
+}
+
 func (rs *ReviewSession) StartTUI() error {
 	if len(rs.cards) == 0 {
 		fmt.Println("No cards to review!")
 		return nil
 	}
 
+	rs.started = time.Now()
+	rs.shownAt = rs.started
+
 	for {
 		model := newReviewModel(rs)
-		program := tea.NewProgram(model, tea.WithAltScreen())
+		program := tea.NewProgram(model, programOptions()...)
 		
 		finalModel, err := program.Run()
 		if err != nil {
@@ -328,7 +463,7 @@ func (rs *ReviewSession) StartTUI() error {
 			}
 			
 			// Reload the card
-			updatedCard, err := parseCard(final.currentCard.FilePath)
+			updatedCard, err := reloadCard(final.currentCard)
 			if err != nil {
 				fmt.Printf("Error reloading card: %v\n", err)
 				return nil
@@ -343,7 +478,7 @@ func (rs *ReviewSession) StartTUI() error {
 			model.state = savedState
 			
 			// Continue with restored state
-			program := tea.NewProgram(model, tea.WithAltScreen())
+			program := tea.NewProgram(model, programOptions()...)
 			finalModel, err := program.Run()
 			if err != nil {
 				return fmt.Errorf("TUI error: %v", err)
@@ -355,11 +490,13 @@ func (rs *ReviewSession) StartTUI() error {
 			continue
 		}
 		
-		// Check if we completed the session or user quit normally
-		if final.session.current >= len(rs.cards) {
-			fmt.Printf("Session complete! Reviewed %d cards.\n", len(rs.cards))
-		} else {
-			fmt.Printf("Session ended. Reviewed %d cards.\n", final.session.current)
+		// Show how the session went, whether it was completed or quit
+		now := time.Now()
+		record := rs.record(now)
+		fmt.Print(rs.summary(record, now))
+		fmt.Print(pendingSummary(rs.pending(), now))
+		if err := rs.saveRecord(record); err != nil {
+			fmt.Printf("Error saving session: %v\n", err)
 		}
 		
 		break // Exit the loop for normal completion